@@ -0,0 +1,31 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+const (
+	apiKeyPrefix    = "og_"
+	apiKeyPrefixLen = len(apiKeyPrefix) + 8
+)
+
+// GenerateAPIKey returns a new random key, its display prefix and the hash
+// to store. The key itself is shown to the user once and never stored.
+func GenerateAPIKey() (key, prefix, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", "", err
+	}
+	key = apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return key, key[:apiKeyPrefixLen], HashAPIKey(key), nil
+}
+
+// HashAPIKey returns the hex SHA-256 of key. Keys carry 256 bits of
+// entropy, so a fast unsalted hash is sufficient and allows direct lookup.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
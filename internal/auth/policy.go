@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/hitanshu0729/order_go/internal/models"
+	"github.com/hitanshu0729/order_go/internal/problem"
+)
+
+var roleRank = map[string]int{
+	models.RoleCustomer: 0,
+	models.RoleStaff:    1,
+	models.RoleAdmin:    2,
+}
+
+// ValidRole reports whether role is a known role.
+func ValidRole(role string) bool {
+	_, ok := roleRank[role]
+	return ok
+}
+
+// HasRole reports whether the principal holds role or a more privileged
+// one. Admins are staff too.
+func (p *Principal) HasRole(role string) bool {
+	have, ok := roleRank[p.Role]
+	return ok && have >= roleRank[role]
+}
+
+// IsStaff reports whether the principal acts on behalf of the shop rather
+// than as a customer.
+func (p *Principal) IsStaff() bool {
+	return p.HasRole(models.RoleStaff)
+}
+
+// CanAccessUser reports whether the principal may read or act on userID's
+// account: users may access themselves, staff may access anyone.
+func (p *Principal) CanAccessUser(userID int64) bool {
+	return p.UserID == userID || p.IsStaff()
+}
+
+// CanManageUser reports whether the principal may modify userID's account:
+// users may modify themselves, admins may modify anyone.
+func (p *Principal) CanManageUser(userID int64) bool {
+	return p.UserID == userID || p.HasRole(models.RoleAdmin)
+}
+
+// CanAccessOrder reports whether the principal may see and mutate order.
+// Customers are limited to their own orders.
+func (p *Principal) CanAccessOrder(order *models.Order) bool {
+	return p.CanAccessUser(order.UserID)
+}
+
+// RequireRole rejects requests whose principal does not hold role with 403.
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if p, ok := PrincipalFrom(c); !ok || !p.HasRole(role) {
+			problem.Abort(c, problem.Forbidden("requires "+role+" role"))
+			return
+		}
+		c.Next()
+	}
+}
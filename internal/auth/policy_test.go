@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/hitanshu0729/order_go/internal/models"
+)
+
+func TestPolicy(t *testing.T) {
+	customer := &Principal{UserID: 1, Role: models.RoleCustomer}
+	staff := &Principal{UserID: 2, Role: models.RoleStaff}
+	admin := &Principal{UserID: 3, Role: models.RoleAdmin}
+	unknown := &Principal{UserID: 4, Role: "root"}
+
+	own := &models.Order{UserID: 1}
+	other := &models.Order{UserID: 9}
+
+	tests := []struct {
+		name string
+		got  bool
+		want bool
+	}{
+		{"customer is not staff", customer.IsStaff(), false},
+		{"admin is staff", admin.IsStaff(), true},
+		{"unknown role has no rank", unknown.HasRole(models.RoleCustomer), false},
+		{"customer sees own order", customer.CanAccessOrder(own), true},
+		{"customer cannot see others' orders", customer.CanAccessOrder(other), false},
+		{"staff sees any order", staff.CanAccessOrder(other), true},
+		{"staff cannot manage other users", staff.CanManageUser(9), false},
+		{"admin manages other users", admin.CanManageUser(9), true},
+		{"customer manages self", customer.CanManageUser(1), true},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %t, want %t", tt.name, tt.got, tt.want)
+		}
+	}
+}
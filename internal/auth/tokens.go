@@ -0,0 +1,205 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/hitanshu0729/order_go/internal/models"
+)
+
+var (
+	// ErrInvalidToken indicates a token that failed verification
+	ErrInvalidToken = errors.New("invalid token")
+
+	// ErrSigningDisabled indicates no signing key is configured for issuance
+	ErrSigningDisabled = errors.New("token issuance is not configured")
+)
+
+// Claims are the JWT claims issued for a user. The subject is the user id.
+type Claims struct {
+	Email string `json:"email,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// UserID parses the numeric subject.
+func (c *Claims) UserID() (int64, error) {
+	return strconv.ParseInt(c.Subject, 10, 64)
+}
+
+// TokenService issues and verifies JWTs. Only tokens signed with the
+// configured algorithm are accepted.
+type TokenService struct {
+	alg     string
+	method  jwt.SigningMethod
+	signKey any
+	keyID   string
+
+	secret  []byte
+	rsaKeys map[string]*rsa.PublicKey
+
+	issuer string
+	ttl    time.Duration
+}
+
+func NewTokenService(cfg Config) (*TokenService, error) {
+	s := &TokenService{
+		alg:     cfg.Algorithm,
+		rsaKeys: map[string]*rsa.PublicKey{},
+		keyID:   cfg.KeyID,
+		issuer:  cfg.Issuer,
+		ttl:     cfg.TokenTTL,
+	}
+
+	switch cfg.Algorithm {
+	case "HS256":
+		if len(cfg.Secret) == 0 {
+			return nil, errors.New("JWT_SECRET is required for HS256")
+		}
+		s.method = jwt.SigningMethodHS256
+		s.signKey = cfg.Secret
+		s.secret = cfg.Secret
+	case "RS256":
+		// A shared secret next to RSA keys would let anyone holding it mint
+		// HS256 tokens, so refuse the mix rather than ignore it.
+		if len(cfg.Secret) > 0 {
+			return nil, errors.New("JWT_SECRET must not be set for RS256")
+		}
+		if cfg.JWKSFile != "" {
+			keys, err := loadJWKS(cfg.JWKSFile)
+			if err != nil {
+				return nil, err
+			}
+			for kid, key := range keys {
+				s.rsaKeys[kid] = key
+			}
+		}
+		if cfg.PrivateKeyFile != "" {
+			pem, err := os.ReadFile(cfg.PrivateKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			key, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+			if err != nil {
+				return nil, fmt.Errorf("parse %s: %w", cfg.PrivateKeyFile, err)
+			}
+			s.method = jwt.SigningMethodRS256
+			s.signKey = key
+			s.rsaKeys[cfg.KeyID] = &key.PublicKey
+		}
+		if len(s.rsaKeys) == 0 {
+			return nil, errors.New("JWT_PRIVATE_KEY_FILE or JWT_JWKS_FILE is required for RS256")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported JWT_ALG %q", cfg.Algorithm)
+	}
+	return s, nil
+}
+
+// TTL is the lifetime of issued tokens.
+func (s *TokenService) TTL() time.Duration {
+	return s.ttl
+}
+
+// Issue signs a token for user.
+func (s *TokenService) Issue(user *models.User) (string, time.Time, error) {
+	if s.signKey == nil {
+		return "", time.Time{}, ErrSigningDisabled
+	}
+	now := time.Now()
+	expiresAt := now.Add(s.ttl)
+	claims := Claims{
+		Email: user.Email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.FormatUint(uint64(user.ID), 10),
+			Issuer:    s.issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+	token := jwt.NewWithClaims(s.method, claims)
+	if s.keyID != "" {
+		token.Header["kid"] = s.keyID
+	}
+	signed, err := token.SignedString(s.signKey)
+	return signed, expiresAt, err
+}
+
+// Verify checks a token's algorithm, signature, expiry and issuer.
+func (s *TokenService) Verify(raw string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(raw, claims, s.key,
+		jwt.WithValidMethods([]string{s.alg}),
+		jwt.WithIssuer(s.issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if _, err := claims.UserID(); err != nil {
+		return nil, fmt.Errorf("%w: subject is not a user id", ErrInvalidToken)
+	}
+	return claims, nil
+}
+
+func (s *TokenService) key(t *jwt.Token) (any, error) {
+	switch t.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		return s.secret, nil
+	case *jwt.SigningMethodRSA:
+		kid, _ := t.Header["kid"].(string)
+		if key, ok := s.rsaKeys[kid]; ok {
+			return key, nil
+		}
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return nil, fmt.Errorf("unexpected signing method %s", t.Method.Alg())
+}
+
+type jwks struct {
+	Keys []struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+// loadJWKS reads the RSA public keys from a JWKS file, keyed by kid.
+func loadJWKS(path string) (map[string]*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var set jwks
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: key %q modulus: %w", path, k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: key %q exponent: %w", path, k.Kid, err)
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hitanshu0729/order_go/internal/models"
+)
+
+var testUser = &models.User{ID: 42, Email: "a@example.com"}
+
+func TestHS256RoundTrip(t *testing.T) {
+	s, err := NewTokenService(Config{Algorithm: "HS256", Secret: []byte("s3cret"), Issuer: "test", TokenTTL: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := s.Issue(testUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := s.Verify(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := claims.UserID(); id != 42 || claims.Email != "a@example.com" {
+		t.Fatalf("claims = %+v", claims)
+	}
+
+	other, _ := NewTokenService(Config{Algorithm: "HS256", Secret: []byte("other"), Issuer: "test", TokenTTL: time.Minute})
+	if _, err := other.Verify(token); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("wrong secret: err = %v", err)
+	}
+	wrongIssuer, _ := NewTokenService(Config{Algorithm: "HS256", Secret: []byte("s3cret"), Issuer: "else", TokenTTL: time.Minute})
+	if _, err := wrongIssuer.Verify(token); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("wrong issuer: err = %v", err)
+	}
+}
+
+func TestExpiredTokenRejected(t *testing.T) {
+	s, _ := NewTokenService(Config{Algorithm: "HS256", Secret: []byte("s3cret"), Issuer: "test", TokenTTL: -time.Minute})
+	token, _, err := s.Issue(testUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Verify(token); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestRS256WithJWKS(t *testing.T) {
+	dir := t.TempDir()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "key.pem")
+	block := &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	issuer, err := NewTokenService(Config{Algorithm: "RS256", PrivateKeyFile: keyFile, KeyID: "k1", Issuer: "test", TokenTTL: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := issuer.Issue(testUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A verifier holding only the public key set accepts the token but
+	// cannot issue its own.
+	set := map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": "k1",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}}
+	data, _ := json.Marshal(set)
+	jwksFile := filepath.Join(dir, "jwks.json")
+	if err := os.WriteFile(jwksFile, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := NewTokenService(Config{Algorithm: "RS256", JWKSFile: jwksFile, Issuer: "test", TokenTTL: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verifier.Verify(token); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if _, _, err := verifier.Issue(testUser); !errors.Is(err, ErrSigningDisabled) {
+		t.Fatalf("issue: err = %v", err)
+	}
+}
+
+func TestRS256RejectsHS256Token(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "key.pem")
+	block := &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := Config{Algorithm: "RS256", PrivateKeyFile: keyFile, KeyID: "k1", Issuer: "test", TokenTTL: time.Minute}
+	rs, err := NewTokenService(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An HS256 token for the same issuer, as anyone knowing a secret could
+	// mint one.
+	hs, err := NewTokenService(Config{Algorithm: "HS256", Secret: []byte("s3cret"), Issuer: "test", TokenTTL: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged, _, err := hs.Issue(testUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rs.Verify(forged); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("HS256 token: err = %v, want %v", err, ErrInvalidToken)
+	}
+
+	cfg.Secret = []byte("s3cret")
+	if _, err := NewTokenService(cfg); err == nil {
+		t.Fatal("RS256 service accepted a JWT_SECRET")
+	}
+}
@@ -0,0 +1,146 @@
+package catalog
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hitanshu0729/order_go/internal/models"
+)
+
+// readAll returns the valid rows of a file and the lines of its row errors.
+func readAll(t *testing.T, format, file string) ([]*models.ProductRow, []int) {
+	t.Helper()
+	r, err := NewReader(strings.NewReader(file), format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rows []*models.ProductRow
+	var errLines []int
+	for {
+		row, err := r.Next()
+		if err == io.EOF {
+			return rows, errLines
+		}
+		var rowErr *models.ImportRowError
+		if errors.As(err, &rowErr) {
+			errLines = append(errLines, rowErr.Line)
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, row)
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func TestReadCSV(t *testing.T) {
+	file := "\ufeffsku,parent_sku,name,price,stock,attr.size\n" +
+		"TEE,,Tee,500,,\n" +
+		"TEE-S,TEE,,,3,S\n" +
+		"TEE-L,TEE,Large,,,L\n" + // variants have no name
+		"MUG,,Mug,abc,1,\n" +
+		"SHORT,,Short\n" +
+		",,Nameless,100,1,\n" +
+		"\"CUP\",,\"Cup, large\",200, 4 ,\n"
+
+	rows, errLines := readAll(t, models.ImportFormatCSV, file)
+	want := []*models.ProductRow{
+		{Line: 2, SKU: "TEE", Name: ptr("Tee"), Price: ptr[int64](500)},
+		{Line: 3, SKU: "TEE-S", ParentSKU: "TEE", Stock: ptr[int64](3), Attributes: map[string]string{"size": "S"}},
+		{Line: 8, SKU: "CUP", Name: ptr("Cup, large"), Price: ptr[int64](200), Stock: ptr[int64](4)},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		for _, r := range rows {
+			t.Logf("%+v", *r)
+		}
+		t.Errorf("rows do not match")
+	}
+	if want := []int{4, 5, 6, 7}; !reflect.DeepEqual(errLines, want) {
+		t.Errorf("error lines = %v, want %v", errLines, want)
+	}
+}
+
+func TestReadCSVHeader(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+	}{
+		{"empty file", ""},
+		{"unknown column", "sku,colour\n"},
+		{"invalid attribute", "sku,attr.a b\n"},
+		{"duplicate column", "sku,name,name\n"},
+		{"missing sku", "name,price\n"},
+	}
+	for _, tt := range tests {
+		if _, err := NewReader(strings.NewReader(tt.header), models.ImportFormatCSV); err == nil {
+			t.Errorf("%s: got no error", tt.name)
+		}
+	}
+}
+
+func TestReadNDJSON(t *testing.T) {
+	file := `{"sku":"TEE","name":"Tee","price":500,"attributes":{"material":"cotton"}}
+
+{"sku":"TEE-S","parent_sku":"TEE","stock":3}
+{"sku":"X","colour":"red"}
+{"sku":"Y","price":0}
+not json
+{"sku":"Z","category":""}
+`
+	rows, errLines := readAll(t, models.ImportFormatNDJSON, file)
+	want := []*models.ProductRow{
+		{Line: 1, SKU: "TEE", Name: ptr("Tee"), Price: ptr[int64](500), Attributes: map[string]string{"material": "cotton"}},
+		{Line: 3, SKU: "TEE-S", ParentSKU: "TEE", Stock: ptr[int64](3)},
+		{Line: 7, SKU: "Z", Category: ptr("")},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		for _, r := range rows {
+			t.Logf("%+v", *r)
+		}
+		t.Errorf("rows do not match")
+	}
+	if want := []int{4, 5, 6}; !reflect.DeepEqual(errLines, want) {
+		t.Errorf("error lines = %v, want %v", errLines, want)
+	}
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	rows := []*models.ProductRow{
+		{SKU: "TEE", Name: ptr("Tee, cotton"), Description: ptr("Soft\nand light"), Category: ptr("shirts"),
+			Price: ptr[int64](500), Stock: ptr[int64](0), Attributes: map[string]string{"material": "cotton"}},
+		{SKU: "TEE-S", ParentSKU: "TEE", Price: ptr[int64](500), Stock: ptr[int64](3),
+			Attributes: map[string]string{"size": "S"}},
+	}
+	for _, format := range []string{models.ImportFormatCSV, models.ImportFormatNDJSON} {
+		var buf bytes.Buffer
+		w, err := NewWriter(&buf, format, []string{"material", "size"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, row := range rows {
+			if err := w.Write(row); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		got, errLines := readAll(t, format, buf.String())
+		if len(errLines) > 0 {
+			t.Fatalf("%s: row errors on lines %v:\n%s", format, errLines, buf.String())
+		}
+		for _, row := range got {
+			row.Line = 0
+		}
+		if !reflect.DeepEqual(got, rows) {
+			t.Errorf("%s: round trip changed rows:\n%s", format, buf.String())
+		}
+	}
+}
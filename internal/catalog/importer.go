@@ -0,0 +1,114 @@
+package catalog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/hitanshu0729/order_go/internal/domain"
+	"github.com/hitanshu0729/order_go/internal/jobs"
+	"github.com/hitanshu0729/order_go/internal/models"
+	"github.com/hitanshu0729/order_go/internal/storage/sqlite"
+)
+
+// ImportJobType is the job type that runs a queued product import.
+const ImportJobType = "products.import"
+
+// progressRows is how often, in rows read, a queued import reports its
+// progress.
+const progressRows = 100
+
+// ImportJobPayload is the payload of an ImportJobType job.
+type ImportJobPayload struct {
+	ImportID int64 `json:"import_id"`
+}
+
+// Importer runs product imports. Small files are imported while the client
+// waits; larger ones are queued as jobs and run by RunJob.
+type Importer struct {
+	repo *sqlite.Repo
+}
+
+func NewImporter(repo *sqlite.Repo) *Importer {
+	return &Importer{repo: repo}
+}
+
+// RunJob is the jobs.Func for ImportJobType.
+func (im *Importer) RunJob(ctx context.Context, job *models.Job) (any, error) {
+	var p ImportJobPayload
+	if err := json.Unmarshal(job.Payload, &p); err != nil {
+		return nil, jobs.Permanent(fmt.Errorf("invalid payload: %w", err))
+	}
+	imp, payload, err := im.repo.StartProductImport(ctx, p.ImportID)
+	if errors.Is(err, domain.ErrImportNotFound) {
+		return nil, jobs.Permanent(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if imp == nil {
+		slog.InfoContext(ctx, "product import already finished", "import_id", p.ImportID)
+		return p, nil
+	}
+
+	err = im.importPayload(ctx, imp, payload)
+	if err != nil && ctx.Err() != nil {
+		if !errors.Is(context.Cause(ctx), jobs.ErrCancelled) {
+			// The worker is stopping or lost its lease. Nothing was
+			// committed, and the import runs again with the job.
+			return nil, err
+		}
+		err = jobs.ErrCancelled
+	}
+	if err := im.finish(ctx, imp, err); err != nil {
+		return nil, err
+	}
+	return map[string]any{"import_id": imp.ID, "status": imp.Status}, nil
+}
+
+// Run imports payload for imp, which must already be running, and records
+// the outcome on imp and in the database. A file that cannot be read fails
+// the import rather than Run; Run only fails when the outcome cannot be
+// stored.
+func (im *Importer) Run(ctx context.Context, imp *models.ProductImport, payload []byte) error {
+	return im.finish(ctx, imp, im.importPayload(ctx, imp, payload))
+}
+
+// importPayload reads and applies payload, reporting progress by how much
+// of it has been read when running as a job.
+func (im *Importer) importPayload(ctx context.Context, imp *models.ProductImport, payload []byte) error {
+	src := bytes.NewReader(payload)
+	r, err := NewReader(src, imp.Format)
+	if err != nil {
+		return err
+	}
+	rows := 0
+	return im.repo.ImportProducts(ctx, imp, func() (*models.ProductRow, error) {
+		if rows++; rows%progressRows == 0 {
+			read := len(payload) - src.Len()
+			jobs.Progress(ctx, 100*read/len(payload), fmt.Sprintf("%d rows read", rows))
+		}
+		return r.Next()
+	})
+}
+
+// finish sets imp's status from the error of importing it and stores the
+// outcome.
+func (im *Importer) finish(ctx context.Context, imp *models.ProductImport, err error) error {
+	switch {
+	case err != nil:
+		imp.Status, imp.Error = models.ImportStatusFailed, err.Error()
+	case imp.Failed > 0:
+		imp.Status = models.ImportStatusFailed
+	default:
+		imp.Status = models.ImportStatusCompleted
+	}
+	slog.InfoContext(ctx, "product import finished",
+		"import_id", imp.ID, "status", imp.Status, "dry_run", imp.DryRun, "rows", imp.TotalRows,
+		"created", imp.Created, "updated", imp.Updated, "failed", imp.Failed, "error", imp.Error,
+	)
+	return im.repo.FinishProductImport(context.WithoutCancel(ctx), imp)
+}
@@ -0,0 +1,244 @@
+// Package catalog reads and writes product import and export files and
+// runs imports in the background.
+//
+// Both formats carry one product or variant per row. CSV files have a
+// header naming their columns: sku (required), parent_sku, name,
+// description, category (a slug), price, stock and one attr.<name> column
+// per attribute; blank cells leave the stored value unchanged. NDJSON
+// files have one models.ProductRow object per line.
+package catalog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hitanshu0729/order_go/internal/models"
+)
+
+// Limits on imported fields, matching those on the product endpoints.
+const (
+	MaxSKULength         = 64
+	MaxDescriptionLength = 5000
+	MaxAttributes        = 50
+	MaxAttributeValue    = 200
+
+	// maxLineBytes bounds one NDJSON line.
+	maxLineBytes = 1 << 20
+)
+
+var attributeName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,50}$`)
+
+// ValidAttributeName reports whether name may be used as a product
+// attribute: 1-50 letters, digits, '_' or '-'.
+func ValidAttributeName(name string) bool {
+	return attributeName.MatchString(name)
+}
+
+// ValidFormat reports whether format is a supported file format.
+func ValidFormat(format string) bool {
+	return format == models.ImportFormatCSV || format == models.ImportFormatNDJSON
+}
+
+var columns = []string{"sku", "parent_sku", "name", "description", "category", "price", "stock"}
+
+// Reader reads product rows from an import file.
+type Reader struct {
+	csv    *csv.Reader
+	header []string
+	lines  *bufio.Scanner
+	line   int
+}
+
+// NewReader returns a Reader for r in format. For CSV it reads and checks
+// the header.
+func NewReader(r io.Reader, format string) (*Reader, error) {
+	switch format {
+	case models.ImportFormatCSV:
+		return newCSVReader(r)
+	case models.ImportFormatNDJSON:
+		lines := bufio.NewScanner(r)
+		lines.Buffer(nil, maxLineBytes)
+		return &Reader{lines: lines}, nil
+	}
+	return nil, fmt.Errorf("unsupported format %q", format)
+}
+
+func newCSVReader(r io.Reader) (*Reader, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("missing CSV header")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV header: %w", err)
+	}
+	header = slices.Clone(header)
+	header[0] = strings.TrimPrefix(header[0], "\ufeff") // byte order mark
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		header[i] = name
+		attr, isAttr := strings.CutPrefix(name, "attr.")
+		switch {
+		case isAttr && !ValidAttributeName(attr):
+			return nil, fmt.Errorf("invalid attribute column %q", name)
+		case !isAttr && !slices.Contains(columns, name):
+			return nil, fmt.Errorf("unknown column %q", name)
+		case slices.Index(header[:i], name) >= 0:
+			return nil, fmt.Errorf("duplicate column %q", name)
+		}
+	}
+	if !slices.Contains(header, "sku") {
+		return nil, errors.New("missing sku column")
+	}
+	cr.ReuseRecord = true
+	return &Reader{csv: cr, header: header}, nil
+}
+
+// Next returns the next row. A row that cannot be parsed or is invalid is
+// reported as a *models.ImportRowError, after which reading may continue.
+// Next returns io.EOF after the last row; any other error ends the file.
+func (r *Reader) Next() (*models.ProductRow, error) {
+	var row *models.ProductRow
+	var err error
+	if r.csv != nil {
+		row, err = r.nextCSV()
+	} else {
+		row, err = r.nextNDJSON()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if msg := validate(row); msg != "" {
+		return nil, &models.ImportRowError{Line: row.Line, SKU: row.SKU, Message: msg}
+	}
+	return row, nil
+}
+
+func (r *Reader) nextCSV() (*models.ProductRow, error) {
+	record, err := r.csv.Read()
+	line, _ := r.csv.FieldPos(0)
+	if errors.Is(err, csv.ErrFieldCount) {
+		return nil, &models.ImportRowError{Line: line, Message: fmt.Sprintf("expected %d fields, got %d", len(r.header), len(record))}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	row := &models.ProductRow{Line: line}
+	var errs []string
+	for i, cell := range record {
+		cell = strings.TrimSpace(cell)
+		if cell == "" {
+			continue
+		}
+		switch name := r.header[i]; name {
+		case "sku":
+			row.SKU = cell
+		case "parent_sku":
+			row.ParentSKU = cell
+		case "name":
+			row.Name = &cell
+		case "description":
+			row.Description = &cell
+		case "category":
+			row.Category = &cell
+		case "price", "stock":
+			n, err := strconv.ParseInt(cell, 10, 64)
+			if err != nil {
+				errs = append(errs, name+" must be an integer")
+				continue
+			}
+			if name == "price" {
+				row.Price = &n
+			} else {
+				row.Stock = &n
+			}
+		default:
+			if row.Attributes == nil {
+				row.Attributes = map[string]string{}
+			}
+			row.Attributes[strings.TrimPrefix(name, "attr.")] = cell
+		}
+	}
+	if len(errs) > 0 {
+		return nil, &models.ImportRowError{Line: line, SKU: row.SKU, Message: strings.Join(errs, "; ")}
+	}
+	return row, nil
+}
+
+func (r *Reader) nextNDJSON() (*models.ProductRow, error) {
+	for r.lines.Scan() {
+		r.line++
+		b := bytes.TrimSpace(r.lines.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+		row := &models.ProductRow{Line: r.line}
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(row); err != nil {
+			return nil, &models.ImportRowError{Line: r.line, Message: "invalid JSON: " + err.Error()}
+		}
+		if dec.More() {
+			return nil, &models.ImportRowError{Line: r.line, Message: "invalid JSON: more than one value on the line"}
+		}
+		return row, nil
+	}
+	if err := r.lines.Err(); err != nil {
+		return nil, fmt.Errorf("line %d: %w", r.line+1, err)
+	}
+	return nil, io.EOF
+}
+
+// validate returns why row is invalid, or "" when it is valid. Whether a
+// new product has its name and price can only be checked on import.
+func validate(row *models.ProductRow) string {
+	var errs []string
+	switch {
+	case row.SKU == "":
+		errs = append(errs, "sku is required")
+	case len(row.SKU) > MaxSKULength:
+		errs = append(errs, fmt.Sprintf("sku must be at most %d characters", MaxSKULength))
+	}
+	if row.ParentSKU != "" {
+		if row.ParentSKU == row.SKU {
+			errs = append(errs, "parent_sku must differ from sku")
+		}
+		if row.Name != nil || row.Description != nil || row.Category != nil {
+			errs = append(errs, "variants take their name, description and category from the product")
+		}
+	}
+	if row.Name != nil && *row.Name == "" {
+		errs = append(errs, "name must not be empty")
+	}
+	if row.Description != nil && len(*row.Description) > MaxDescriptionLength {
+		errs = append(errs, fmt.Sprintf("description must be at most %d characters", MaxDescriptionLength))
+	}
+	if row.Price != nil && *row.Price <= 0 {
+		errs = append(errs, "price must be greater than 0")
+	}
+	if row.Stock != nil && *row.Stock < 0 {
+		errs = append(errs, "stock must not be negative")
+	}
+	if len(row.Attributes) > MaxAttributes {
+		errs = append(errs, fmt.Sprintf("at most %d attributes are allowed", MaxAttributes))
+	}
+	for _, name := range slices.Sorted(maps.Keys(row.Attributes)) {
+		if !ValidAttributeName(name) {
+			errs = append(errs, fmt.Sprintf("invalid attribute name %q", name))
+		} else if len(row.Attributes[name]) > MaxAttributeValue {
+			errs = append(errs, fmt.Sprintf("attribute %s must be at most %d characters", name, MaxAttributeValue))
+		}
+	}
+	return strings.Join(errs, "; ")
+}
@@ -0,0 +1,82 @@
+package catalog
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/hitanshu0729/order_go/internal/models"
+)
+
+// Writer writes product rows to an export file that can be imported again.
+type Writer struct {
+	csv        *csv.Writer
+	attributes []string
+	json       *json.Encoder
+}
+
+// NewWriter returns a Writer for w in format. A CSV file gets one
+// attr.<name> column for each of attributes, and its header is written
+// immediately.
+func NewWriter(w io.Writer, format string, attributes []string) (*Writer, error) {
+	switch format {
+	case models.ImportFormatCSV:
+		cw := csv.NewWriter(w)
+		header := append([]string(nil), columns...)
+		for _, name := range attributes {
+			header = append(header, "attr."+name)
+		}
+		if err := cw.Write(header); err != nil {
+			return nil, err
+		}
+		return &Writer{csv: cw, attributes: attributes}, nil
+	case models.ImportFormatNDJSON:
+		return &Writer{json: json.NewEncoder(w)}, nil
+	}
+	return nil, fmt.Errorf("unsupported format %q", format)
+}
+
+// Write writes one row. CSV output is buffered until Flush.
+func (w *Writer) Write(row *models.ProductRow) error {
+	if w.json != nil {
+		return w.json.Encode(row)
+	}
+	record := []string{
+		row.SKU,
+		row.ParentSKU,
+		deref(row.Name),
+		deref(row.Description),
+		deref(row.Category),
+		formatInt(row.Price),
+		formatInt(row.Stock),
+	}
+	for _, name := range w.attributes {
+		record = append(record, row.Attributes[name])
+	}
+	return w.csv.Write(record)
+}
+
+// Flush writes any buffered output.
+func (w *Writer) Flush() error {
+	if w.csv == nil {
+		return nil
+	}
+	w.csv.Flush()
+	return w.csv.Error()
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func formatInt(n *int64) string {
+	if n == nil {
+		return ""
+	}
+	return strconv.FormatInt(*n, 10)
+}
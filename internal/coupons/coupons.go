@@ -0,0 +1,140 @@
+// Package coupons validates discount codes and turns them into pricing
+// discounts. Usage limits need the database and are enforced by the repo.
+package coupons
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hitanshu0729/order_go/internal/domain"
+	"github.com/hitanshu0729/order_go/internal/models"
+	"github.com/hitanshu0729/order_go/internal/money"
+	"github.com/hitanshu0729/order_go/internal/pricing"
+)
+
+// NormalizeCode returns the canonical (upper-case, trimmed) form of a code.
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Validate checks that a coupon definition is internally consistent.
+func Validate(c *models.Coupon) error {
+	if c.Code == "" {
+		return fmt.Errorf("%w: code is required", domain.ErrInvalidCoupon)
+	}
+	switch c.Kind {
+	case models.CouponKindPercentage:
+		if c.PercentOff < 1 || c.PercentOff > 100 {
+			return fmt.Errorf("%w: percent_off must be between 1 and 100", domain.ErrInvalidCoupon)
+		}
+	case models.CouponKindFixed:
+		if c.AmountOff <= 0 {
+			return fmt.Errorf("%w: amount_off must be greater than 0", domain.ErrInvalidCoupon)
+		}
+	case models.CouponKindBuyXGetY:
+		if c.ProductID == nil {
+			return fmt.Errorf("%w: product_id is required for buy_x_get_y", domain.ErrInvalidCoupon)
+		}
+		if c.BuyQuantity <= 0 || c.GetQuantity <= 0 {
+			return fmt.Errorf("%w: buy_quantity and get_quantity must be greater than 0", domain.ErrInvalidCoupon)
+		}
+	default:
+		return fmt.Errorf("%w: unknown kind %q", domain.ErrInvalidCoupon, c.Kind)
+	}
+	if c.MinOrderAmount < 0 || c.MaxUses < 0 || c.MaxUsesPerUser < 0 {
+		return fmt.Errorf("%w: amounts and limits must not be negative", domain.ErrInvalidCoupon)
+	}
+	if c.StartsAt != nil && c.EndsAt != nil && !c.EndsAt.After(*c.StartsAt) {
+		return fmt.Errorf("%w: ends_at must be after starts_at", domain.ErrInvalidCoupon)
+	}
+	return nil
+}
+
+// CheckActive reports whether c is enabled and inside its validity window.
+func CheckActive(c *models.Coupon, now time.Time) error {
+	if !c.Active {
+		return domain.ErrCouponInactive
+	}
+	if c.StartsAt != nil && now.Before(*c.StartsAt) {
+		return fmt.Errorf("%w: valid from %s", domain.ErrCouponInactive, c.StartsAt.Format(time.RFC3339))
+	}
+	if c.EndsAt != nil && !now.Before(*c.EndsAt) {
+		return fmt.Errorf("%w: expired at %s", domain.ErrCouponInactive, c.EndsAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// Discounts validates c against an order described by in and returns the
+// discounts it grants. Coupon amounts are in base and converted at the
+// order's snapshotted exchange rate.
+func Discounts(c *models.Coupon, in pricing.Input, base string, now time.Time) ([]pricing.Discount, error) {
+	if err := CheckActive(c, now); err != nil {
+		return nil, err
+	}
+
+	var subtotal, productGross, productQty, productUnit int64
+	for _, l := range in.Lines {
+		subtotal += l.UnitPrice * l.Quantity
+		if c.ProductID != nil && l.ProductID == *c.ProductID {
+			productGross += l.UnitPrice * l.Quantity
+			productQty += l.Quantity
+			if productUnit == 0 || l.UnitPrice < productUnit {
+				productUnit = l.UnitPrice
+			}
+		}
+	}
+
+	if c.MinOrderAmount > 0 {
+		minimum, err := money.Convert(money.New(c.MinOrderAmount, base), in.Currency, in.ExchangeRate)
+		if err != nil {
+			return nil, err
+		}
+		if subtotal < minimum.Amount {
+			return nil, fmt.Errorf("%w: subtotal below minimum of %s", domain.ErrCouponNotApplicable, minimum)
+		}
+	}
+	if c.ProductID != nil && productQty == 0 {
+		return nil, fmt.Errorf("%w: product %d is not in the order", domain.ErrCouponNotApplicable, *c.ProductID)
+	}
+
+	d := pricing.Discount{Code: c.Code, Description: c.Description}
+	if c.ProductID != nil {
+		d.ProductID = *c.ProductID
+	}
+
+	switch c.Kind {
+	case models.CouponKindPercentage:
+		target := subtotal
+		if c.ProductID != nil {
+			target = productGross
+		}
+		off, err := money.MulRate(money.New(target, in.Currency), fmt.Sprintf("%d/100", c.PercentOff))
+		if err != nil {
+			return nil, err
+		}
+		d.Amount = off.Amount
+
+	case models.CouponKindFixed:
+		off, err := money.Convert(money.New(c.AmountOff, base), in.Currency, in.ExchangeRate)
+		if err != nil {
+			return nil, err
+		}
+		d.Amount = off.Amount
+
+	case models.CouponKindBuyXGetY:
+		free := productQty / (c.BuyQuantity + c.GetQuantity) * c.GetQuantity
+		if free == 0 {
+			return nil, fmt.Errorf(
+				"%w: buy %d to get %d free",
+				domain.ErrCouponNotApplicable, c.BuyQuantity, c.GetQuantity,
+			)
+		}
+		d.Amount = free * productUnit
+
+	default:
+		return nil, fmt.Errorf("%w: unknown kind %q", domain.ErrCouponNotApplicable, c.Kind)
+	}
+
+	return []pricing.Discount{d}, nil
+}
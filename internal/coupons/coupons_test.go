@@ -0,0 +1,82 @@
+package coupons
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hitanshu0729/order_go/internal/domain"
+	"github.com/hitanshu0729/order_go/internal/models"
+	"github.com/hitanshu0729/order_go/internal/pricing"
+)
+
+func order(lines ...pricing.Line) pricing.Input {
+	return pricing.Input{Currency: "INR", ExchangeRate: "1", Lines: lines}
+}
+
+func TestDiscounts(t *testing.T) {
+	productID := int64(7)
+	now := time.Now()
+	tests := []struct {
+		name   string
+		coupon models.Coupon
+		in     pricing.Input
+		want   pricing.Discount
+	}{
+		{
+			name:   "percentage off order",
+			coupon: models.Coupon{Code: "TEN", Kind: models.CouponKindPercentage, PercentOff: 10, Active: true},
+			in:     order(pricing.Line{ProductID: 1, Quantity: 3, UnitPrice: 999}),
+			want:   pricing.Discount{Code: "TEN", Amount: 300},
+		},
+		{
+			name:   "fixed off product",
+			coupon: models.Coupon{Code: "FIX", Kind: models.CouponKindFixed, AmountOff: 500, ProductID: &productID, Active: true},
+			in:     order(pricing.Line{ProductID: 7, Quantity: 1, UnitPrice: 2000}),
+			want:   pricing.Discount{Code: "FIX", ProductID: 7, Amount: 500},
+		},
+		{
+			name: "buy two get one",
+			coupon: models.Coupon{
+				Code: "B2G1", Kind: models.CouponKindBuyXGetY, ProductID: &productID,
+				BuyQuantity: 2, GetQuantity: 1, Active: true,
+			},
+			in:   order(pricing.Line{ProductID: 7, Quantity: 7, UnitPrice: 100}),
+			want: pricing.Discount{Code: "B2G1", ProductID: 7, Amount: 200},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Discounts(&tt.coupon, tt.in, "INR", now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 1 || got[0] != tt.want {
+				t.Errorf("Discounts() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiscountsRejects(t *testing.T) {
+	productID := int64(7)
+	past := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name   string
+		coupon models.Coupon
+		want   error
+	}{
+		{"inactive", models.Coupon{Kind: models.CouponKindPercentage, PercentOff: 10}, domain.ErrCouponInactive},
+		{"expired", models.Coupon{Kind: models.CouponKindPercentage, PercentOff: 10, Active: true, EndsAt: &past}, domain.ErrCouponInactive},
+		{"below minimum", models.Coupon{Kind: models.CouponKindFixed, AmountOff: 1, MinOrderAmount: 5000, Active: true}, domain.ErrCouponNotApplicable},
+		{"product missing", models.Coupon{Kind: models.CouponKindFixed, AmountOff: 1, ProductID: &productID, Active: true}, domain.ErrCouponNotApplicable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Discounts(&tt.coupon, order(pricing.Line{ProductID: 1, Quantity: 1, UnitPrice: 1000}), "INR", time.Now())
+			if !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
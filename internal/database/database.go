@@ -1,12 +1,17 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 
+	"github.com/XSAM/otelsql"
 	_ "github.com/joho/godotenv/autoload"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
@@ -39,11 +44,34 @@ func New() Service {
 		return dbInstance
 	}
 
-	db, err := gorm.Open(sqlite.Open("app3.db"), &gorm.Config{})
-	db.Exec("PRAGMA foreign_keys = ON")
-
+	// Open through otelsql so every statement becomes a child span of the
+	// request or consumer span in its context. Statements run outside a
+	// trace, e.g. by the background workers, are not recorded.
+	//
+	// Transactions take the write lock when they begin, so a transaction that
+	// reads an order before updating it cannot interleave with another
+	// writer; contending writers wait up to the busy timeout. Foreign keys
+	// are enforced per connection, so they are enabled in the DSN for every
+	// connection the pool opens.
+	sqlDB, err := otelsql.Open("sqlite3", "app3.db?_txlock=immediate&_busy_timeout=5000&_foreign_keys=on",
+		otelsql.WithAttributes(semconv.DBSystemNameSQLite),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			OmitConnResetSession: true,
+			OmitConnPrepare:      true,
+			OmitRows:             true,
+			SpanFilter: func(ctx context.Context, _ otelsql.Method, _ string, _ []driver.NamedValue) bool {
+				return trace.SpanContextFromContext(ctx).IsValid()
+			},
+		}),
+	)
+	if err != nil {
+		slog.Error("failed to open database", "error", err)
+		os.Exit(1)
+	}
+	db, err := gorm.Open(sqlite.New(sqlite.Config{Conn: sqlDB}), &gorm.Config{})
 	if err != nil {
-		log.Fatal(err)
+		slog.Error("failed to open database", "error", err)
+		os.Exit(1)
 	}
 
 	// Run migrations here
@@ -79,7 +107,7 @@ func (s *service) Health() map[string]string {
 // If the connection is successfully closed, it returns nil.
 // If an error occurs while closing the connection, it returns the error.
 func (s *service) Close() error {
-	log.Printf("Disconnected from database: %s", dburl)
+	slog.Info("disconnected from database", "url", dburl)
 	sqldb, err := s.db.DB()
 	if err != nil {
 		return err
@@ -18,6 +18,41 @@ var (
 
 	// ErrUserNotFound indicates the user does not exist
 	ErrUserNotFound = errors.New("user not found")
+
+	// ErrOrderItemNotFound indicates the order has no such item
+	ErrOrderItemNotFound = errors.New("order item not found")
+
+	// ErrProductPriceNotFound indicates the product has no price in the currency
+	ErrProductPriceNotFound = errors.New("product price not found")
+
+	// ErrAPIKeyNotFound indicates the API key does not exist or is already revoked
+	ErrAPIKeyNotFound = errors.New("api key not found")
+
+	// ErrVariantNotFound indicates the product has no such live variant
+	ErrVariantNotFound = errors.New("product variant not found")
+
+	// ErrVariantRequired indicates an order line for a product with
+	// variants that does not choose one by variant_id
+	ErrVariantRequired = errors.New("variant required")
+
+	// ErrImportNotFound indicates no product import exists with the given id
+	ErrImportNotFound = errors.New("product import not found")
+
+	// ErrJobNotFound indicates no background job exists with the given id
+	ErrJobNotFound = errors.New("job not found")
+
+	// ErrReturnNotFound indicates no return exists with the given id
+	ErrReturnNotFound = errors.New("return not found")
+
+	// ErrPaymentNotFound indicates no payment exists with the given id or
+	// provider reference
+	ErrPaymentNotFound = errors.New("payment not found")
+
+	// ErrShipmentNotFound indicates no shipment exists with the given id
+	ErrShipmentNotFound = errors.New("shipment not found")
+
+	// ErrCategoryNotFound indicates the category does not exist
+	ErrCategoryNotFound = errors.New("category not found")
 )
 
 // Transient errors - retryable
@@ -27,6 +62,10 @@ var (
 
 	// ErrKafkaConnection indicates a temporary Kafka connection issue
 	ErrKafkaConnection = errors.New("kafka connection error")
+
+	// ErrPaymentProvider indicates the payment provider could not be reached
+	// or failed to process a request
+	ErrPaymentProvider = errors.New("payment provider error")
 )
 
 // Business logic errors
@@ -34,9 +73,80 @@ var (
 	// ErrDuplicateEmail indicates the email already exists
 	ErrDuplicateEmail = errors.New("email already exists")
 
+	// ErrDuplicateSKU indicates a product or variant already has the SKU
+	ErrDuplicateSKU = errors.New("sku already exists")
+
+	// ErrDuplicateCategorySlug indicates the category slug already exists
+	ErrDuplicateCategorySlug = errors.New("category slug already exists")
+
 	// ErrInvalidOrderStatus indicates an invalid order status transition
 	ErrInvalidOrderStatus = errors.New("invalid order status")
 
 	// ErrOrderAlreadyProcessed indicates the order event was already processed
 	ErrOrderAlreadyProcessed = errors.New("order already processed")
+
+	// ErrCouponNotFound indicates no coupon exists with the given code or id
+	ErrCouponNotFound = errors.New("coupon not found")
+
+	// ErrInvalidCoupon indicates a coupon definition that fails validation
+	ErrInvalidCoupon = errors.New("invalid coupon")
+
+	// ErrDuplicateCouponCode indicates the coupon code already exists
+	ErrDuplicateCouponCode = errors.New("coupon code already exists")
+
+	// ErrCouponInactive indicates the coupon is disabled or outside its validity window
+	ErrCouponInactive = errors.New("coupon is not active")
+
+	// ErrCouponUsageLimit indicates the global or per-user usage limit is reached
+	ErrCouponUsageLimit = errors.New("coupon usage limit reached")
+
+	// ErrCouponNotApplicable indicates the order does not qualify for the coupon
+	ErrCouponNotApplicable = errors.New("coupon not applicable to order")
+
+	// ErrCouponRedeemed indicates a coupon that has been used cannot be deleted
+	ErrCouponRedeemed = errors.New("coupon has already been redeemed")
+
+	// ErrVersionMismatch indicates the resource changed since the client read
+	// it, so a conditional update was refused
+	ErrVersionMismatch = errors.New("resource has been modified")
+
+	// ErrUnknownJobType indicates no job handler is registered for the type
+	ErrUnknownJobType = errors.New("unknown job type")
+
+	// ErrJobFinished indicates the job already succeeded, failed or was
+	// cancelled
+	ErrJobFinished = errors.New("job already finished")
+
+	// ErrInvalidReturn indicates a return request that does not fit the
+	// order, such as more units than are left to return
+	ErrInvalidReturn = errors.New("invalid return")
+
+	// ErrInvalidReturnStatus indicates an invalid return status transition
+	ErrInvalidReturnStatus = errors.New("invalid return status")
+
+	// ErrInvalidShipment indicates a shipment that does not fit the order,
+	// such as more units than are left to ship
+	ErrInvalidShipment = errors.New("invalid shipment")
+
+	// ErrInvalidShipmentStatus indicates an invalid shipment status
+	// transition
+	ErrInvalidShipmentStatus = errors.New("invalid shipment status")
+
+	// ErrPaymentDeclined indicates the payment provider refused the payment
+	ErrPaymentDeclined = errors.New("payment declined")
+
+	// ErrInvalidPaymentStatus indicates an invalid payment status
+	// transition, or a second payment for an order with one in progress
+	ErrInvalidPaymentStatus = errors.New("invalid payment status")
+
+	// ErrNothingToPay indicates an order whose total is zero, such as one
+	// without items
+	ErrNothingToPay = errors.New("order has nothing to pay")
+
+	// ErrInvalidRefund indicates a refund of more than is left of a payment
+	ErrInvalidRefund = errors.New("invalid refund")
+
+	// ErrInvalidSignature indicates a payment webhook whose signature is
+	// missing, stale or wrong
+	ErrInvalidSignature = errors.New("invalid webhook signature")
 )
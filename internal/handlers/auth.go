@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hitanshu0729/order_go/internal/auth"
+	"github.com/hitanshu0729/order_go/internal/domain"
+	"github.com/hitanshu0729/order_go/internal/models"
+	"github.com/hitanshu0729/order_go/internal/problem"
+	"github.com/hitanshu0729/order_go/internal/storage/sqlite"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// AuthHandler issues access tokens and manages API keys.
+type AuthHandler struct {
+	repo   *sqlite.Repo
+	tokens *auth.TokenService
+}
+
+func NewAuthHandler(repo *sqlite.Repo, tokens *auth.TokenService) *AuthHandler {
+	return &AuthHandler{repo: repo, tokens: tokens}
+}
+
+// RegisterAuthRoutes registers /auth and the per-user API key routes.
+func (h *AuthHandler) RegisterAuthRoutes(rg *gin.RouterGroup) {
+	a := rg.Group("/auth")
+	a.POST("/login", h.Login)
+	a.POST("/token", h.Token)
+	a.GET("/me", h.Me)
+
+	keys := rg.Group("/users/:id/api-keys")
+	keys.GET("", h.GetAPIKeys)
+	keys.POST("", h.CreateAPIKey)
+	keys.DELETE("/:key_id", h.RevokeAPIKey)
+}
+
+// dummyHash is compared against when the email is unknown so that login
+// takes the same time whether or not the account exists.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("order_go"), bcrypt.DefaultCost)
+
+// hashPassword bcrypt-hashes a password for storage.
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+type LoginRequest struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+}
+
+func (h *AuthHandler) Login(c *gin.Context) {
+	var req LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	user, err := h.repo.GetUserByEmail(c.Request.Context(), req.Email)
+	if err != nil && !errors.Is(err, domain.ErrUserNotFound) {
+		c.Error(err)
+		return
+	}
+	if user == nil || user.PasswordHash == "" {
+		_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(req.Password))
+		c.Error(errInvalidLogin)
+		return
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)) != nil {
+		c.Error(errInvalidLogin)
+		return
+	}
+
+	h.issue(c, user)
+}
+
+var errInvalidLogin = problem.Unauthorized("invalid email or password")
+
+// Token issues a fresh access token for the authenticated caller, which
+// lets API key holders exchange their key for a short-lived JWT.
+func (h *AuthHandler) Token(c *gin.Context) {
+	p, _ := auth.PrincipalFrom(c)
+	user, err := h.repo.GetUserByID(c.Request.Context(), p.UserID)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			err = problem.Unauthorized("invalid credentials")
+		}
+		c.Error(err)
+		return
+	}
+	h.issue(c, user)
+}
+
+func (h *AuthHandler) issue(c *gin.Context, user *models.User) {
+	token, expiresAt, err := h.tokens.Issue(user)
+	if err != nil {
+		c.Error(fmt.Errorf("issue token for user id=%d: %w", user.ID, err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_in":   int64(time.Until(expiresAt).Seconds()),
+	})
+}
+
+func (h *AuthHandler) Me(c *gin.Context) {
+	p, _ := auth.PrincipalFrom(c)
+	c.JSON(http.StatusOK, p)
+}
+
+// keyOwner parses :id and checks that the caller may manage that account's
+// keys: their own, or anyone's for admins.
+func keyOwner(c *gin.Context) (int64, bool) {
+	id, ok := pathID(c, "id", "user")
+	if !ok {
+		return 0, false
+	}
+	if !principal(c).CanManageUser(id) {
+		c.Error(problem.Forbidden("cannot manage another user's api keys"))
+		return 0, false
+	}
+	return id, true
+}
+
+func (h *AuthHandler) GetAPIKeys(c *gin.Context) {
+	userID, ok := keyOwner(c)
+	if !ok {
+		return
+	}
+	keys, err := h.repo.GetAPIKeys(c.Request.Context(), userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// CreateAPIKey generates a key for the caller. The plaintext key is only
+// returned in this response.
+func (h *AuthHandler) CreateAPIKey(c *gin.Context) {
+	userID, ok := keyOwner(c)
+	if !ok {
+		return
+	}
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		c.Error(problem.Unprocessable("expiry_in_past", "expires_at must be in the future"))
+		return
+	}
+
+	key, prefix, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	k := &models.APIKey{
+		UserID:    userID,
+		Name:      strings.TrimSpace(req.Name),
+		Prefix:    prefix,
+		KeyHash:   hash,
+		ExpiresAt: req.ExpiresAt,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := h.repo.CreateAPIKey(c.Request.Context(), k); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"api_key": k, "key": key})
+}
+
+func (h *AuthHandler) RevokeAPIKey(c *gin.Context) {
+	userID, ok := keyOwner(c)
+	if !ok {
+		return
+	}
+	keyID, ok := pathID(c, "key_id", "api key")
+	if !ok {
+		return
+	}
+
+	if err := h.repo.RevokeAPIKey(c.Request.Context(), userID, keyID); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "api key revoked"})
+}
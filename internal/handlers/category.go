@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"net/http"
+	"regexp"
+
+	"github.com/hitanshu0729/order_go/internal/auth"
+	"github.com/hitanshu0729/order_go/internal/models"
+	"github.com/hitanshu0729/order_go/internal/problem"
+	"github.com/hitanshu0729/order_go/internal/storage/sqlite"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CategoryHandler struct {
+	categories *sqlite.Repo
+}
+
+func NewCategoryHandler(categories *sqlite.Repo) *CategoryHandler {
+	return &CategoryHandler{categories: categories}
+}
+
+// RegisterCategoryRoutes registers category routes under the given router group.
+func (h *CategoryHandler) RegisterCategoryRoutes(rg *gin.RouterGroup) {
+	categories := rg.Group("/categories")
+	categories.GET("", h.GetCategories)
+	categories.POST("", auth.RequireRole(models.RoleAdmin), h.CreateCategory)
+	categories.GET("/:id", h.GetCategoryByID)
+}
+
+type CreateCategoryRequest struct {
+	Name     string `json:"name" binding:"required,max=100"`
+	Slug     string `json:"slug" binding:"required,max=100"`
+	ParentID *int64 `json:"parent_id"`
+}
+
+// categorySlug keeps slugs usable in ?category= filters and URLs.
+var categorySlug = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+func (h *CategoryHandler) GetCategories(c *gin.Context) {
+	categories, err := h.categories.GetCategories(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, categories)
+}
+
+func (h *CategoryHandler) GetCategoryByID(c *gin.Context) {
+	id, ok := pathID(c, "id", "category")
+	if !ok {
+		return
+	}
+	category, err := h.categories.GetCategoryByID(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, category)
+}
+
+func (h *CategoryHandler) CreateCategory(c *gin.Context) {
+	var req CreateCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	if !categorySlug.MatchString(req.Slug) {
+		c.Error(problem.Unprocessable("invalid_slug", "slug must be lowercase letters and digits separated by single dashes"))
+		return
+	}
+	category := &models.Category{Name: req.Name, Slug: req.Slug, ParentID: req.ParentID}
+	if err := h.categories.CreateCategory(c.Request.Context(), category); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, category)
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hitanshu0729/order_go/internal/metrics"
+	"github.com/hitanshu0729/order_go/internal/models"
+	"github.com/hitanshu0729/order_go/internal/storage/sqlite"
+)
+
+type CheckoutRequest struct {
+	UserID          int64          `json:"user_id"` // defaults to the caller; only staff may order for others
+	Currency        string         `json:"currency" binding:"omitempty,len=3,uppercase"`
+	TaxJurisdiction string         `json:"tax_jurisdiction"`
+	CouponCode      string         `json:"coupon_code"`
+	ShippingAddress models.Address `json:"shipping_address" binding:"required"`
+	Items           []CartItem     `json:"items" binding:"required,min=1,max=100,dive"`
+}
+
+// CartItem is a line of a checkout or of a cart replacing an order's items.
+// A product with variants must be ordered by variant_id, and the line is
+// labelled with the variant's SKU; a free-form variant label is only
+// accepted for products without variants.
+type CartItem struct {
+	ProductID int64  `json:"product_id" binding:"required"`
+	VariantID *int64 `json:"variant_id"`
+	Quantity  int64  `json:"quantity" binding:"required,gt=0"`
+	Variant   string `json:"variant" binding:"max=100"`
+	Note      string `json:"note" binding:"max=500"`
+}
+
+// mergeCartItems folds repeated product and variant_id pairs into one
+// line, adding their quantities and keeping the last non-empty variant
+// label and note.
+func mergeCartItems(items []CartItem) []CartItem {
+	type key struct {
+		product   int64
+		variantID int64
+	}
+	var merged []CartItem
+	index := map[key]int{}
+	for _, item := range items {
+		k := key{product: item.ProductID}
+		if item.VariantID != nil {
+			k.variantID = *item.VariantID
+		}
+		i, ok := index[k]
+		if !ok {
+			index[k] = len(merged)
+			merged = append(merged, item)
+			continue
+		}
+		merged[i].Quantity += item.Quantity
+		if item.Variant != "" {
+			merged[i].Variant = item.Variant
+		}
+		if item.Note != "" {
+			merged[i].Note = item.Note
+		}
+	}
+	return merged
+}
+
+// Checkout creates a complete, priced order from a cart in one step. Either
+// the order, its items, coupon and order.created event are all stored or
+// nothing is.
+func (h *OrderHandler) Checkout(c *gin.Context) {
+	var req CheckoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	order, ok := h.newOrder(c, req.UserID, req.Currency, req.TaxJurisdiction)
+	if !ok {
+		return
+	}
+	order.ShippingAddress = &req.ShippingAddress
+
+	var lines []sqlite.CheckoutLine
+	for _, item := range mergeCartItems(req.Items) {
+		lines = append(lines, sqlite.CheckoutLine{
+			ProductID: item.ProductID,
+			VariantID: item.VariantID,
+			Quantity:  item.Quantity,
+			Variant:   item.Variant,
+			Note:      item.Note,
+		})
+	}
+
+	ctx := c.Request.Context()
+	order, err := h.orders.Checkout(ctx, order, lines, req.CouponCode, h.unitPrice)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	slog.InfoContext(ctx, "order checked out",
+		"order_id", order.ID, "user_id", order.UserID, "items", len(order.Items),
+		"total", order.TotalAmount, "currency", order.Currency,
+	)
+	metrics.OrderCreated()
+
+	setETag(c, order.Version)
+	c.JSON(http.StatusCreated, order)
+}
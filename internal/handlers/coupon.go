@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/hitanshu0729/order_go/internal/auth"
+	"github.com/hitanshu0729/order_go/internal/coupons"
+	"github.com/hitanshu0729/order_go/internal/models"
+	"github.com/hitanshu0729/order_go/internal/storage/sqlite"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CouponHandler struct {
+	coupons *sqlite.Repo
+}
+
+func NewCouponHandler(coupons *sqlite.Repo) *CouponHandler {
+	return &CouponHandler{coupons: coupons}
+}
+
+// RegisterCouponRoutes registers coupon routes under the given router group.
+func (h *CouponHandler) RegisterCouponRoutes(rg *gin.RouterGroup) {
+	coupons := rg.Group("/coupons", auth.RequireRole(models.RoleAdmin))
+	coupons.GET("", h.GetCoupons)
+	coupons.POST("", h.CreateCoupon)
+	coupons.GET("/:id", h.GetCouponByID)
+	coupons.PATCH("/:id", h.UpdateCoupon)
+	coupons.DELETE("/:id", h.DeleteCoupon)
+}
+
+type CouponRequest struct {
+	Code           string     `json:"code" binding:"required"`
+	Description    string     `json:"description"`
+	Kind           string     `json:"kind" binding:"required,oneof=percentage fixed buy_x_get_y"`
+	PercentOff     int64      `json:"percent_off"`
+	AmountOff      int64      `json:"amount_off"`
+	ProductID      *int64     `json:"product_id"`
+	BuyQuantity    int64      `json:"buy_quantity"`
+	GetQuantity    int64      `json:"get_quantity"`
+	MinOrderAmount int64      `json:"min_order_amount"`
+	MaxUses        int64      `json:"max_uses"`
+	MaxUsesPerUser int64      `json:"max_uses_per_user"`
+	StartsAt       *time.Time `json:"starts_at"`
+	EndsAt         *time.Time `json:"ends_at"`
+	Active         *bool      `json:"active"`
+}
+
+// coupon converts the request into a validated coupon definition.
+func (req CouponRequest) coupon() (*models.Coupon, error) {
+	c := &models.Coupon{
+		Code:           coupons.NormalizeCode(req.Code),
+		Description:    req.Description,
+		Kind:           req.Kind,
+		PercentOff:     req.PercentOff,
+		AmountOff:      req.AmountOff,
+		ProductID:      req.ProductID,
+		BuyQuantity:    req.BuyQuantity,
+		GetQuantity:    req.GetQuantity,
+		MinOrderAmount: req.MinOrderAmount,
+		MaxUses:        req.MaxUses,
+		MaxUsesPerUser: req.MaxUsesPerUser,
+		StartsAt:       req.StartsAt,
+		EndsAt:         req.EndsAt,
+		Active:         req.Active == nil || *req.Active,
+	}
+	return c, coupons.Validate(c)
+}
+
+func (h *CouponHandler) GetCoupons(c *gin.Context) {
+	list, err := h.coupons.GetCoupons(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+func (h *CouponHandler) CreateCoupon(c *gin.Context) {
+	var req CouponRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	coupon, err := req.coupon()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if err := h.coupons.CreateCoupon(c.Request.Context(), coupon); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Coupon created", "coupon_id": coupon.ID})
+}
+
+func (h *CouponHandler) GetCouponByID(c *gin.Context) {
+	id, ok := pathID(c, "id", "coupon")
+	if !ok {
+		return
+	}
+	coupon, err := h.coupons.GetCouponByID(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, coupon)
+}
+
+func (h *CouponHandler) UpdateCoupon(c *gin.Context) {
+	id, ok := pathID(c, "id", "coupon")
+	if !ok {
+		return
+	}
+	var req CouponRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	coupon, err := req.coupon()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	coupon.ID = id
+	if err := h.coupons.UpdateCoupon(c.Request.Context(), coupon); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "coupon updated"})
+}
+
+func (h *CouponHandler) DeleteCoupon(c *gin.Context) {
+	id, ok := pathID(c, "id", "coupon")
+	if !ok {
+		return
+	}
+	if err := h.coupons.DeleteCoupon(c.Request.Context(), id); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "coupon deleted"})
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hitanshu0729/order_go/internal/domain"
+	"github.com/hitanshu0729/order_go/internal/problem"
+)
+
+// Orders, products and users carry a version that every update increments.
+// It is served as the resource's ETag, and updates that send it back in
+// If-Match only apply if nobody else has changed the resource since.
+
+// setETag sets the ETag of a resource at version.
+func setETag(c *gin.Context, version int64) {
+	c.Header("ETag", `"`+strconv.FormatInt(version, 10)+`"`)
+}
+
+// ifMatch returns the version named by the If-Match header, or 0 for "*".
+// Every update of an existing resource requires the header; a missing one
+// is a 428.
+func ifMatch(c *gin.Context) (int64, bool) {
+	h := strings.TrimSpace(c.GetHeader("If-Match"))
+	switch {
+	case h == "":
+		c.Error(problem.New(http.StatusPreconditionRequired, "precondition_required",
+			"If-Match header with the resource's ETag is required"))
+		return 0, false
+	case h == "*":
+		return 0, true
+	case strings.HasPrefix(h, "W/"):
+		c.Error(fmt.Errorf("%w: weak entity tags never match", domain.ErrVersionMismatch))
+		return 0, false
+	}
+	version, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(h, `"`), `"`), 10, 64)
+	if err != nil || version < 1 || len(h) < 3 || h[0] != '"' || h[len(h)-1] != '"' {
+		c.Error(problem.BadRequest("invalid_if_match", `If-Match must be a single ETag such as "3"`))
+		return 0, false
+	}
+	return version, true
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hitanshu0729/order_go/internal/auth"
+	"github.com/hitanshu0729/order_go/internal/domain"
+	"github.com/hitanshu0729/order_go/internal/inventory"
+	"github.com/hitanshu0729/order_go/internal/models"
+	"github.com/hitanshu0729/order_go/internal/storage/sqlite"
+
+	"github.com/gin-gonic/gin"
+)
+
+type InventoryHandler struct {
+	repo       *sqlite.Repo
+	reconciler *inventory.Reconciler
+}
+
+func NewInventoryHandler(repo *sqlite.Repo, reconciler *inventory.Reconciler) *InventoryHandler {
+	return &InventoryHandler{repo: repo, reconciler: reconciler}
+}
+
+// RegisterInventoryRoutes registers stock ledger routes under the given router group.
+func (h *InventoryHandler) RegisterInventoryRoutes(rg *gin.RouterGroup) {
+	staff := auth.RequireRole(models.RoleStaff)
+
+	products := rg.Group("/products")
+	products.GET("/:id/movements", staff, h.GetProductMovements)
+	products.POST("/:id/movements", auth.RequireRole(models.RoleAdmin), h.CreateProductMovement)
+
+	rg.GET("/inventory/reconciliation", staff, h.GetReconciliation)
+}
+
+// CreateProductMovementRequest adjusts a product's stock, or one of its
+// variants' when VariantID is set.
+type CreateProductMovementRequest struct {
+	VariantID *int64 `json:"variant_id"`
+	Quantity  int64  `json:"quantity" binding:"required"`
+	Reason    string `json:"reason" binding:"required,oneof=restock adjustment"`
+	Note      string `json:"note"`
+}
+
+func (h *InventoryHandler) GetProductMovements(c *gin.Context) {
+	id, ok := pathID(c, "id", "product")
+	if !ok {
+		return
+	}
+	if _, err := h.repo.GetProductByID(c.Request.Context(), id); err != nil {
+		c.Error(err)
+		return
+	}
+	movements, err := h.repo.GetProductMovements(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, movements)
+}
+
+func (h *InventoryHandler) CreateProductMovement(c *gin.Context) {
+	id, ok := pathID(c, "id", "product")
+	if !ok {
+		return
+	}
+	var req CreateProductMovementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	var err error
+	if req.VariantID != nil {
+		err = h.repo.AdjustVariantStock(c.Request.Context(), id, *req.VariantID, req.Quantity, req.Reason, req.Note)
+	} else {
+		err = h.repo.AdjustProductStock(c.Request.Context(), id, req.Quantity, req.Reason, req.Note)
+	}
+	if errors.Is(err, domain.ErrInsufficientStock) {
+		c.Error(fmt.Errorf("%w: stock cannot go below zero", err))
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "stock movement recorded"})
+}
+
+func (h *InventoryHandler) GetReconciliation(c *gin.Context) {
+	drifts, err := h.reconciler.Run(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"checked_at": time.Now().UTC(),
+		"consistent": len(drifts) == 0,
+		"drift":      drifts,
+	})
+}
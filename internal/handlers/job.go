@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hitanshu0729/order_go/internal/auth"
+	"github.com/hitanshu0729/order_go/internal/jobs"
+	"github.com/hitanshu0729/order_go/internal/models"
+)
+
+type JobHandler struct {
+	jobs *jobs.Runner
+}
+
+func NewJobHandler(runner *jobs.Runner) *JobHandler {
+	return &JobHandler{jobs: runner}
+}
+
+// RegisterJobRoutes registers background job routes under the given router
+// group.
+func (h *JobHandler) RegisterJobRoutes(rg *gin.RouterGroup) {
+	jobs := rg.Group("/jobs", auth.RequireRole(models.RoleAdmin))
+	jobs.POST("", h.CreateJob)
+	jobs.GET("/:id", h.GetJob)
+	jobs.POST("/:id/cancel", h.CancelJob)
+}
+
+// CreateJobRequest queues a job of a registered type. MaxAttempts defaults
+// to jobs.DefaultMaxAttempts.
+type CreateJobRequest struct {
+	Type        string          `json:"type" binding:"required"`
+	Payload     json.RawMessage `json:"payload"`
+	MaxAttempts int             `json:"max_attempts" binding:"omitempty,min=1,max=10"`
+}
+
+// CreateJob queues a job and answers 202 with the job to poll.
+func (h *JobHandler) CreateJob(c *gin.Context) {
+	var req CreateJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	userID := principal(c).UserID
+	job := &models.Job{Type: req.Type, Payload: req.Payload, MaxAttempts: req.MaxAttempts, CreatedBy: &userID}
+	if err := h.jobs.Enqueue(c.Request.Context(), job); err != nil {
+		c.Error(err)
+		return
+	}
+	c.Header("Location", c.FullPath()+"/"+strconv.FormatInt(job.ID, 10))
+	c.JSON(http.StatusAccepted, job)
+}
+
+func (h *JobHandler) GetJob(c *gin.Context) {
+	id, ok := pathID(c, "id", "job")
+	if !ok {
+		return
+	}
+	job, err := h.jobs.Get(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// CancelJob cancels a queued job at once. A running job is stopped by its
+// worker shortly afterwards, so it is answered with 202 while cancellation
+// is pending.
+func (h *JobHandler) CancelJob(c *gin.Context) {
+	id, ok := pathID(c, "id", "job")
+	if !ok {
+		return
+	}
+	job, err := h.jobs.Cancel(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	status := http.StatusOK
+	if !job.Finished() {
+		status = http.StatusAccepted
+	}
+	c.JSON(status, job)
+}
@@ -2,12 +2,22 @@ package handlers
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/hitanshu0729/order_go/internal/auth"
+	"github.com/hitanshu0729/order_go/internal/domain"
 	"github.com/hitanshu0729/order_go/internal/kafka"
+	"github.com/hitanshu0729/order_go/internal/metrics"
+	"github.com/hitanshu0729/order_go/internal/models"
+	"github.com/hitanshu0729/order_go/internal/money"
+	"github.com/hitanshu0729/order_go/internal/payments"
+	"github.com/hitanshu0729/order_go/internal/pricing"
+	"github.com/hitanshu0729/order_go/internal/problem"
 	"github.com/hitanshu0729/order_go/internal/storage/sqlite"
 
 	"github.com/gin-gonic/gin"
@@ -16,89 +26,155 @@ import (
 type OrderHandler struct {
 	orders        *sqlite.Repo
 	kafkaProducer *kafka.Producer
+	rates         *money.Rates
+	pricing       *pricing.Engine
+	payments      *payments.Service
 }
 
-func NewOrderHandler(orders *sqlite.Repo, kafkaProducer *kafka.Producer) *OrderHandler {
-	return &OrderHandler{orders: orders, kafkaProducer: kafkaProducer}
+func NewOrderHandler(orders *sqlite.Repo, kafkaProducer *kafka.Producer, rates *money.Rates, pricing *pricing.Engine, payments *payments.Service) *OrderHandler {
+	return &OrderHandler{orders: orders, kafkaProducer: kafkaProducer, rates: rates, pricing: pricing, payments: payments}
 }
 
+// RegisterOrderRoutes registers order routes. Customers only reach their
+// own orders; deleting, restoring, shipping and arbitrary status changes
+// are staff operations.
 func (h *OrderHandler) RegisterOrderRoutes(rg *gin.RouterGroup) {
+	staff := auth.RequireRole(models.RoleStaff)
+
 	orders := rg.Group("/orders")
 	orders.POST("", h.CreateOrder)
 	orders.GET("", h.GetOrders)
 
 	orders.GET("/:id", h.GetOrderByID)
+	orders.DELETE("/:id", staff, h.DeleteOrder)
+	orders.POST("/:id/restore", staff, h.RestoreOrder)
 	orders.GET("/status/:status", h.GetOrdersByStatus)
 
-	orders.PATCH("/:id/status", h.UpdateOrderStatus)
+	orders.PATCH("/:id/status", staff, h.UpdateOrderStatus)
 	orders.POST("/:id/cancel", h.CancelOrder)
 	orders.POST("/:id/pay", h.PayOrder)
-	orders.POST("/:id/ship", h.ShipOrder)
+	orders.POST("/:id/ship", staff, h.ShipOrder)
+	orders.POST("/:id/coupon", h.ApplyCoupon)
+	orders.DELETE("/:id/coupon", h.RemoveCoupon)
 
-	// ✅ Order Items — properly nested
+	// Order items, addressed by line id.
 	orders.GET("/:id/items", h.GetOrderItems)
 	orders.POST("/:id/items", h.AddOrderItem)
-	orders.PATCH("/:id/items/:item_id", h.UpdateOrderItemQuantity)
+	orders.PUT("/:id/items", h.ReplaceOrderItems)
+	orders.PATCH("/:id/items/:item_id", h.UpdateOrderItem)
 	orders.DELETE("/:id/items/:item_id", h.RemoveOrderItem)
+
+	rg.POST("/checkout", h.Checkout)
 }
 
 type CreateOrderRequest struct {
-	UserID int64 `json:"user_id" binding:"required"`
+	UserID          int64  `json:"user_id"` // defaults to the caller; only staff may order for others
+	Currency        string `json:"currency" binding:"omitempty,len=3,uppercase"`
+	TaxJurisdiction string `json:"tax_jurisdiction"`
 }
 
+// UpdateOrderStatusRequest sets an order's status. Cancelled is the only
+// status that can be set by hand: paid is only reached by capturing a
+// payment, shipping statuses follow from the order's shipments, and moving
+// an order back to pending would drop its payment or reopen a cancellation.
 type UpdateOrderStatusRequest struct {
-	Status string `json:"status" binding:"required,oneof=pending paid cancelled completed"`
+	Status string `json:"status" binding:"required,oneof=cancelled"`
+	Reason string `json:"reason" binding:"max=500"`
+}
+
+// PayOrderRequest is the optional body of a payment. PaymentMethod is the
+// provider's token for the customer's payment method.
+type PayOrderRequest struct {
+	PaymentMethod string `json:"payment_method" binding:"max=100"`
+}
+
+// ShipOrderRequest is the optional body of shipping a whole order.
+type ShipOrderRequest struct {
+	Carrier        string `json:"carrier" binding:"max=100"`
+	TrackingNumber string `json:"tracking_number" binding:"max=100"`
+}
+
+// CancelOrderRequest is the optional body of a cancellation.
+type CancelOrderRequest struct {
+	Reason string `json:"reason" binding:"max=500"`
 }
 
 type AddOrderItemRequest struct {
-	ProductID int64 `json:"product_id" binding:"required"`
-	Quantity  int64 `json:"quantity" binding:"required,gt=0"`
+	ProductID int64  `json:"product_id" binding:"required"`
+	VariantID *int64 `json:"variant_id"`
+	Quantity  int64  `json:"quantity" binding:"required,gt=0"`
+	Variant   string `json:"variant" binding:"max=100"`
+	Note      string `json:"note" binding:"max=500"`
+}
+
+type ReplaceOrderItemsRequest struct {
+	Items []CartItem `json:"items" binding:"required,max=100,dive"`
 }
 
-type UpdateOrderItemQuantityRequest struct {
-	Quantity int64 `json:"quantity" binding:"required,gt=0"`
+type ApplyCouponRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type UpdateOrderItemRequest struct {
+	Quantity int64   `json:"quantity" binding:"required,gt=0"`
+	Note     *string `json:"note" binding:"omitempty,max=500"`
 }
 
 func (h *OrderHandler) CreateOrder(c *gin.Context) {
 	var req CreateOrderRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
-	log.Printf("Creating order: %+v", req)
-	err := h.orders.CreateOrder(c.Request.Context(), req.UserID, "pending", 0)
+	order, ok := h.newOrder(c, req.UserID, req.Currency, req.TaxJurisdiction)
+	if !ok {
+		return
+	}
+	err := h.orders.CreateOrder(c.Request.Context(), order)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
-	log.Printf("Order created successfully: %+v", req)
-	// ✅ AFTER DB commit
-	log.Println("🚀 Publishing order.created event to Kafka")
+	ctx := c.Request.Context()
+	slog.InfoContext(ctx, "order created", "order_id", order.ID, "user_id", order.UserID, "currency", order.Currency)
+	metrics.OrderCreated()
+
+	// Publish after the DB commit. The event outlives the request, so keep
+	// its request id but not its cancellation.
 	err = h.kafkaProducer.Publish(
-		context.Background(),
+		context.WithoutCancel(ctx),
 		"order.created",
 		map[string]any{
-			"user_id": req.UserID,
+			"order_id": order.ID,
+			"user_id":  order.UserID,
+			"currency": order.Currency,
 		},
 	)
 	if err != nil {
-		log.Println("❌ Kafka publish failed:", err)
+		slog.ErrorContext(ctx, "kafka publish failed", "event", "order.created", "order_id", order.ID, "error", err)
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "Order created"})
+	c.JSON(http.StatusCreated, gin.H{"message": "Order created", "order_id": order.ID})
 }
 
 func (h *OrderHandler) GetOrders(c *gin.Context) {
-	var filter sqlite.OrderFilter
+	filter := sqlite.OrderFilter{IncludeDeleted: includeDeleted(c)}
 
 	if userIDStr := c.Query("user_id"); userIDStr != "" {
 		if userID, err := strconv.ParseInt(userIDStr, 10, 64); err == nil {
 			filter.UserID = &userID
 		} else {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+			c.Error(problem.BadRequest("invalid_query", "invalid user_id"))
 			return
 		}
 	}
+	if p := principal(c); !p.IsStaff() {
+		if filter.UserID != nil && *filter.UserID != p.UserID {
+			c.Error(problem.Forbidden("cannot list another user's orders"))
+			return
+		}
+		filter.UserID = &p.UserID
+	}
 	if status := c.Query("status"); status != "" {
 		filter.Status = &status
 	}
@@ -106,7 +182,7 @@ func (h *OrderHandler) GetOrders(c *gin.Context) {
 		if t, err := time.Parse("2006-01-02", from); err == nil {
 			filter.From = &t
 		} else {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from date"})
+			c.Error(problem.BadRequest("invalid_query", "invalid from date"))
 			return
 		}
 	}
@@ -114,255 +190,489 @@ func (h *OrderHandler) GetOrders(c *gin.Context) {
 		if t, err := time.Parse("2006-01-02", to); err == nil {
 			filter.To = &t
 		} else {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to date"})
+			c.Error(problem.BadRequest("invalid_query", "invalid to date"))
 			return
 		}
 	}
 
 	orders, err := h.orders.GetOrdersFiltered(c.Request.Context(), filter)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, orders)
 }
 
+// GetOrderByID returns an order, with its items and a summary of its user
+// when asked for by ?expand=items,user.
 func (h *OrderHandler) GetOrderByID(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order id"})
+	id, ok := pathID(c, "id", "order")
+	if !ok {
 		return
 	}
-	order, err := h.orders.GetOrderByID(c.Request.Context(), id)
-	if err != nil || order == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+	expand, ok := expansions(c, "items", "user")
+	if !ok {
 		return
 	}
+	getOrder := h.orders.GetOrderByID
+	if includeDeleted(c) {
+		getOrder = h.orders.GetOrderByIDUnscoped
+	}
+	order, err := getOrder(c.Request.Context(), id)
+	if err == nil && !principal(c).CanAccessOrder(order) {
+		err = domain.ErrOrderNotFound
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if expand["items"] {
+		if order.Items, err = h.orders.GetOrderItems(ctx, order.ID); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+	if expand["user"] {
+		// The order outlives a deleted user.
+		user, err := h.orders.GetUserByIDUnscoped(ctx, order.UserID)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		order.User = &models.UserSummary{ID: int64(user.ID), Name: user.Name, Email: user.Email}
+	}
+	setETag(c, order.Version)
 	c.JSON(http.StatusOK, order)
 }
 
+func (h *OrderHandler) DeleteOrder(c *gin.Context) {
+	id, ok := pathID(c, "id", "order")
+	if !ok {
+		return
+	}
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+	if err := h.orders.DeleteOrder(c.Request.Context(), id, version); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "order deleted"})
+}
+
+func (h *OrderHandler) RestoreOrder(c *gin.Context) {
+	id, ok := pathID(c, "id", "order")
+	if !ok {
+		return
+	}
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+	if err := h.orders.RestoreOrder(c.Request.Context(), id, version); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "order restored"})
+}
+
 func (h *OrderHandler) GetOrdersByStatus(c *gin.Context) {
 	status := c.Param("status")
-	orders, err := h.orders.GetOrdersByStatus(c.Request.Context(), status)
+	var (
+		orders []*models.Order
+		err    error
+	)
+	if p := principal(c); p.IsStaff() {
+		orders, err = h.orders.GetOrdersByStatus(c.Request.Context(), status)
+	} else {
+		orders, err = h.orders.GetOrdersFiltered(c.Request.Context(), sqlite.OrderFilter{UserID: &p.UserID, Status: &status})
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, orders)
 }
 
+// UpdateOrderStatus is the staff route for cancelling an order; see
+// UpdateOrderStatusRequest. Staff move orders to the other statuses
+// through payments and shipments.
 func (h *OrderHandler) UpdateOrderStatus(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order id"})
+	id, ok := pathID(c, "id", "order")
+	if !ok {
 		return
 	}
-	var req UpdateOrderStatusRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	version, ok := ifMatch(c)
+	if !ok {
 		return
 	}
-	err = h.orders.UpdateOrderStatus(c.Request.Context(), id, req.Status)
+	var req UpdateOrderStatusRequest
+	err := c.ShouldBindJSON(&req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update order status"})
+		c.Error(err)
+		return
+	}
+	// Cancelling has side effects, whichever route it comes through.
+	if err := h.payments.CancelOrder(c.Request.Context(), id, version, req.Reason); err != nil {
+		c.Error(err)
 		return
 	}
+	metrics.OrderCancelled()
 	c.JSON(http.StatusOK, gin.H{"message": "order status updated"})
 }
 
+// CancelOrder cancels an order, with an optional reason in the body. A paid
+// order's payment is refunded, and the stock taken when it was paid is
+// restored by the inventory consumer on the order.cancelled event.
 func (h *OrderHandler) CancelOrder(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order id"})
+	order, version, ok := h.accessibleOrderVersion(c)
+	if !ok {
 		return
 	}
-	order, err := h.orders.GetOrderByID(c.Request.Context(), id)
-	if err != nil || order == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
-		return
-	}
-	if order.Status == "completed" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot cancel an order that is already shipped (completed)"})
-		return
+	var req CancelOrderRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Error(err)
+			return
+		}
 	}
-	err = h.orders.UpdateOrderStatus(c.Request.Context(), id, "cancelled")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update order status"})
+	if err := h.payments.CancelOrder(c.Request.Context(), order.ID, version, req.Reason); err != nil {
+		c.Error(err)
 		return
 	}
+	metrics.OrderCancelled()
 	c.JSON(http.StatusOK, gin.H{"message": "order status updated", "status": "cancelled"})
 }
 
+// PayOrder charges the order's grand total through the payment provider.
+// The order is paid once the payment is captured; a payment the customer
+// still has to complete with the provider is answered with 202 and pays
+// the order when the provider's webhook reports it captured.
 func (h *OrderHandler) PayOrder(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order id"})
+	order, version, ok := h.accessibleOrderVersion(c)
+	if !ok {
 		return
 	}
-	order, err := h.orders.GetOrderByID(c.Request.Context(), id)
-	if err != nil || order == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
-		return
+	var req PayOrderRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Error(err)
+			return
+		}
 	}
-	if order.Status != "pending" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "order can only be paid if status is 'pending'"})
+	payment, err := h.payments.Pay(c.Request.Context(), order.ID, version, req.PaymentMethod)
+	if errors.Is(err, domain.ErrCouponInactive) || errors.Is(err, domain.ErrCouponUsageLimit) {
+		c.Error(fmt.Errorf("%w, remove the coupon to continue", err))
 		return
 	}
-	err = h.orders.UpdateOrderStatus(c.Request.Context(), id, "paid")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update order status"})
+		c.Error(err)
 		return
 	}
-	go h.kafkaProducer.Publish(
-		context.Background(),
-		"order.paid",
-		map[string]any{
-			"order_id": id,
-		},
-	)
-	c.JSON(http.StatusOK, gin.H{"message": "order status updated", "status": "paid"})
+	if payment.Status != models.PaymentStatusCaptured {
+		c.JSON(http.StatusAccepted, gin.H{"message": "payment pending", "status": "pending", "payment": payment})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "order status updated", "status": "paid", "payment": payment})
 }
 
+// ShipOrder ships every unit of the order not yet in a shipment in one
+// shipment. Partial shipments are made through the shipment routes.
 func (h *OrderHandler) ShipOrder(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
+	order, version, ok := h.accessibleOrderVersion(c)
+	if !ok {
+		return
+	}
+	var req ShipOrderRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+	ctx := c.Request.Context()
+	shipment, err := h.orders.ShipOrder(ctx, order.ID, version, req.Carrier, req.TrackingNumber)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order id"})
+		c.Error(err)
 		return
 	}
-	order, err := h.orders.GetOrderByID(c.Request.Context(), id)
-	if err != nil || order == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+	if order, err = h.orders.GetOrderByID(ctx, order.ID); err != nil {
+		c.Error(err)
 		return
 	}
-	if order.Status != "paid" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "order can only be shipped if status is 'paid'"})
+	c.JSON(http.StatusOK, gin.H{"message": "order status updated", "status": order.Status, "shipment": shipment})
+}
+
+func (h *OrderHandler) ApplyCoupon(c *gin.Context) {
+	order, version, ok := h.accessibleOrderVersion(c)
+	if !ok {
 		return
 	}
-	err = h.orders.UpdateOrderStatus(c.Request.Context(), id, "completed")
+	var req ApplyCouponRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	if err := h.orders.ApplyCoupon(c.Request.Context(), order.ID, req.Code, version); err != nil {
+		c.Error(err)
+		return
+	}
+	order, err := h.orders.GetOrderByID(c.Request.Context(), order.ID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update order status"})
+		c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "order status updated", "status": "completed"})
+	setETag(c, order.Version)
+	c.JSON(http.StatusOK, order)
+}
+
+func (h *OrderHandler) RemoveCoupon(c *gin.Context) {
+	order, version, ok := h.accessibleOrderVersion(c)
+	if !ok {
+		return
+	}
+	if err := h.orders.RemoveCoupon(c.Request.Context(), order.ID, version); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "coupon removed"})
 }
 
 func (h *OrderHandler) GetOrderItems(c *gin.Context) {
-	orderID, err := strconv.ParseInt(c.Param("id"), 10, 64) // <-- use "id"
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order id"})
+	order, ok := h.accessibleOrder(c)
+	if !ok {
 		return
 	}
-	items, err := h.orders.GetOrderItems(c.Request.Context(), orderID)
+	items, err := h.orders.GetOrderItems(c.Request.Context(), order.ID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, items)
 }
 
 func (h *OrderHandler) AddOrderItem(c *gin.Context) {
-	orderID, err := strconv.ParseInt(c.Param("id"), 10, 64) // <-- use "id"
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order id"})
+	order, version, ok := h.accessibleOrderVersion(c)
+	if !ok {
 		return
 	}
 	var req AddOrderItemRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
-	product, err := h.orders.GetProductByID(c.Request.Context(), req.ProductID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	item := &models.OrderItem{
+		OrderID:   order.ID,
+		ProductID: req.ProductID,
+		VariantID: req.VariantID,
+		Quantity:  req.Quantity,
+		Variant:   req.Variant,
+		Note:      req.Note,
 	}
-	if product == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
+	if err := h.priceItem(c.Request.Context(), order, item); err != nil {
+		c.Error(err)
 		return
 	}
-	err = h.orders.AddOrderItem(c.Request.Context(), orderID, req.ProductID, req.Quantity, product.Price)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := h.orders.AddOrderItem(c.Request.Context(), item, version); err != nil {
+		c.Error(err)
 		return
 	}
-	c.JSON(http.StatusCreated, gin.H{"message": "item added"})
+	c.JSON(http.StatusCreated, gin.H{"message": "item added", "item": item})
 }
 
-func (h *OrderHandler) UpdateOrderItemQuantity(c *gin.Context) {
-	orderID, err := strconv.ParseInt(c.Param("id"), 10, 64) // <-- use "id"
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order id"})
+// ReplaceOrderItems replaces the whole cart of a pending order and returns
+// the repriced order with its items.
+func (h *OrderHandler) ReplaceOrderItems(c *gin.Context) {
+	order, version, ok := h.accessibleOrderVersion(c)
+	if !ok {
 		return
 	}
-	itemID, err := strconv.ParseInt(c.Param("item_id"), 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid item id"})
+	var req ReplaceOrderItemsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
 		return
 	}
-	var req UpdateOrderItemQuantityRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	ctx := c.Request.Context()
+
+	var items []*models.OrderItem
+	for _, line := range mergeCartItems(req.Items) {
+		item := &models.OrderItem{
+			ProductID: line.ProductID,
+			VariantID: line.VariantID,
+			Quantity:  line.Quantity,
+			Variant:   line.Variant,
+			Note:      line.Note,
+		}
+		if err := h.priceItem(ctx, order, item); err != nil {
+			c.Error(err)
+			return
+		}
+		items = append(items, item)
+	}
+	if err := h.orders.ReplaceOrderItems(ctx, order.ID, items, version); err != nil {
+		c.Error(err)
 		return
 	}
-	order, err := h.orders.GetOrderByID(c.Request.Context(), orderID)
+
+	order, err := h.orders.GetOrderByID(ctx, order.ID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error fetching order": err.Error()})
+		c.Error(err)
+		return
+	}
+	if order.Items, err = h.orders.GetOrderItems(ctx, order.ID); err != nil {
+		c.Error(err)
 		return
 	}
+	setETag(c, order.Version)
+	c.JSON(http.StatusOK, order)
+}
 
-	if order == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+func (h *OrderHandler) UpdateOrderItem(c *gin.Context) {
+	order, version, ok := h.accessibleOrderVersion(c)
+	if !ok {
 		return
 	}
-	if order.Status != "pending" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "can only update items for orders with status 'pending'"})
+	itemID, ok := pathID(c, "item_id", "item")
+	if !ok {
+		return
+	}
+	var req UpdateOrderItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
 		return
 	}
-	err = h.orders.UpdateOrderItemQuantity(c.Request.Context(), orderID, itemID, req.Quantity)
+	err := h.orders.UpdateOrderItem(c.Request.Context(), order.ID, itemID, req.Quantity, req.Note, version)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error updating item quantity": err.Error()})
+		c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "item quantity updated"})
+	c.JSON(http.StatusOK, gin.H{"message": "item updated"})
 }
 
 func (h *OrderHandler) RemoveOrderItem(c *gin.Context) {
-	orderID, err := strconv.ParseInt(c.Param("id"), 10, 64) // <-- use "id"
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order id"})
+	order, version, ok := h.accessibleOrderVersion(c)
+	if !ok {
 		return
 	}
-
-	order, err := h.orders.GetOrderByID(c.Request.Context(), orderID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error fetching order": err.Error()})
+	itemID, ok := pathID(c, "item_id", "item")
+	if !ok {
 		return
 	}
-
-	if order == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+	if err := h.orders.RemoveOrderItem(c.Request.Context(), order.ID, itemID, version); err != nil {
+		c.Error(err)
 		return
 	}
-	if order.Status != "pending" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "can only update items for orders with status 'pending'"})
-		return
+	c.JSON(http.StatusOK, gin.H{"message": "item removed"})
+}
+
+// newOrder prepares a pending order for userID, defaulting to the caller,
+// in the given currency and tax jurisdiction, defaulting to the base
+// currency and the default jurisdiction. The exchange rate is snapshotted
+// now.
+func (h *OrderHandler) newOrder(c *gin.Context, userID int64, currency, jurisdiction string) (*models.Order, bool) {
+	p := principal(c)
+	if userID == 0 {
+		userID = p.UserID
+	}
+	if !p.CanAccessUser(userID) {
+		c.Error(problem.Forbidden("cannot create orders for another user"))
+		return nil, false
+	}
+	if currency == "" {
+		currency = h.rates.Base
 	}
+	rate, err := h.rates.Rate(currency)
+	if err != nil {
+		c.Error(err)
+		return nil, false
+	}
+	if jurisdiction == "" {
+		jurisdiction = h.pricing.DefaultJurisdiction()
+	}
+	if !h.pricing.HasJurisdiction(jurisdiction) {
+		c.Error(fmt.Errorf("%w: %s", pricing.ErrUnknownJurisdiction, jurisdiction))
+		return nil, false
+	}
+	return &models.Order{
+		UserID:          userID,
+		Status:          "pending",
+		Currency:        currency,
+		ExchangeRate:    rate,
+		TaxJurisdiction: jurisdiction,
+	}, true
+}
 
-	itemID, err := strconv.ParseInt(c.Param("item_id"), 10, 64)
+// accessibleOrder loads the live order named by :id if the caller may act
+// on it. Customers get the same 404 for another user's order as for a
+// missing one.
+func (h *OrderHandler) accessibleOrder(c *gin.Context) (*models.Order, bool) {
+	id, ok := pathID(c, "id", "order")
+	if !ok {
+		return nil, false
+	}
+	order, err := h.orders.GetOrderByID(c.Request.Context(), id)
+	if err == nil && !principal(c).CanAccessOrder(order) {
+		err = domain.ErrOrderNotFound
+	}
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid item id"})
-		return
+		c.Error(err)
+		return nil, false
+	}
+	return order, true
+}
+
+// accessibleOrderVersion is accessibleOrder for updates: it also returns the
+// version named by If-Match, or 0 for "*".
+func (h *OrderHandler) accessibleOrderVersion(c *gin.Context) (*models.Order, int64, bool) {
+	order, ok := h.accessibleOrder(c)
+	if !ok {
+		return nil, 0, false
 	}
-	err = h.orders.RemoveOrderItem(c.Request.Context(), orderID, itemID)
+	version, ok := ifMatch(c)
+	if !ok {
+		return nil, 0, false
+	}
+	return order, version, true
+}
+
+// priceItem looks up the product, and variant if any, of an order line and
+// sets its price and snapshot. Products with variants must be ordered by
+// variant_id, and their lines are labelled with the variant's SKU.
+func (h *OrderHandler) priceItem(ctx context.Context, order *models.Order, item *models.OrderItem) error {
+	product, label, err := h.orders.GetLineProduct(ctx, item.ProductID, item.VariantID, item.Variant)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error removing item": err.Error()})
-		return
+		return err
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "item removed"})
+	item.Variant = label
+	if item.Price, err = h.unitPrice(product, order); err != nil {
+		return err
+	}
+	item.Product = product.Snapshot()
+	return nil
 }
 
+// unitPrice resolves a product's price in the order's currency. An explicit
+// product price wins; otherwise the base price is converted at the rate
+// snapshotted on the order, never the current one.
+func (h *OrderHandler) unitPrice(product *models.Product, order *models.Order) (int64, error) {
+	if order.Currency == h.rates.Base {
+		return product.Price, nil
+	}
+	for _, p := range product.Prices {
+		if p.Currency == order.Currency {
+			return p.Amount, nil
+		}
+	}
+	converted, err := money.Convert(money.New(product.Price, h.rates.Base), order.Currency, order.ExchangeRate)
+	if err != nil {
+		return 0, err
+	}
+	if converted.Amount <= 0 {
+		return 0, problem.Unprocessable("price_rounds_to_zero",
+			fmt.Sprintf("price of product %d rounds to zero in %s", product.ID, order.Currency))
+	}
+	return converted.Amount, nil
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hitanshu0729/order_go/internal/auth"
+	"github.com/hitanshu0729/order_go/internal/models"
+	"github.com/hitanshu0729/order_go/internal/orders"
+)
+
+type OrderExpiryHandler struct {
+	expirer *orders.Expirer
+}
+
+func NewOrderExpiryHandler(expirer *orders.Expirer) *OrderExpiryHandler {
+	return &OrderExpiryHandler{expirer: expirer}
+}
+
+// RegisterOrderExpiryRoutes registers the unpaid order expiry route under
+// the given router group.
+func (h *OrderExpiryHandler) RegisterOrderExpiryRoutes(rg *gin.RouterGroup) {
+	rg.GET("/orders/expiry", auth.RequireRole(models.RoleAdmin), h.GetOrderExpiry)
+}
+
+// GetOrderExpiry returns the expiry schedule and its latest runs.
+func (h *OrderExpiryHandler) GetOrderExpiry(c *gin.Context) {
+	schedule, err := h.expirer.Schedule(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, schedule)
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hitanshu0729/order_go/internal/auth"
+	"github.com/hitanshu0729/order_go/internal/domain"
+	"github.com/hitanshu0729/order_go/internal/models"
+	"github.com/hitanshu0729/order_go/internal/payments"
+	"github.com/hitanshu0729/order_go/internal/storage/sqlite"
+)
+
+type PaymentHandler struct {
+	payments *payments.Service
+	orders   *sqlite.Repo
+}
+
+func NewPaymentHandler(service *payments.Service, orders *sqlite.Repo) *PaymentHandler {
+	return &PaymentHandler{payments: service, orders: orders}
+}
+
+// RegisterPaymentRoutes registers payment routes. Orders are paid through
+// POST /orders/:id/pay; the webhook is called by the payment provider and
+// authenticated by its signature instead of a token.
+func (h *PaymentHandler) RegisterPaymentRoutes(rg *gin.RouterGroup) {
+	staff := auth.RequireRole(models.RoleStaff)
+
+	rg.GET("/orders/:id/payments", h.GetOrderPayments)
+
+	payments := rg.Group("/payments")
+	payments.POST("/webhook", h.Webhook)
+	payments.GET("/:id", h.GetPayment)
+	payments.POST("/:id/refund", staff, h.RefundPayment)
+	payments.POST("/:id/void", staff, h.VoidPayment)
+}
+
+// RefundPaymentRequest refunds Amount, in the payment's currency, or all
+// that is left of the payment when it is omitted.
+type RefundPaymentRequest struct {
+	Amount int64 `json:"amount" binding:"omitempty,gt=0"`
+}
+
+func (h *PaymentHandler) GetOrderPayments(c *gin.Context) {
+	id, ok := pathID(c, "id", "order")
+	if !ok {
+		return
+	}
+	order, err := h.orders.GetOrderByID(c.Request.Context(), id)
+	if err == nil && !principal(c).CanAccessOrder(order) {
+		err = domain.ErrOrderNotFound
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	list, err := h.payments.OrderPayments(c.Request.Context(), order.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// GetPayment returns a payment. Customers only see payments of their own
+// orders; others are reported as not found.
+func (h *PaymentHandler) GetPayment(c *gin.Context) {
+	id, ok := pathID(c, "id", "payment")
+	if !ok {
+		return
+	}
+	payment, err := h.payments.Get(c.Request.Context(), id)
+	if err == nil && !principal(c).IsStaff() {
+		order, oerr := h.orders.GetOrderByID(c.Request.Context(), payment.OrderID)
+		if oerr != nil || !principal(c).CanAccessOrder(order) {
+			err = domain.ErrPaymentNotFound
+		}
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, payment)
+}
+
+func (h *PaymentHandler) RefundPayment(c *gin.Context) {
+	id, ok := pathID(c, "id", "payment")
+	if !ok {
+		return
+	}
+	var req RefundPaymentRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+	payment, err := h.payments.Refund(c.Request.Context(), id, req.Amount)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, payment)
+}
+
+// VoidPayment cancels a payment still pending with the provider, so the
+// order can be paid again.
+func (h *PaymentHandler) VoidPayment(c *gin.Context) {
+	id, ok := pathID(c, "id", "payment")
+	if !ok {
+		return
+	}
+	payment, err := h.payments.Void(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, payment)
+}
+
+// Webhook applies a payment provider's notification. The signature covers
+// the raw body, so it is read before any decoding.
+func (h *PaymentHandler) Webhook(c *gin.Context) {
+	body, err := c.GetRawData()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if err := h.payments.HandleWebhook(c.Request.Context(), c.GetHeader(payments.SignatureHeader), body); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"received": true})
+}
@@ -1,39 +1,108 @@
 package handlers
 
 import (
-	"github.com/hitanshu0729/order_go/internal/storage/sqlite"
-	"log"
 	"net/http"
-	"strconv"
+	"strings"
+
+	"github.com/hitanshu0729/order_go/internal/auth"
+	"github.com/hitanshu0729/order_go/internal/catalog"
+	"github.com/hitanshu0729/order_go/internal/models"
+	"github.com/hitanshu0729/order_go/internal/money"
+	"github.com/hitanshu0729/order_go/internal/problem"
+	"github.com/hitanshu0729/order_go/internal/storage/sqlite"
 
 	"github.com/gin-gonic/gin"
 )
 
 type ProductHandler struct {
 	products *sqlite.Repo
+	rates    *money.Rates
 }
 
-func NewProductHandler(products *sqlite.Repo) *ProductHandler {
-	return &ProductHandler{products: products}
+func NewProductHandler(products *sqlite.Repo, rates *money.Rates) *ProductHandler {
+	return &ProductHandler{products: products, rates: rates}
 }
 
 func (h *ProductHandler) RegisterProductRoutes(rg *gin.RouterGroup) {
+	admin := auth.RequireRole(models.RoleAdmin)
+
 	products := rg.Group("/products")
 	products.GET("", h.GetProducts)
-	products.POST("", h.CreateProduct)
+	products.POST("", admin, h.CreateProduct)
 	products.GET(":id", h.GetProductByID)
+	products.PATCH("/:id", admin, h.UpdateProduct)
+	products.DELETE("/:id", admin, h.DeleteProduct)
+	products.POST("/:id/restore", admin, h.RestoreProduct)
+	products.PUT("/:id/prices/:currency", admin, h.SetProductPrice)
+	products.DELETE("/:id/prices/:currency", admin, h.DeleteProductPrice)
+	products.POST("/:id/variants", admin, h.CreateProductVariant)
+	products.DELETE("/:id/variants/:variant_id", admin, h.DeleteProductVariant)
 }
 
 type CreateProductRequest struct {
-	Name  string `json:"name" binding:"required"`
-	Price int64  `json:"price" binding:"required,gt=0"`
-	Stock int64  `json:"stock" binding:"required,gte=0"`
+	SKU         string                        `json:"sku" binding:"max=64"` // generated when empty
+	Name        string                        `json:"name" binding:"required"`
+	Description string                        `json:"description" binding:"max=5000"`
+	CategoryID  *int64                        `json:"category_id"`
+	Attributes  map[string]string             `json:"attributes" binding:"max=50,dive,keys,min=1,max=50,endkeys,max=200"`
+	Price       int64                         `json:"price" binding:"required,gt=0"`
+	Stock       int64                         `json:"stock" binding:"gte=0"` // base stock; variants carry their own
+	Variants    []CreateProductVariantRequest `json:"variants" binding:"max=100,dive"`
 }
 
+// UpdateProductRequest changes the fields present in the body. A
+// category_id of 0 removes the category; attributes replace all existing
+// ones.
+type UpdateProductRequest struct {
+	SKU         *string            `json:"sku" binding:"omitempty,min=1,max=64"`
+	Name        *string            `json:"name" binding:"omitempty,min=1"`
+	Description *string            `json:"description" binding:"omitempty,max=5000"`
+	CategoryID  *int64             `json:"category_id" binding:"omitempty,gte=0"`
+	Attributes  *map[string]string `json:"attributes" binding:"omitempty,max=50,dive,keys,min=1,max=50,endkeys,max=200"`
+	Price       *int64             `json:"price" binding:"omitempty,gt=0"`
+}
+
+type CreateProductVariantRequest struct {
+	SKU        string            `json:"sku" binding:"required,max=64"`
+	Attributes map[string]string `json:"attributes" binding:"max=50,dive,keys,min=1,max=50,endkeys,max=200"`
+	Price      int64             `json:"price" binding:"required,gt=0"`
+	Stock      int64             `json:"stock" binding:"gte=0"`
+}
+
+func (req CreateProductVariantRequest) variant() *models.ProductVariant {
+	return &models.ProductVariant{SKU: req.SKU, Attributes: req.Attributes, Price: req.Price, Stock: req.Stock}
+}
+
+type SetProductPriceRequest struct {
+	Amount int64 `json:"amount" binding:"required,gt=0"`
+}
+
+// GetProducts lists products, optionally narrowed to a category and its
+// subcategories by ?category=<id or slug> and to attribute values by
+// ?attr.<name>=<value>, which match the product or any of its variants.
 func (h *ProductHandler) GetProducts(c *gin.Context) {
-	products, err := h.products.GetProducts(c.Request.Context())
+	filter := sqlite.ProductFilter{
+		IncludeDeleted: includeDeleted(c),
+		Category:       c.Query("category"),
+	}
+	for param, values := range c.Request.URL.Query() {
+		key, ok := strings.CutPrefix(param, "attr.")
+		if !ok {
+			continue
+		}
+		if !catalog.ValidAttributeName(key) {
+			c.Error(problem.BadRequest("invalid_query", "invalid attribute name "+key))
+			return
+		}
+		if filter.Attributes == nil {
+			filter.Attributes = map[string]string{}
+		}
+		filter.Attributes[key] = values[0]
+	}
+
+	products, err := h.products.GetProducts(c.Request.Context(), filter)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, products)
@@ -42,30 +111,212 @@ func (h *ProductHandler) GetProducts(c *gin.Context) {
 func (h *ProductHandler) CreateProduct(c *gin.Context) {
 	var req CreateProductRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(err)
+		return
+	}
+	product := &models.Product{
+		SKU:         req.SKU,
+		Name:        req.Name,
+		Description: req.Description,
+		CategoryID:  req.CategoryID,
+		Attributes:  req.Attributes,
+		Price:       req.Price,
+		Stock:       req.Stock,
+	}
+	for _, v := range req.Variants {
+		product.Variants = append(product.Variants, v.variant())
+	}
+	ctx := c.Request.Context()
+	if err := h.products.CreateProduct(ctx, product); err != nil {
+		c.Error(err)
 		return
 	}
-	log.Printf("Creating product: %+v", req)
-	err := h.products.CreateProduct(c.Request.Context(), req.Name, req.Price, req.Stock)
+	product, err := h.products.GetProductByID(ctx, product.ID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
-	log.Printf("Product created successfully: %+v", req)
-	c.JSON(http.StatusCreated, gin.H{"message": "Product created"})
+	setETag(c, product.Version)
+	c.JSON(http.StatusCreated, gin.H{"message": "Product created", "product": product})
 }
 
-func (h *ProductHandler) GetProductByID(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
+func (h *ProductHandler) UpdateProduct(c *gin.Context) {
+	id, ok := pathID(c, "id", "product")
+	if !ok {
+		return
+	}
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+	var req UpdateProductRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	ctx := c.Request.Context()
+	product, err := h.products.GetProductByID(ctx, id)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product id"})
+		c.Error(err)
 		return
 	}
-	product, err := h.products.GetProductByID(c.Request.Context(), id)
+	if req.SKU != nil {
+		product.SKU = *req.SKU
+	}
+	if req.Name != nil {
+		product.Name = *req.Name
+	}
+	if req.Description != nil {
+		product.Description = *req.Description
+	}
+	if req.CategoryID != nil {
+		product.CategoryID = req.CategoryID
+		if *req.CategoryID == 0 {
+			product.CategoryID = nil
+		}
+	}
+	if req.Attributes != nil {
+		product.Attributes = *req.Attributes
+	}
+	if req.Price != nil {
+		product.Price = *req.Price
+	}
+	if err := h.products.UpdateProduct(ctx, product, version); err != nil {
+		c.Error(err)
+		return
+	}
+	if product, err = h.products.GetProductByID(ctx, id); err != nil {
+		c.Error(err)
+		return
+	}
+	setETag(c, product.Version)
+	c.JSON(http.StatusOK, product)
+}
+
+func (h *ProductHandler) GetProductByID(c *gin.Context) {
+	id, ok := pathID(c, "id", "product")
+	if !ok {
+		return
+	}
+	getProduct := h.products.GetProductByID
+	if includeDeleted(c) {
+		getProduct = h.products.GetProductByIDUnscoped
+	}
+	product, err := getProduct(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
+		c.Error(err)
 		return
 	}
+	setETag(c, product.Version)
 	c.JSON(http.StatusOK, product)
 }
+
+func (h *ProductHandler) DeleteProduct(c *gin.Context) {
+	id, ok := pathID(c, "id", "product")
+	if !ok {
+		return
+	}
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+	if err := h.products.DeleteProduct(c.Request.Context(), id, version); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "product deleted"})
+}
+
+func (h *ProductHandler) RestoreProduct(c *gin.Context) {
+	id, ok := pathID(c, "id", "product")
+	if !ok {
+		return
+	}
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+	if err := h.products.RestoreProduct(c.Request.Context(), id, version); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "product restored"})
+}
+
+func (h *ProductHandler) SetProductPrice(c *gin.Context) {
+	id, ok := pathID(c, "id", "product")
+	if !ok {
+		return
+	}
+	currency := c.Param("currency")
+	if currency == h.rates.Base {
+		c.Error(problem.Unprocessable("base_currency_price", "base currency price is set on the product itself"))
+		return
+	}
+	if _, err := h.rates.Rate(currency); err != nil {
+		c.Error(err)
+		return
+	}
+	var req SetProductPriceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	if _, err := h.products.GetProductByID(c.Request.Context(), id); err != nil {
+		c.Error(err)
+		return
+	}
+	err := h.products.SetProductPrice(c.Request.Context(), id, money.New(req.Amount, currency))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "product price set"})
+}
+
+func (h *ProductHandler) DeleteProductPrice(c *gin.Context) {
+	id, ok := pathID(c, "id", "product")
+	if !ok {
+		return
+	}
+	if err := h.products.DeleteProductPrice(c.Request.Context(), id, c.Param("currency")); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "product price removed"})
+}
+
+func (h *ProductHandler) CreateProductVariant(c *gin.Context) {
+	id, ok := pathID(c, "id", "product")
+	if !ok {
+		return
+	}
+	var req CreateProductVariantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	variant := req.variant()
+	variant.ProductID = id
+	if err := h.products.CreateProductVariant(c.Request.Context(), variant); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, variant)
+}
+
+func (h *ProductHandler) DeleteProductVariant(c *gin.Context) {
+	id, ok := pathID(c, "id", "product")
+	if !ok {
+		return
+	}
+	variantID, ok := pathID(c, "variant_id", "variant")
+	if !ok {
+		return
+	}
+	if err := h.products.DeleteProductVariant(c.Request.Context(), id, variantID); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "variant deleted"})
+}
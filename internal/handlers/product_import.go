@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hitanshu0729/order_go/internal/auth"
+	"github.com/hitanshu0729/order_go/internal/catalog"
+	"github.com/hitanshu0729/order_go/internal/jobs"
+	"github.com/hitanshu0729/order_go/internal/models"
+	"github.com/hitanshu0729/order_go/internal/problem"
+	"github.com/hitanshu0729/order_go/internal/storage/sqlite"
+)
+
+// exportFlushRows is how many exported rows are buffered before they are
+// sent to the client.
+const exportFlushRows = 500
+
+type ImportHandler struct {
+	repo     *sqlite.Repo
+	importer *catalog.Importer
+	jobs     *jobs.Runner
+	// syncBytes is the largest file imported while the client waits;
+	// larger files are queued as a job.
+	syncBytes int64
+}
+
+func NewImportHandler(repo *sqlite.Repo, importer *catalog.Importer, runner *jobs.Runner, syncBytes int64) *ImportHandler {
+	return &ImportHandler{repo: repo, importer: importer, jobs: runner, syncBytes: syncBytes}
+}
+
+// RegisterImportRoutes registers bulk product import and export routes
+// under the given router group.
+func (h *ImportHandler) RegisterImportRoutes(rg *gin.RouterGroup) {
+	admin := auth.RequireRole(models.RoleAdmin)
+
+	products := rg.Group("/products")
+	products.POST("/import", admin, h.ImportProducts)
+	products.GET("/import/:id", admin, h.GetProductImport)
+	products.GET("/export", auth.RequireRole(models.RoleStaff), h.ExportProducts)
+}
+
+// fileFormat returns the format named by ?format=, or else implied by the
+// request's Content-Type, recording a 400 problem when there is none.
+func fileFormat(c *gin.Context, fallback string) (string, bool) {
+	format := c.Query("format")
+	if format == "" {
+		switch mediaType, _, _ := mime.ParseMediaType(c.ContentType()); mediaType {
+		case "text/csv":
+			format = models.ImportFormatCSV
+		case "application/x-ndjson", "application/jsonl":
+			format = models.ImportFormatNDJSON
+		default:
+			format = fallback
+		}
+	}
+	if !catalog.ValidFormat(format) {
+		c.Error(problem.BadRequest("invalid_query", "format must be csv or ndjson"))
+		return "", false
+	}
+	return format, true
+}
+
+// ImportProducts creates or updates products and variants by SKU from a CSV
+// or NDJSON file in the request body. With ?dry_run=true the file is only
+// validated. Files up to syncBytes are imported before responding; larger
+// ones are queued as a products.import job and answered with 202 and the
+// import to poll.
+func (h *ImportHandler) ImportProducts(c *gin.Context) {
+	format, ok := fileFormat(c, "")
+	if !ok {
+		return
+	}
+	payload, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if len(payload) == 0 {
+		c.Error(problem.BadRequest("invalid_payload", "import file is empty"))
+		return
+	}
+
+	ctx := c.Request.Context()
+	imp := &models.ProductImport{
+		Status:    models.ImportStatusPending,
+		Format:    format,
+		DryRun:    c.Query("dry_run") == "true",
+		CreatedBy: principal(c).UserID,
+	}
+	queue := int64(len(payload)) > h.syncBytes
+	if !queue {
+		imp.Status = models.ImportStatusRunning
+	}
+	if err := h.repo.CreateProductImport(ctx, imp, payload); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if queue {
+		if err := h.enqueue(ctx, imp); err != nil {
+			c.Error(err)
+			return
+		}
+		c.Header("Location", c.FullPath()+"/"+strconv.FormatInt(imp.ID, 10))
+		c.JSON(http.StatusAccepted, imp)
+		return
+	}
+	if err := h.importer.Run(ctx, imp, payload); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, imp)
+}
+
+// enqueue queues the job that runs a pending import, failing the import
+// when it cannot be queued so that it is not left pending forever.
+func (h *ImportHandler) enqueue(ctx context.Context, imp *models.ProductImport) error {
+	payload, err := json.Marshal(catalog.ImportJobPayload{ImportID: imp.ID})
+	if err != nil {
+		return err
+	}
+	userID := imp.CreatedBy
+	err = h.jobs.Enqueue(ctx, &models.Job{Type: catalog.ImportJobType, Payload: payload, CreatedBy: &userID})
+	if err == nil {
+		return nil
+	}
+	imp.Status, imp.Error = models.ImportStatusFailed, "could not be queued"
+	if ferr := h.repo.FinishProductImport(context.WithoutCancel(ctx), imp); ferr != nil {
+		slog.ErrorContext(ctx, "failed to fail unqueued product import", "import_id", imp.ID, "error", ferr)
+	}
+	return err
+}
+
+func (h *ImportHandler) GetProductImport(c *gin.Context) {
+	id, ok := pathID(c, "id", "import")
+	if !ok {
+		return
+	}
+	imp, err := h.repo.GetProductImport(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, imp)
+}
+
+// ExportProducts streams every live product and variant as CSV (the
+// default) or NDJSON, in the format ImportProducts accepts.
+func (h *ImportHandler) ExportProducts(c *gin.Context) {
+	format, ok := fileFormat(c, models.ImportFormatCSV)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	attributes, err := h.repo.ProductAttributeNames(ctx)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	contentType := "text/csv; charset=utf-8"
+	if format == models.ImportFormatNDJSON {
+		contentType = "application/x-ndjson"
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", `attachment; filename="products.`+format+`"`)
+	c.Status(http.StatusOK)
+
+	w, err := catalog.NewWriter(c.Writer, format, attributes)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	rows := 0
+	err = h.repo.ExportProducts(ctx, func(row *models.ProductRow) error {
+		if err := w.Write(row); err != nil {
+			return err
+		}
+		if rows++; rows%exportFlushRows == 0 {
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		// The status line is already sent, so the client sees a truncated
+		// file.
+		slog.ErrorContext(ctx, "product export failed", "rows", rows, "error", err)
+		return
+	}
+	slog.InfoContext(ctx, "products exported", "format", format, "rows", rows)
+}
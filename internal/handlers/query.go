@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hitanshu0729/order_go/internal/auth"
+	"github.com/hitanshu0729/order_go/internal/models"
+	"github.com/hitanshu0729/order_go/internal/problem"
+)
+
+// includeDeleted reports whether the caller asked for soft-deleted rows
+// via ?include_deleted=true. Only admins may see deleted rows; the flag is
+// ignored for everyone else.
+func includeDeleted(c *gin.Context) bool {
+	if c.Query("include_deleted") != "true" {
+		return false
+	}
+	p, ok := auth.PrincipalFrom(c)
+	return ok && p.HasRole(models.RoleAdmin)
+}
+
+// principal returns the authenticated caller. Every route that calls it is
+// behind auth.Middleware.
+func principal(c *gin.Context) *auth.Principal {
+	p, _ := auth.PrincipalFrom(c)
+	return p
+}
+
+// pathID parses the named path parameter as the id of a resource, recording
+// a 400 problem when it is not numeric.
+func pathID(c *gin.Context, param, resource string) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param(param), 10, 64)
+	if err != nil {
+		c.Error(problem.BadRequest("invalid_id", "invalid "+resource+" id"))
+		return 0, false
+	}
+	return id, true
+}
+
+// expansions parses ?expand=a,b into the set of related resources to embed,
+// recording a 400 problem for any name not in allowed.
+func expansions(c *gin.Context, allowed ...string) (map[string]bool, bool) {
+	expand := map[string]bool{}
+	q := c.Query("expand")
+	if q == "" {
+		return expand, true
+	}
+	for _, name := range strings.Split(q, ",") {
+		name = strings.TrimSpace(name)
+		if !slices.Contains(allowed, name) {
+			c.Error(problem.BadRequest("invalid_query",
+				"cannot expand "+strconv.Quote(name)+"; expected one of "+strings.Join(allowed, ", ")))
+			return nil, false
+		}
+		expand[name] = true
+	}
+	return expand, true
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hitanshu0729/order_go/internal/auth"
+	"github.com/hitanshu0729/order_go/internal/domain"
+	"github.com/hitanshu0729/order_go/internal/models"
+	"github.com/hitanshu0729/order_go/internal/problem"
+	"github.com/hitanshu0729/order_go/internal/storage/sqlite"
+)
+
+var returnStatuses = []string{
+	models.ReturnStatusRequested,
+	models.ReturnStatusApproved,
+	models.ReturnStatusRejected,
+	models.ReturnStatusReceived,
+}
+
+type ReturnHandler struct {
+	returns *sqlite.Repo
+}
+
+func NewReturnHandler(repo *sqlite.Repo) *ReturnHandler {
+	return &ReturnHandler{returns: repo}
+}
+
+// RegisterReturnRoutes registers return routes. Customers request and see
+// returns of their own orders; deciding on and receiving returns are staff
+// operations.
+func (h *ReturnHandler) RegisterReturnRoutes(rg *gin.RouterGroup) {
+	staff := auth.RequireRole(models.RoleStaff)
+
+	rg.POST("/orders/:id/returns", h.CreateReturn)
+	rg.GET("/orders/:id/returns", h.GetOrderReturns)
+
+	returns := rg.Group("/returns")
+	returns.GET("", staff, h.GetReturns)
+	returns.GET("/:id", h.GetReturn)
+	returns.POST("/:id/approve", staff, h.ApproveReturn)
+	returns.POST("/:id/reject", staff, h.RejectReturn)
+	returns.POST("/:id/receive", staff, h.ReceiveReturn)
+}
+
+type ReturnItemRequest struct {
+	OrderItemID int64  `json:"order_item_id" binding:"required"`
+	Quantity    int64  `json:"quantity" binding:"required,gt=0"`
+	Reason      string `json:"reason" binding:"required,oneof=damaged defective wrong_item not_as_described changed_mind other"`
+	Note        string `json:"note" binding:"max=500"`
+}
+
+type CreateReturnRequest struct {
+	Items []ReturnItemRequest `json:"items" binding:"required,min=1,max=100,dive"`
+}
+
+// ApproveReturnRequest is the optional body of an approval.
+type ApproveReturnRequest struct {
+	Note string `json:"note" binding:"max=500"`
+}
+
+// RejectReturnRequest explains to the customer why a return was rejected.
+type RejectReturnRequest struct {
+	Note string `json:"note" binding:"required,max=500"`
+}
+
+// CreateReturn requests a return of shipped units of an order's lines.
+func (h *ReturnHandler) CreateReturn(c *gin.Context) {
+	order, ok := h.accessibleOrder(c)
+	if !ok {
+		return
+	}
+	var req CreateReturnRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	ret := &models.Return{OrderID: order.ID}
+	for _, item := range req.Items {
+		ret.Items = append(ret.Items, &models.ReturnItem{
+			OrderItemID: item.OrderItemID,
+			Quantity:    item.Quantity,
+			Reason:      item.Reason,
+			Note:        item.Note,
+		})
+	}
+	if err := h.returns.CreateReturn(c.Request.Context(), ret); err != nil {
+		c.Error(err)
+		return
+	}
+	c.Header("Location", "/api/v1/returns/"+strconv.FormatInt(ret.ID, 10))
+	c.JSON(http.StatusCreated, ret)
+}
+
+func (h *ReturnHandler) GetOrderReturns(c *gin.Context) {
+	order, ok := h.accessibleOrder(c)
+	if !ok {
+		return
+	}
+	returns, err := h.returns.ListOrderReturns(c.Request.Context(), order.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, returns)
+}
+
+// GetReturns lists all returns, newest first, optionally filtered by
+// ?status.
+func (h *ReturnHandler) GetReturns(c *gin.Context) {
+	status := c.Query("status")
+	if status != "" && !slices.Contains(returnStatuses, status) {
+		c.Error(problem.BadRequest("invalid_query", "invalid return status "+status))
+		return
+	}
+	returns, err := h.returns.ListReturns(c.Request.Context(), status)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, returns)
+}
+
+// GetReturn returns a return. Customers only see returns of their own
+// orders; others are reported as not found.
+func (h *ReturnHandler) GetReturn(c *gin.Context) {
+	id, ok := pathID(c, "id", "return")
+	if !ok {
+		return
+	}
+	ret, err := h.returns.GetReturn(c.Request.Context(), id)
+	if err == nil && !principal(c).CanAccessUser(ret.UserID) {
+		err = domain.ErrReturnNotFound
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, ret)
+}
+
+func (h *ReturnHandler) ApproveReturn(c *gin.Context) {
+	id, ok := pathID(c, "id", "return")
+	if !ok {
+		return
+	}
+	var req ApproveReturnRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+	ret, err := h.returns.ApproveReturn(c.Request.Context(), id, req.Note)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, ret)
+}
+
+func (h *ReturnHandler) RejectReturn(c *gin.Context) {
+	id, ok := pathID(c, "id", "return")
+	if !ok {
+		return
+	}
+	var req RejectReturnRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	ret, err := h.returns.RejectReturn(c.Request.Context(), id, req.Note)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, ret)
+}
+
+// ReceiveReturn records the goods of an approved return as received and
+// restocks them.
+func (h *ReturnHandler) ReceiveReturn(c *gin.Context) {
+	id, ok := pathID(c, "id", "return")
+	if !ok {
+		return
+	}
+	ret, err := h.returns.ReceiveReturn(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, ret)
+}
+
+// accessibleOrder loads the order in the path if the caller may access it.
+func (h *ReturnHandler) accessibleOrder(c *gin.Context) (*models.Order, bool) {
+	id, ok := pathID(c, "id", "order")
+	if !ok {
+		return nil, false
+	}
+	order, err := h.returns.GetOrderByID(c.Request.Context(), id)
+	if err == nil && !principal(c).CanAccessOrder(order) {
+		err = domain.ErrOrderNotFound
+	}
+	if err != nil {
+		c.Error(err)
+		return nil, false
+	}
+	return order, true
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hitanshu0729/order_go/internal/auth"
+	"github.com/hitanshu0729/order_go/internal/domain"
+	"github.com/hitanshu0729/order_go/internal/models"
+	"github.com/hitanshu0729/order_go/internal/problem"
+	"github.com/hitanshu0729/order_go/internal/storage/sqlite"
+)
+
+var shipmentStatuses = []string{
+	models.ShipmentStatusPacked,
+	models.ShipmentStatusShipped,
+	models.ShipmentStatusDelivered,
+}
+
+type ShipmentHandler struct {
+	shipments *sqlite.Repo
+}
+
+func NewShipmentHandler(repo *sqlite.Repo) *ShipmentHandler {
+	return &ShipmentHandler{shipments: repo}
+}
+
+// RegisterShipmentRoutes registers shipment routes. Customers see the
+// shipments of their own orders; packing, shipping and delivering them are
+// staff operations.
+func (h *ShipmentHandler) RegisterShipmentRoutes(rg *gin.RouterGroup) {
+	staff := auth.RequireRole(models.RoleStaff)
+
+	rg.POST("/orders/:id/shipments", staff, h.CreateShipment)
+	rg.GET("/orders/:id/shipments", h.GetOrderShipments)
+
+	shipments := rg.Group("/shipments")
+	shipments.GET("", staff, h.GetShipments)
+	shipments.GET("/:id", h.GetShipment)
+	shipments.DELETE("/:id", staff, h.DeleteShipment)
+	shipments.POST("/:id/ship", staff, h.ShipShipment)
+	shipments.POST("/:id/deliver", staff, h.DeliverShipment)
+}
+
+type ShipmentItemRequest struct {
+	OrderItemID int64 `json:"order_item_id" binding:"required"`
+	Quantity    int64 `json:"quantity" binding:"required,gt=0"`
+}
+
+// CreateShipmentRequest packs Items, or every unit left to ship when there
+// are none.
+type CreateShipmentRequest struct {
+	Items          []ShipmentItemRequest `json:"items" binding:"max=100,dive"`
+	Carrier        string                `json:"carrier" binding:"max=100"`
+	TrackingNumber string                `json:"tracking_number" binding:"max=100"`
+}
+
+// ShipShipmentRequest is the optional body of shipping a shipment. Empty
+// fields keep the values given when it was packed.
+type ShipShipmentRequest struct {
+	Carrier        string `json:"carrier" binding:"max=100"`
+	TrackingNumber string `json:"tracking_number" binding:"max=100"`
+}
+
+// CreateShipment packs units of a paid order's lines into a shipment.
+func (h *ShipmentHandler) CreateShipment(c *gin.Context) {
+	order, ok := h.accessibleOrder(c)
+	if !ok {
+		return
+	}
+	var req CreateShipmentRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+
+	shipment := &models.Shipment{OrderID: order.ID, Carrier: req.Carrier, TrackingNumber: req.TrackingNumber}
+	for _, item := range req.Items {
+		shipment.Items = append(shipment.Items, &models.ShipmentItem{
+			OrderItemID: item.OrderItemID,
+			Quantity:    item.Quantity,
+		})
+	}
+	if err := h.shipments.CreateShipment(c.Request.Context(), shipment); err != nil {
+		c.Error(err)
+		return
+	}
+	c.Header("Location", "/api/v1/shipments/"+strconv.FormatInt(shipment.ID, 10))
+	c.JSON(http.StatusCreated, shipment)
+}
+
+func (h *ShipmentHandler) GetOrderShipments(c *gin.Context) {
+	order, ok := h.accessibleOrder(c)
+	if !ok {
+		return
+	}
+	shipments, err := h.shipments.ListOrderShipments(c.Request.Context(), order.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, shipments)
+}
+
+// GetShipments lists all shipments, newest first, optionally filtered by
+// ?status.
+func (h *ShipmentHandler) GetShipments(c *gin.Context) {
+	status := c.Query("status")
+	if status != "" && !slices.Contains(shipmentStatuses, status) {
+		c.Error(problem.BadRequest("invalid_query", "invalid shipment status "+status))
+		return
+	}
+	shipments, err := h.shipments.ListShipments(c.Request.Context(), status)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, shipments)
+}
+
+// GetShipment returns a shipment. Customers only see shipments of their own
+// orders; others are reported as not found.
+func (h *ShipmentHandler) GetShipment(c *gin.Context) {
+	id, ok := pathID(c, "id", "shipment")
+	if !ok {
+		return
+	}
+	shipment, err := h.shipments.GetShipment(c.Request.Context(), id)
+	if err == nil && !principal(c).IsStaff() {
+		order, oerr := h.shipments.GetOrderByID(c.Request.Context(), shipment.OrderID)
+		if oerr != nil || !principal(c).CanAccessOrder(order) {
+			err = domain.ErrShipmentNotFound
+		}
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, shipment)
+}
+
+// DeleteShipment unpacks a shipment that has not been shipped yet.
+func (h *ShipmentHandler) DeleteShipment(c *gin.Context) {
+	id, ok := pathID(c, "id", "shipment")
+	if !ok {
+		return
+	}
+	if err := h.shipments.DeleteShipment(c.Request.Context(), id); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "shipment deleted"})
+}
+
+func (h *ShipmentHandler) ShipShipment(c *gin.Context) {
+	id, ok := pathID(c, "id", "shipment")
+	if !ok {
+		return
+	}
+	var req ShipShipmentRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+	shipment, err := h.shipments.ShipShipment(c.Request.Context(), id, req.Carrier, req.TrackingNumber)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, shipment)
+}
+
+func (h *ShipmentHandler) DeliverShipment(c *gin.Context) {
+	id, ok := pathID(c, "id", "shipment")
+	if !ok {
+		return
+	}
+	shipment, err := h.shipments.DeliverShipment(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, shipment)
+}
+
+// accessibleOrder loads the order in the path if the caller may access it.
+func (h *ShipmentHandler) accessibleOrder(c *gin.Context) (*models.Order, bool) {
+	id, ok := pathID(c, "id", "order")
+	if !ok {
+		return nil, false
+	}
+	order, err := h.shipments.GetOrderByID(c.Request.Context(), id)
+	if err == nil && !principal(c).CanAccessOrder(order) {
+		err = domain.ErrOrderNotFound
+	}
+	if err != nil {
+		c.Error(err)
+		return nil, false
+	}
+	return order, true
+}
@@ -1,12 +1,16 @@
 package handlers
 
 import (
-	"github.com/hitanshu0729/order_go/internal/storage/sqlite"
-	"log"
+	"log/slog"
 	"net/http"
-	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/hitanshu0729/order_go/internal/auth"
+	"github.com/hitanshu0729/order_go/internal/domain"
+	"github.com/hitanshu0729/order_go/internal/models"
+	"github.com/hitanshu0729/order_go/internal/problem"
+	"github.com/hitanshu0729/order_go/internal/storage/sqlite"
+	"golang.org/x/crypto/bcrypt"
 )
 
 // UserHandlers holds user-related handlers.
@@ -19,114 +23,219 @@ func NewUserHandler(users *sqlite.Repo) *UserHandler {
 }
 
 // RegisterUserRoutes registers user routes under the given router group.
+// Users can read and update their own account; staff can read any account
+// and only admins can delete, restore or change roles.
 func (h *UserHandler) RegisterUserRoutes(rg *gin.RouterGroup) {
+	admin := auth.RequireRole(models.RoleAdmin)
+
 	users := rg.Group("/users")
-	users.GET("", h.GetUsers)
+	users.GET("", auth.RequireRole(models.RoleStaff), h.GetUsers)
 	users.POST("", h.CreateUser)
 	users.GET("/:id", h.GetUserByID)
 	users.PATCH("/:id", h.UpdateUser)
-	users.DELETE("/:id", h.DeleteUser)
+	users.DELETE("/:id", admin, h.DeleteUser)
+	users.POST("/:id/restore", admin, h.RestoreUser)
+	users.PUT("/:id/role", admin, h.SetUserRole)
 }
 
 func (h *UserHandler) GetUsers(c *gin.Context) {
-	users, err := h.users.GetUsers(c.Request.Context())
+	users, err := h.users.GetUsers(c.Request.Context(), includeDeleted(c))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, users)
 }
 
+// UpdateUserRequest changes a user's profile and, with Password, their
+// password. CurrentPassword is required when users change their own.
+type UpdateUserRequest struct {
+	Name            string `json:"name" binding:"required"`
+	Email           string `json:"email" binding:"required,email"`
+	Password        string `json:"password" binding:"omitempty,min=8"`
+	CurrentPassword string `json:"current_password"`
+}
+
 type CreateUserRequest struct {
 	Name  string `json:"name" binding:"required"`
 	Email string `json:"email" binding:"required,email"`
+
+	// Password is optional; users without one cannot log in.
+	Password string `json:"password" binding:"omitempty,min=8"`
 }
 
 func (h *UserHandler) CreateUser(c *gin.Context) {
 	var req CreateUserRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
-	log.Printf("Creating user: %+v", req)
+	var passwordHash string
+	if req.Password != "" {
+		hash, err := hashPassword(req.Password)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		passwordHash = hash
+	}
 
-	err := h.users.CreateUser(c.Request.Context(), req.Name, req.Email)
+	err := h.users.CreateUser(c.Request.Context(), req.Name, req.Email, passwordHash)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
-	log.Printf("User created successfully: %+v", req)
+	slog.InfoContext(c.Request.Context(), "user created", "email", req.Email)
 
 	c.JSON(http.StatusCreated, gin.H{"message": "User created"})
 }
 
 func (h *UserHandler) GetUserByID(c *gin.Context) {
-	idStr := c.Param("id") // always string
-
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid user id",
-		})
+	id, ok := pathID(c, "id", "user")
+	if !ok {
+		return
+	}
+	if !principal(c).CanAccessUser(id) {
+		c.Error(domain.ErrUserNotFound)
 		return
 	}
 
-	user, err := h.users.GetUserByID(c.Request.Context(), id)
+	getUser := h.users.GetUserByID
+	if includeDeleted(c) {
+		getUser = h.users.GetUserByIDUnscoped
+	}
+	user, err := getUser(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "user not found",
-		})
+		c.Error(err)
 		return
 	}
 
+	setETag(c, user.Version)
 	c.JSON(http.StatusOK, user)
 }
 
 func (h *UserHandler) DeleteUser(c *gin.Context) {
-	idStr := c.Param("id") // always string
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid user id",
-		})
+	id, ok := pathID(c, "id", "user")
+	if !ok {
 		return
 	}
-
-	err = h.users.DeleteUser(c.Request.Context(), id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to delete user",
-		})
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+	if err := h.users.DeleteUser(c.Request.Context(), id, version); err != nil {
+		c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "user deleted"})
 }
 
-func (h *UserHandler) UpdateUser(c *gin.Context) {
-	idStr := c.Param("id") // always string
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid user id",
-		})
+func (h *UserHandler) RestoreUser(c *gin.Context) {
+	id, ok := pathID(c, "id", "user")
+	if !ok {
 		return
 	}
-	var req CreateUserRequest
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+	if err := h.users.RestoreUser(c.Request.Context(), id, version); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "user restored"})
+}
 
+func (h *UserHandler) UpdateUser(c *gin.Context) {
+	id, ok := pathID(c, "id", "user")
+	if !ok {
+		return
+	}
+	if !principal(c).CanManageUser(id) {
+		c.Error(problem.Forbidden("cannot update another user"))
+		return
+	}
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+	var req UpdateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
-	err = h.users.UpdateUser(c.Request.Context(), id, req.Name, req.Email)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to update user",
-		})
+	var hash string
+	if req.Password != "" {
+		if err := h.checkCurrentPassword(c, id, req.CurrentPassword); err != nil {
+			c.Error(err)
+			return
+		}
+		var err error
+		if hash, err = hashPassword(req.Password); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+	if err := h.users.UpdateUser(c.Request.Context(), id, req.Name, req.Email, hash, version); err != nil {
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "user updated"})
 }
+
+// checkCurrentPassword requires users changing their own password to
+// confirm the one they have. Admins resetting someone else's password, and
+// users who have none yet, do not need to.
+func (h *UserHandler) checkCurrentPassword(c *gin.Context, id int64, current string) error {
+	if principal(c).UserID != id {
+		return nil
+	}
+	user, err := h.users.GetUserByID(c.Request.Context(), id)
+	if err != nil {
+		return err
+	}
+	if user.PasswordHash == "" {
+		return nil
+	}
+	if current == "" || bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(current)) != nil {
+		return problem.Forbidden("current password is incorrect")
+	}
+	return nil
+}
+
+type SetUserRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=customer staff admin"`
+}
+
+func (h *UserHandler) SetUserRole(c *gin.Context) {
+	id, ok := pathID(c, "id", "user")
+	if !ok {
+		return
+	}
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+	var req SetUserRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	// Admins cannot demote themselves, so there is always an admin left
+	// to undo a mistake.
+	if id == principal(c).UserID && req.Role != models.RoleAdmin {
+		c.Error(problem.Unprocessable("own_role", "cannot change your own role"))
+		return
+	}
+
+	if err := h.users.SetUserRole(c.Request.Context(), id, req.Role, version); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "role updated", "role": req.Role})
+}
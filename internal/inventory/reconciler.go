@@ -0,0 +1,60 @@
+package inventory
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/hitanshu0729/order_go/internal/models"
+	"github.com/hitanshu0729/order_go/internal/storage/sqlite"
+)
+
+// Reconciler periodically verifies that every product's stock equals the
+// sum of its inventory ledger and reports any drift.
+type Reconciler struct {
+	repo     *sqlite.Repo
+	interval time.Duration
+}
+
+func NewReconciler(repo *sqlite.Repo, interval time.Duration) *Reconciler {
+	return &Reconciler{repo: repo, interval: interval}
+}
+
+// Start runs a reconciliation immediately and then on every tick until ctx
+// is cancelled.
+func (r *Reconciler) Start(ctx context.Context) {
+	slog.Info("inventory reconciler started", "interval", r.interval)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.Run(ctx); err != nil {
+			slog.Error("inventory reconciliation failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			slog.Info("inventory reconciler stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Run performs a single reconciliation pass.
+func (r *Reconciler) Run(ctx context.Context) ([]models.InventoryDrift, error) {
+	drifts, err := r.repo.ReconcileInventory(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range drifts {
+		slog.WarnContext(ctx, "inventory drift",
+			"product_id", d.ProductID, "stock", d.Stock, "ledger", d.LedgerSum, "drift", d.Drift,
+		)
+	}
+	if len(drifts) == 0 {
+		slog.InfoContext(ctx, "inventory reconciliation OK, no drift")
+	}
+	return drifts, nil
+}
@@ -0,0 +1,61 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{3, 40 * time.Second},
+		{6, 320 * time.Second},
+		{7, 10 * time.Minute},
+		{100, 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := backoff(tt.attempt); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestPermanent(t *testing.T) {
+	base := errors.New("bad payload")
+	err := fmt.Errorf("import: %w", Permanent(base))
+	if !isPermanent(err) {
+		t.Error("wrapped permanent error not detected")
+	}
+	if !errors.Is(err, base) {
+		t.Error("permanent error does not unwrap to its cause")
+	}
+	if isPermanent(base) {
+		t.Error("plain error reported as permanent")
+	}
+	if Permanent(nil) != nil {
+		t.Error("Permanent(nil) != nil")
+	}
+}
+
+func TestProgress(t *testing.T) {
+	// Outside a job Progress is a no-op.
+	Progress(context.Background(), 50, "halfway")
+
+	p := &progress{}
+	ctx := context.WithValue(context.Background(), progressKey{}, p)
+	Progress(ctx, 150, "done")
+	if percent, message := p.get(); percent != 100 || message != "done" {
+		t.Errorf("progress = %d %q, want 100 %q", percent, message, "done")
+	}
+	Progress(ctx, -5, "")
+	if percent, _ := p.get(); percent != 0 {
+		t.Errorf("progress = %d, want 0", percent)
+	}
+}
@@ -0,0 +1,54 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+type progressKey struct{}
+
+// progress is the latest progress reported by a running job, saved by its
+// heartbeat.
+type progress struct {
+	mu      sync.Mutex
+	percent int
+	message string
+}
+
+func (p *progress) get() (int, string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.percent, p.message
+}
+
+// Progress reports how far the job running with ctx has got, as a percent
+// and a short message. It does nothing when ctx does not belong to a job.
+func Progress(ctx context.Context, percent int, message string) {
+	p, ok := ctx.Value(progressKey{}).(*progress)
+	if !ok {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.percent, p.message = max(0, min(percent, 100)), message
+}
+
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as one that retrying cannot fix, so the job fails
+// without further attempts.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+func isPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
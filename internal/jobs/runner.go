@@ -0,0 +1,270 @@
+// Package jobs runs background work queued in the jobs table. Workers lease
+// jobs and renew the lease with a heartbeat while they run, so a job whose
+// worker died is picked up again once the lease expires. Failed attempts are
+// retried with exponential backoff, and a running job can be cancelled.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hitanshu0729/order_go/internal/domain"
+	"github.com/hitanshu0729/order_go/internal/models"
+	"github.com/hitanshu0729/order_go/internal/storage/sqlite"
+)
+
+// DefaultMaxAttempts is how many times a job is tried unless it says
+// otherwise.
+const DefaultMaxAttempts = 3
+
+const (
+	baseBackoff = 10 * time.Second
+	maxBackoff  = 10 * time.Minute
+)
+
+// ErrCancelled is the cause of a job's context when cancellation of the
+// job has been requested.
+var ErrCancelled = errors.New("job cancelled")
+
+var errLeaseLost = errors.New("job lease lost")
+
+// Func runs one attempt of a job. Its result is stored on the job as JSON.
+// It should return promptly once ctx is done.
+type Func func(ctx context.Context, job *models.Job) (any, error)
+
+type Config struct {
+	Workers      int
+	Lease        time.Duration
+	PollInterval time.Duration
+}
+
+// Runner executes queued jobs with a fixed pool of workers.
+type Runner struct {
+	repo  *sqlite.Repo
+	cfg   Config
+	owner string
+	funcs map[string]Func
+	wake  chan struct{}
+}
+
+func NewRunner(repo *sqlite.Repo, cfg Config) *Runner {
+	host, _ := os.Hostname()
+	return &Runner{
+		repo:  repo,
+		cfg:   cfg,
+		owner: fmt.Sprintf("%s:%d", host, os.Getpid()),
+		funcs: make(map[string]Func),
+		wake:  make(chan struct{}, 1),
+	}
+}
+
+// Register sets the function that runs jobs of type typ. It must be called
+// before Start.
+func (r *Runner) Register(typ string, fn Func) {
+	r.funcs[typ] = fn
+}
+
+// Enqueue queues j to run as soon as a worker is free.
+func (r *Runner) Enqueue(ctx context.Context, j *models.Job) error {
+	if _, ok := r.funcs[j.Type]; !ok {
+		return domain.ErrUnknownJobType
+	}
+	if j.MaxAttempts == 0 {
+		j.MaxAttempts = DefaultMaxAttempts
+	}
+	if err := r.repo.CreateJob(ctx, j); err != nil {
+		return err
+	}
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Get returns a job by id.
+func (r *Runner) Get(ctx context.Context, id int64) (*models.Job, error) {
+	return r.repo.GetJob(ctx, id)
+}
+
+// Cancel cancels a queued job, or asks the worker running a job to stop it
+// at its next heartbeat.
+func (r *Runner) Cancel(ctx context.Context, id int64) (*models.Job, error) {
+	return r.repo.CancelJob(ctx, id)
+}
+
+// Start runs the workers until ctx is cancelled and returns once they have
+// stopped. Jobs interrupted by cancellation go back to the queue.
+func (r *Runner) Start(ctx context.Context) {
+	slog.Info("job runner started", "workers", r.cfg.Workers, "lease", r.cfg.Lease, "poll_interval", r.cfg.PollInterval)
+
+	var wg sync.WaitGroup
+	for i := range r.cfg.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.work(ctx, fmt.Sprintf("%s/%d", r.owner, i))
+		}()
+	}
+	wg.Wait()
+	slog.Info("job runner stopped")
+}
+
+// work claims and runs jobs until none is ready, then waits for the next
+// tick or Enqueue.
+func (r *Runner) work(ctx context.Context, owner string) {
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for ctx.Err() == nil {
+			job, err := r.repo.ClaimJob(ctx, owner, r.cfg.Lease)
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.Error("failed to claim job", "worker", owner, "error", err)
+				}
+				break
+			}
+			if job == nil {
+				break
+			}
+			r.run(ctx, owner, job)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-r.wake:
+		}
+	}
+}
+
+// run executes one attempt of job and records its outcome.
+func (r *Runner) run(ctx context.Context, owner string, job *models.Job) {
+	log := slog.With("job_id", job.ID, "type", job.Type, "attempt", job.Attempts, "worker", owner)
+	// The outcome is stored even when the runner is stopping.
+	store := context.WithoutCancel(ctx)
+
+	p := &progress{percent: job.Progress, message: job.ProgressMessage}
+	jobCtx, cancel := context.WithCancelCause(context.WithValue(ctx, progressKey{}, p))
+	defer cancel(nil)
+	if job.CancelRequested {
+		cancel(ErrCancelled)
+	}
+
+	stop := make(chan struct{})
+	var heartbeat sync.WaitGroup
+	heartbeat.Add(1)
+	go func() {
+		defer heartbeat.Done()
+		r.heartbeat(store, owner, job.ID, p, cancel, stop)
+	}()
+
+	log.Info("job started")
+	started := time.Now()
+	result, err := r.call(jobCtx, job)
+	close(stop)
+	heartbeat.Wait()
+
+	job.Progress, job.ProgressMessage = p.get()
+	cause := context.Cause(jobCtx)
+	switch {
+	case errors.Is(cause, errLeaseLost):
+		log.Warn("job lease lost, abandoning attempt")
+		return
+	case errors.Is(cause, ErrCancelled):
+		job.Status = models.JobStatusCancelled
+	case err != nil && ctx.Err() != nil:
+		if err := r.repo.ReleaseJob(store, job.ID, owner); err != nil {
+			log.Error("failed to release job", "error", err)
+			return
+		}
+		log.Info("job released on shutdown")
+		return
+	case err == nil:
+		job.Status, job.Progress = models.JobStatusSucceeded, 100
+		if result != nil {
+			if job.Result, err = json.Marshal(result); err != nil {
+				job.Status, job.Error = models.JobStatusFailed, "encode result: "+err.Error()
+			}
+		}
+	case isPermanent(err) || job.Attempts >= job.MaxAttempts:
+		job.Status, job.Error = models.JobStatusFailed, err.Error()
+	default:
+		delay := backoff(job.Attempts)
+		if err := r.repo.RetryJob(store, job.ID, owner, err.Error(), delay); err != nil {
+			log.Error("failed to requeue job", "error", err)
+			return
+		}
+		log.Warn("job attempt failed, retrying", "error", err, "retry_in", delay)
+		return
+	}
+
+	if err := r.repo.FinishJob(store, job, owner); err != nil {
+		log.Error("failed to store job outcome", "status", job.Status, "error", err)
+		return
+	}
+	log.Info("job finished", "status", job.Status, "duration", time.Since(started), "error", job.Error)
+}
+
+// call runs the job's function, turning a panic into a permanent failure.
+func (r *Runner) call(ctx context.Context, job *models.Job) (result any, err error) {
+	if job.Attempts > job.MaxAttempts {
+		// Earlier attempts were lost with the workers running them.
+		return nil, Permanent(errors.New("too many attempts"))
+	}
+	fn, ok := r.funcs[job.Type]
+	if !ok {
+		return nil, Permanent(fmt.Errorf("no handler for job type %q", job.Type))
+	}
+	defer func() {
+		if v := recover(); v != nil {
+			err = Permanent(fmt.Errorf("panic: %v", v))
+		}
+	}()
+	return fn(ctx, job)
+}
+
+// heartbeat renews the lease and saves progress every third of the lease
+// until stop is closed, cancelling the job when cancellation is requested
+// or the lease has been lost.
+func (r *Runner) heartbeat(ctx context.Context, owner string, id int64, p *progress, cancel context.CancelCauseFunc, stop <-chan struct{}) {
+	ticker := time.NewTicker(r.cfg.Lease / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		percent, message := p.get()
+		cancelRequested, err := r.repo.HeartbeatJob(ctx, id, owner, r.cfg.Lease, percent, message)
+		switch {
+		case errors.Is(err, sqlite.ErrJobLeaseLost):
+			cancel(errLeaseLost)
+			return
+		case err != nil:
+			slog.Error("job heartbeat failed", "job_id", id, "error", err)
+		case cancelRequested:
+			cancel(ErrCancelled)
+		}
+	}
+}
+
+// backoff returns the delay before retrying a job whose attempt-th attempt
+// failed: 10s doubling on every attempt, up to 10 minutes.
+func backoff(attempt int) time.Duration {
+	d := baseBackoff
+	for i := 1; i < attempt && d < maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxBackoff)
+}
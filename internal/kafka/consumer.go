@@ -2,12 +2,21 @@ package kafka
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"time"
 
 	"github.com/hitanshu0729/order_go/internal/domain"
+	"github.com/hitanshu0729/order_go/internal/logging"
+	"github.com/hitanshu0729/order_go/internal/metrics"
+	"github.com/hitanshu0729/order_go/internal/tracing"
 	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Consumer struct {
@@ -33,45 +42,115 @@ func (c *Consumer) Start(
 	inventoryConsumer *InventoryConsumer,
 	dlqProducer *DLQProducer,
 ) {
-	log.Println("📥 Kafka consumer started")
+	slog.Info("kafka consumer started")
+	defer c.reader.Close()
 
 	for {
 		msg, err := c.reader.FetchMessage(ctx)
 		if err != nil {
-			log.Println("❌ consumer error:", err)
+			slog.Error("kafka consumer stopped", "error", err)
 			return
 		}
 
-		log.Printf(
-			"📨 event received | topic=%s partition=%d offset=%d value=%s",
-			msg.Topic,
-			msg.Partition,
-			msg.Offset,
-			string(msg.Value),
-		)
+		c.handle(ctx, msg, inventoryConsumer, dlqProducer)
+	}
+}
 
-		err = inventoryConsumer.HandleMessage(ctx, msg.Value)
-		if err != nil {
-			if isPoisonError(err) {
-				log.Println("☠️ poison message, sending to DLQ:", err)
+// handle processes one message. Successes and poison messages (after being
+// sent to the DLQ) are committed; transient failures are not.
+func (c *Consumer) handle(
+	ctx context.Context,
+	msg kafka.Message,
+	inventoryConsumer *InventoryConsumer,
+	dlqProducer *DLQProducer,
+) {
+	ctx = logging.WithRequestID(ctx, messageRequestID(msg))
+	ctx, span := startProcessSpan(ctx, msg)
+	defer span.End()
 
-				dlqProducer.Publish(ctx, kafka.Message{
-					Value: buildDLQPayload(msg, err),
-				})
+	slog.InfoContext(ctx, "event received",
+		"topic", msg.Topic,
+		"partition", msg.Partition,
+		"offset", msg.Offset,
+	)
+	slog.DebugContext(ctx, "event payload", "value", string(msg.Value))
 
-				// ✅ commit offset so partition moves on
-				c.reader.CommitMessages(ctx, msg)
-				continue
-			}
+	event := eventType(msg.Value)
+	span.SetAttributes(attribute.String("event.type", event))
+	start := time.Now()
+	err := inventoryConsumer.HandleMessage(ctx, msg.Value)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		if isPoisonError(err) {
+			metrics.ObserveConsumed(event, metrics.OutcomeDLQ, time.Since(start))
+			slog.ErrorContext(ctx, "poison message, sending to DLQ", "offset", msg.Offset, "error", err)
 
-			log.Println("⚠️ transient error, retrying:", err)
-			continue // ❌ do not commit offset
+			dlqProducer.Publish(ctx, kafka.Message{
+				Headers: msg.Headers,
+				Value:   buildDLQPayload(msg, err),
+			})
+
+			// commit offset so partition moves on
+			c.commit(ctx, msg)
+			return
 		}
 
-		if err := c.reader.CommitMessages(ctx, msg); err != nil {
-			log.Println("❌ offset commit failed:", err)
+		metrics.ObserveConsumed(event, metrics.OutcomeFailed, time.Since(start))
+		slog.WarnContext(ctx, "transient error, retrying", "offset", msg.Offset, "error", err)
+		return // do not commit offset
+	}
+
+	metrics.ObserveConsumed(event, metrics.OutcomeProcessed, time.Since(start))
+	c.commit(ctx, msg)
+}
+
+// startProcessSpan starts a consumer span for msg. The producer's context,
+// extracted from the message headers, is both its parent, so one trace
+// covers the order end to end, and a link, which is how messaging-aware
+// backends associate deliveries with their publish.
+func startProcessSpan(ctx context.Context, msg kafka.Message) (context.Context, trace.Span) {
+	parent := otel.GetTextMapPropagator().Extract(ctx, headerCarrier{&msg.Headers})
+	return tracing.Tracer.Start(parent, msg.Topic+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithLinks(trace.LinkFromContext(parent)),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.destination.name", msg.Topic),
+			attribute.Int("messaging.destination.partition.id", msg.Partition),
+			attribute.Int64("messaging.kafka.offset", msg.Offset),
+		),
+	)
+}
+
+func (c *Consumer) commit(ctx context.Context, msg kafka.Message) {
+	if err := c.reader.CommitMessages(ctx, msg); err != nil {
+		slog.ErrorContext(ctx, "offset commit failed", "offset", msg.Offset, "error", err)
+		return
+	}
+	metrics.ObserveCommit(msg.Partition, msg.Offset, msg.HighWaterMark, msg.Time)
+}
+
+// eventType peeks at the type of an event for metric labels.
+func eventType(value []byte) string {
+	var e struct {
+		Type string `json:"type"`
+	}
+	if json.Unmarshal(value, &e) != nil || e.Type == "" {
+		return "unknown"
+	}
+	return e.Type
+}
+
+// messageRequestID returns the request id propagated by the producer, or a
+// fresh one for messages published outside a request.
+func messageRequestID(msg kafka.Message) string {
+	for _, h := range msg.Headers {
+		if h.Key == logging.HeaderRequestID && len(h.Value) > 0 {
+			return string(h.Value)
 		}
 	}
+	return logging.NewRequestID()
 }
 
 func isPoisonError(err error) bool {
@@ -0,0 +1,32 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestHeaderCarrierPropagatesTraceContext(t *testing.T) {
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+		SpanID:     trace.SpanID{1, 2, 3, 4, 5, 6, 7, 8},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), sc)
+
+	headers := []kafka.Header{{Key: "X-Request-ID", Value: []byte("abc")}}
+	var prop propagation.TraceContext
+	prop.Inject(ctx, headerCarrier{&headers})
+
+	if len(headers) != 2 || headers[1].Key != "traceparent" {
+		t.Fatalf("headers = %v, want X-Request-ID and traceparent", headers)
+	}
+
+	got := trace.SpanContextFromContext(prop.Extract(context.Background(), headerCarrier{&headers}))
+	if got.TraceID() != sc.TraceID() || got.SpanID() != sc.SpanID() || !got.IsRemote() {
+		t.Errorf("extracted %v, want remote %v", got, sc)
+	}
+}
@@ -3,13 +3,14 @@ package kafka
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 
 	"github.com/hitanshu0729/order_go/internal/domain"
 	"github.com/hitanshu0729/order_go/internal/storage/sqlite"
-	"github.com/mattn/go-sqlite3"
+	"github.com/hitanshu0729/order_go/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
 type InventoryConsumer struct {
@@ -29,19 +30,33 @@ type EventPayload struct {
 	OrderID int64 `json:"order_id"`
 }
 
-func (c *InventoryConsumer) HandleMessage(ctx context.Context, value []byte) error {
+func (c *InventoryConsumer) HandleMessage(ctx context.Context, value []byte) (err error) {
+	ctx, span := tracing.Tracer.Start(ctx, "inventory.handle_message")
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
 	var e Event
 	if err := json.Unmarshal(value, &e); err != nil {
 		return fmt.Errorf("%w: %v", domain.ErrInvalidPayload, err)
 	}
-	log.Printf("Event received: %+v", e)
-	if e.Type != "order.paid" {
+	switch e.Type {
+	case "order.paid":
+		span.SetAttributes(attribute.Int64("order.id", e.Payload.OrderID))
+		slog.InfoContext(ctx, "updating inventory for paid order", "order_id", e.Payload.OrderID)
+		return c.processOrder(ctx, e.Payload.OrderID)
+	case "order.cancelled":
+		span.SetAttributes(attribute.Int64("order.id", e.Payload.OrderID))
+		slog.InfoContext(ctx, "restocking cancelled order", "order_id", e.Payload.OrderID)
+		return c.restockOrder(ctx, e.Payload.OrderID)
+	default:
+		slog.DebugContext(ctx, "ignoring event", "type", e.Type)
 		return nil // ignore other events
 	}
-
-	log.Println("📦 Inventory update for order:", e.Payload.OrderID)
-
-	return c.processOrder(ctx, e.Payload.OrderID)
 }
 
 func (c *InventoryConsumer) processOrder(ctx context.Context, orderID int64) error {
@@ -57,7 +72,7 @@ func (c *InventoryConsumer) processOrder(ctx context.Context, orderID int64) err
 		}
 	}()
 
-	// 1️⃣ Idempotency guard
+	// 1. Idempotency guard
 	err = c.repo.MarkEventProcessedTx(
 		ctx,
 		tx,
@@ -65,45 +80,77 @@ func (c *InventoryConsumer) processOrder(ctx context.Context, orderID int64) err
 		orderID,
 	)
 	if err != nil {
-		if isUniqueConstraintError(err) {
+		if sqlite.IsUniqueConstraintError(err) {
 			// already processed → safe no-op
-			log.Printf("Already proceesed for order.paid event type and order id %d", orderID)
+			slog.InfoContext(ctx, "order.paid already processed", "order_id", orderID)
 			return nil
 		}
 		return err
 	}
 
-	// 2️⃣ Apply side effects
-	items, err := c.repo.GetOrderItemsTx(ctx, tx, orderID)
+	// 2. Apply side effects, unless the order's cancellation was handled
+	// first: its stock must then stay where it is.
+	cancelled, err := c.repo.EventProcessedTx(ctx, tx, "order.cancelled", orderID)
 	if err != nil {
 		return err
 	}
-
-	for _, item := range items {
-		log.Printf("Decreasing stock for product %d by %d", item.ProductID, item.Quantity)
-		err := c.repo.DecreaseProductStockTx(
-			ctx,
-			tx,
-			item.ProductID,
-			item.Quantity,
-		)
+	if cancelled {
+		slog.WarnContext(ctx, "order.paid for an order already cancelled, stock unchanged", "order_id", orderID)
+	} else {
+		items, err := c.repo.GetOrderItemsTx(ctx, tx, orderID)
 		if err != nil {
 			return err
 		}
+
+		for _, item := range items {
+			slog.DebugContext(ctx, "decreasing stock", "order_id", orderID, "product_id", item.ProductID, "quantity", item.Quantity)
+			err := c.repo.DecreaseProductStockTx(ctx, tx, orderID, item)
+			if err != nil {
+				return err
+			}
+		}
 	}
 	if err := tx.Commit(); err != nil {
 		return err
 	}
 
-	// 3️⃣ Commit both together
+	// 3. Commit both together
 	committed = true
 	return nil
 }
-func isUniqueConstraintError(err error) bool {
-	var sqliteErr sqlite3.Error
-	if errors.As(err, &sqliteErr) {
-		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique ||
-			sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey
+
+// restockOrder returns the stock of a cancelled order, once. Only orders
+// whose order.paid event was processed had stock taken.
+func (c *InventoryConsumer) restockOrder(ctx context.Context, orderID int64) error {
+	tx, err := c.repo.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := c.repo.MarkEventProcessedTx(ctx, tx, "order.cancelled", orderID); err != nil {
+		if sqlite.IsUniqueConstraintError(err) {
+			slog.InfoContext(ctx, "order.cancelled already processed", "order_id", orderID)
+			return nil
+		}
+		return err
+	}
+
+	paid, err := c.repo.EventProcessedTx(ctx, tx, "order.paid", orderID)
+	if err != nil {
+		return err
+	}
+	if paid {
+		items, err := c.repo.GetOrderItemsTx(ctx, tx, orderID)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			slog.DebugContext(ctx, "restocking", "order_id", orderID, "product_id", item.ProductID, "quantity", item.Quantity)
+			if err := c.repo.RestockOrderItemTx(ctx, tx, orderID, item); err != nil {
+				return err
+			}
+		}
 	}
-	return false
+	return tx.Commit()
 }
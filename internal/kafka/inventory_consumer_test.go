@@ -0,0 +1,118 @@
+package kafka
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/hitanshu0729/order_go/internal/models"
+	"github.com/hitanshu0729/order_go/internal/pricing"
+	"github.com/hitanshu0729/order_go/internal/storage/sqlite"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// newTestRepo returns a Repo over a fresh SQLite file with every migration
+// applied.
+func newTestRepo(t *testing.T) *sqlite.Repo {
+	t.Helper()
+
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_txlock=immediate&_busy_timeout=5000&_foreign_keys=on"
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	files, err := filepath.Glob("../../migrations/*.up.sql")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no migrations found: %v", err)
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(string(b)); err != nil {
+			t.Fatalf("%s: %v", filepath.Base(f), err)
+		}
+	}
+
+	cfg, err := pricing.LoadConfig("../../pricing.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sqlite.NewRepo(db, pricing.NewEngine(cfg, "INR"))
+}
+
+// newStockedOrder returns a product with 10 in stock and an order for 4 of
+// it.
+func newStockedOrder(t *testing.T, repo *sqlite.Repo) (productID, orderID int64) {
+	t.Helper()
+	ctx := context.Background()
+	p := &models.Product{Name: "product", Price: 100, Stock: 10}
+	if err := repo.CreateProduct(ctx, p); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.CreateUser(ctx, "user", t.Name()+"@example.com", ""); err != nil {
+		t.Fatal(err)
+	}
+	u, err := repo.GetUserByEmail(ctx, t.Name()+"@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := &models.Order{UserID: int64(u.ID), Status: "pending", Currency: "INR", ExchangeRate: "1", TaxJurisdiction: "IN-KA"}
+	if err := repo.CreateOrder(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	item := &models.OrderItem{OrderID: o.ID, ProductID: p.ID, Quantity: 4, Price: 100}
+	if err := repo.AddOrderItem(ctx, item, 0); err != nil {
+		t.Fatal(err)
+	}
+	return p.ID, o.ID
+}
+
+func TestInventoryConsumerRestocksOnce(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []string
+		want   int64
+	}{
+		{"paid", []string{"order.paid", "order.paid"}, 6},
+		{"paid then cancelled", []string{"order.paid", "order.cancelled", "order.cancelled", "order.paid"}, 10},
+		{"cancelled before paid", []string{"order.cancelled", "order.paid", "order.cancelled"}, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newTestRepo(t)
+			ctx := context.Background()
+			productID, orderID := newStockedOrder(t, repo)
+			c := NewInventoryConsumer(repo)
+
+			for _, typ := range tt.events {
+				msg := fmt.Sprintf(`{"type":%q,"payload":{"order_id":%d}}`, typ, orderID)
+				if err := c.HandleMessage(ctx, []byte(msg)); err != nil {
+					t.Fatalf("%s: %v", typ, err)
+				}
+			}
+			p, err := repo.GetProductByID(ctx, productID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.Stock != tt.want {
+				t.Errorf("stock = %d, want %d", p.Stock, tt.want)
+			}
+			drifts, err := repo.ReconcileInventory(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(drifts) != 0 {
+				t.Errorf("drifts = %+v, want none", drifts)
+			}
+		})
+	}
+}
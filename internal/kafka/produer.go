@@ -5,7 +5,14 @@ import (
 	"encoding/json"
 	"time"
 
+	"github.com/hitanshu0729/order_go/internal/logging"
+	"github.com/hitanshu0729/order_go/internal/metrics"
+	"github.com/hitanshu0729/order_go/internal/tracing"
 	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Producer struct {
@@ -22,7 +29,26 @@ func NewProducer(brokers []string) *Producer {
 	}
 }
 
-func (p *Producer) Publish(ctx context.Context, eventType string, payload any) error {
+// Publish writes an event to orders.events. The request id and trace
+// context carried by ctx travel in the X-Request-ID and traceparent message
+// headers.
+func (p *Producer) Publish(ctx context.Context, eventType string, payload any) (err error) {
+	ctx, span := tracing.Tracer.Start(ctx, p.writer.Topic+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.destination.name", p.writer.Topic),
+			attribute.String("event.type", eventType),
+		),
+	)
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
 	body, err := json.Marshal(map[string]any{
 		"type":      eventType,
 		"payload":   payload,
@@ -32,9 +58,15 @@ func (p *Producer) Publish(ctx context.Context, eventType string, payload any) e
 		return err
 	}
 
-	return p.writer.WriteMessages(ctx, kafka.Message{
-		Value: body,
-	})
+	msg := kafka.Message{Value: body}
+	if id := logging.RequestID(ctx); id != "" {
+		msg.Headers = append(msg.Headers, kafka.Header{Key: logging.HeaderRequestID, Value: []byte(id)})
+	}
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier{&msg.Headers})
+	start := time.Now()
+	err = p.writer.WriteMessages(ctx, msg)
+	metrics.ObservePublish(eventType, time.Since(start), err)
+	return err
 }
 
 func (p *Producer) Close() error {
@@ -0,0 +1,30 @@
+package limits
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hitanshu0729/order_go/internal/problem"
+)
+
+// MaxBody caps request bodies at n bytes, or at the limit routes gives
+// for the matched route, keyed "METHOD /full/route/path". Requests that
+// declare a larger Content-Length are rejected with 413 up front; bodies
+// that turn out to be larger fail when read, which problem renders as the
+// same 413.
+func MaxBody(n int64, routes map[string]int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := n
+		if l, ok := routes[c.Request.Method+" "+c.FullPath()]; ok {
+			limit = l
+		}
+		if c.Request.ContentLength > limit {
+			problem.Abort(c, problem.New(http.StatusRequestEntityTooLarge, "payload_too_large",
+				"request body exceeds "+strconv.FormatInt(limit, 10)+" bytes"))
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
@@ -0,0 +1,44 @@
+package limits
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hitanshu0729/order_go/internal/problem"
+)
+
+// Concurrency lets at most n requests to the given routes, written
+// "METHOD /full/path", run at once. A request that cannot get a slot
+// within wait is rejected with 503 and Retry-After. Other routes pass
+// through untouched.
+func Concurrency(n int, wait time.Duration, routes ...string) gin.HandlerFunc {
+	limited := make(map[string]bool, len(routes))
+	for _, r := range routes {
+		limited[r] = true
+	}
+	slots := make(chan struct{}, n)
+
+	return func(c *gin.Context) {
+		if !limited[c.Request.Method+" "+c.FullPath()] {
+			c.Next()
+			return
+		}
+
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case slots <- struct{}{}:
+		case <-timer.C:
+			c.Header("Retry-After", "1")
+			problem.Abort(c, problem.New(http.StatusServiceUnavailable, "too_busy",
+				"too many concurrent requests for this endpoint, retry shortly"))
+			return
+		case <-c.Request.Context().Done():
+			c.Abort()
+			return
+		}
+		defer func() { <-slots }()
+		c.Next()
+	}
+}
@@ -0,0 +1,173 @@
+package limits
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hitanshu0729/order_go/internal/auth"
+	"github.com/hitanshu0729/order_go/internal/problem"
+)
+
+func newEngine(mw ...gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(problem.Middleware())
+	r.Use(mw...)
+	return r
+}
+
+func TestRateLimiter(t *testing.T) {
+	l := NewRateLimiter(Config{
+		Default: Policy{Name: "default", Rate: 1, Burst: 2},
+		Routes:  map[string]Policy{"POST /orders": {Name: "orders", Rate: 0.5, Burst: 1}},
+	})
+	now := time.Unix(0, 0)
+	l.now = func() time.Time { return now }
+
+	r := newEngine(l.Middleware())
+	r.GET("/products", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.POST("/orders", func(c *gin.Context) { c.Status(http.StatusCreated) })
+
+	do := func(method, path, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = ip + ":1234"
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for i, want := range []int{200, 200, 429} {
+		if rr := do("GET", "/products", "10.0.0.1"); rr.Code != want {
+			t.Fatalf("request %d: status %d, want %d", i, rr.Code, want)
+		}
+	}
+	rr := do("GET", "/products", "10.0.0.1")
+	if got := rr.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("Retry-After = %q, want 1", got)
+	}
+	if got := rr.Header().Get("RateLimit-Remaining"); got != "0" {
+		t.Errorf("RateLimit-Remaining = %q, want 0", got)
+	}
+	if got := rr.Header().Get("RateLimit-Reset"); got != "2" {
+		t.Errorf("RateLimit-Reset = %q, want 2", got)
+	}
+
+	if rr := do("GET", "/products", "10.0.0.2"); rr.Code != http.StatusOK {
+		t.Errorf("other client: status %d, want 200", rr.Code)
+	}
+	if rr := do("POST", "/orders", "10.0.0.1"); rr.Code != http.StatusCreated {
+		t.Errorf("route policy has its own bucket: status %d, want 201", rr.Code)
+	}
+	if rr := do("POST", "/orders", "10.0.0.1"); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("route policy burst: status %d, want 429", rr.Code)
+	}
+
+	now = now.Add(time.Second)
+	if rr := do("GET", "/products", "10.0.0.1"); rr.Code != http.StatusOK {
+		t.Errorf("after refill: status %d, want 200", rr.Code)
+	}
+}
+
+func TestAddressRateLimiterThrottlesFailedAuth(t *testing.T) {
+	tokens, err := auth.NewTokenService(auth.Config{Algorithm: "HS256", Secret: []byte("s3cret"), Issuer: "test", TokenTTL: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := NewAddressRateLimiter(Config{Default: Policy{Name: "address", Rate: 0.01, Burst: 3}})
+	r := newEngine(l.Middleware(), auth.Middleware(tokens, nil))
+	r.GET("/orders", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	var codes []int
+	for range 5 {
+		req := httptest.NewRequest("GET", "/orders", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("Authorization", "Bearer guessed")
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		codes = append(codes, rr.Code)
+	}
+	want := []int{401, 401, 401, 429, 429}
+	for i := range want {
+		if codes[i] != want[i] {
+			t.Fatalf("statuses = %v, want %v", codes, want)
+		}
+	}
+}
+
+func TestMaxBody(t *testing.T) {
+	r := newEngine(MaxBody(8, map[string]int64{"POST /upload": 16}))
+	read := func(c *gin.Context) {
+		if _, err := io.ReadAll(c.Request.Body); err != nil {
+			c.Error(err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+	r.POST("/", read)
+	r.POST("/upload", read)
+
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		length int64
+		want   int
+	}{
+		{"within limit", "/", "small", 5, http.StatusNoContent},
+		{"declared too large", "/", "0123456789", 10, http.StatusRequestEntityTooLarge},
+		{"undeclared too large", "/", "0123456789", -1, http.StatusRequestEntityTooLarge},
+		{"within route limit", "/upload", "0123456789", 10, http.StatusNoContent},
+		{"above route limit", "/upload", "0123456789abcdefg", -1, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+		req.ContentLength = tt.length
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		if rr.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, rr.Code, tt.want)
+		}
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	r := newEngine(Concurrency(1, 10*time.Millisecond, "GET /slow"))
+	r.GET("/slow", func(c *gin.Context) {
+		close(started)
+		<-release
+		c.Status(http.StatusOK)
+	})
+	r.GET("/fast", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	done := make(chan int)
+	go func() {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/slow", nil))
+		done <- rr.Code
+	}()
+	<-started
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	if rr.Code != http.StatusServiceUnavailable || rr.Header().Get("Retry-After") == "" {
+		t.Errorf("second slow request: status %d, Retry-After %q", rr.Code, rr.Header().Get("Retry-After"))
+	}
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/fast", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("unlimited route: status %d, want 200", rr.Code)
+	}
+
+	close(release)
+	if code := <-done; code != http.StatusOK {
+		t.Errorf("first slow request: status %d, want 200", code)
+	}
+}
@@ -0,0 +1,166 @@
+// Package limits protects the API from abusive clients: per-client token
+// bucket rate limits, a request body size cap and a concurrency cap for
+// expensive queries.
+package limits
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hitanshu0729/order_go/internal/auth"
+	"github.com/hitanshu0729/order_go/internal/problem"
+)
+
+// Policy is a token bucket: clients may burst up to Burst requests and are
+// then refilled at Rate requests per second.
+type Policy struct {
+	Name  string
+	Rate  float64
+	Burst int
+}
+
+// PerMinute returns a policy allowing n requests a minute with a burst of
+// burst.
+func PerMinute(name string, n, burst int) Policy {
+	return Policy{Name: name, Rate: float64(n) / 60, Burst: burst}
+}
+
+// Config maps routes, written "METHOD /full/path" as registered with Gin,
+// to their policy. Routes without an entry use Default.
+type Config struct {
+	Default Policy
+	Routes  map[string]Policy
+}
+
+func (cfg Config) policy(c *gin.Context) Policy {
+	if p, ok := cfg.Routes[c.Request.Method+" "+c.FullPath()]; ok {
+		return p
+	}
+	return cfg.Default
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// idleAfter is how long a bucket may go unused before it is dropped. By
+// then any bucket has refilled, so dropping it loses nothing.
+const idleAfter = 10 * time.Minute
+
+// RateLimiter tracks one bucket per policy and client.
+type RateLimiter struct {
+	cfg       Config
+	byAddress bool
+	now       func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewRateLimiter(cfg Config) *RateLimiter {
+	return &RateLimiter{cfg: cfg, now: time.Now, buckets: map[string]*bucket{}}
+}
+
+// NewAddressRateLimiter returns a RateLimiter that keys every client by
+// address, whether or not it authenticated. It runs before auth.Middleware
+// so that requests with bad credentials are throttled too.
+func NewAddressRateLimiter(cfg Config) *RateLimiter {
+	l := NewRateLimiter(cfg)
+	l.byAddress = true
+	return l
+}
+
+// result describes a bucket after taking a token from it.
+type result struct {
+	allowed    bool
+	remaining  int
+	retryAfter time.Duration // until the next token, when not allowed
+	reset      time.Duration // until the bucket is full again
+}
+
+func (l *RateLimiter) take(key string, p Policy) result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastSweep) > idleAfter {
+		for k, b := range l.buckets {
+			if now.Sub(b.last) > idleAfter {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(p.Burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(p.Burst), b.tokens+now.Sub(b.last).Seconds()*p.Rate)
+	b.last = now
+
+	r := result{allowed: b.tokens >= 1}
+	if r.allowed {
+		b.tokens--
+	} else {
+		r.retryAfter = seconds((1 - b.tokens) / p.Rate)
+	}
+	r.remaining = int(b.tokens)
+	r.reset = seconds((float64(p.Burst) - b.tokens) / p.Rate)
+	return r
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// Middleware enforces the route's policy for the calling client and sets
+// the RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers.
+// Rejected requests get a 429 problem with Retry-After. Unless the limiter
+// keys by address, it must run after auth.Middleware so that authenticated
+// clients are keyed by identity rather than address.
+func (l *RateLimiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := l.cfg.policy(c)
+		key := "ip:" + c.ClientIP()
+		if !l.byAddress {
+			key = clientKey(c)
+		}
+		r := l.take(p.Name+"|"+key, p)
+
+		h := c.Writer.Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(p.Burst))
+		h.Set("RateLimit-Remaining", strconv.Itoa(r.remaining))
+		h.Set("RateLimit-Reset", ceilSeconds(r.reset))
+		if !r.allowed {
+			h.Set("Retry-After", ceilSeconds(r.retryAfter))
+			problem.Abort(c, problem.New(http.StatusTooManyRequests, "rate_limited",
+				"rate limit exceeded, retry after "+ceilSeconds(r.retryAfter)+"s"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// clientKey identifies the caller: by API key when one was used, by user
+// for bearer tokens and by address for anonymous requests.
+func clientKey(c *gin.Context) string {
+	if p, ok := auth.PrincipalFrom(c); ok {
+		if p.APIKeyID != 0 {
+			return "key:" + strconv.FormatInt(p.APIKeyID, 10)
+		}
+		return "user:" + strconv.FormatInt(p.UserID, 10)
+	}
+	return "ip:" + c.ClientIP()
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
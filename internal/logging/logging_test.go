@@ -0,0 +1,64 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestContextHandlerAddsRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(contextHandler{slog.NewJSONHandler(&buf, nil)})
+
+	logger.InfoContext(WithRequestID(context.Background(), "abc-123"), "hello")
+
+	var rec map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec["request_id"] != "abc-123" {
+		t.Errorf("request_id = %v, want abc-123", rec["request_id"])
+	}
+}
+
+func TestMiddlewareRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware())
+	var seen string
+	r.GET("/", func(c *gin.Context) {
+		seen = RequestID(c.Request.Context())
+	})
+
+	tests := []struct {
+		name   string
+		header string
+		adopt  bool
+	}{
+		{"adopts client id", "trace-42", true},
+		{"generates when missing", "", false},
+		{"rejects unsafe id", "bad\nid", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.header != "" {
+			req.Header.Set(HeaderRequestID, tt.header)
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		got := rr.Header().Get(HeaderRequestID)
+		if got == "" || got != seen {
+			t.Errorf("%s: response id %q, context id %q", tt.name, got, seen)
+		}
+		if (got == tt.header) != tt.adopt {
+			t.Errorf("%s: got id %q for header %q", tt.name, got, tt.header)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+// Package metrics defines the service's Prometheus collectors and the
+// /metrics handler that exposes them.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "order_go"
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method and route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	kafkaPublished = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "kafka_published_total",
+		Help:      "Kafka events published by event type and result (ok or error).",
+	}, []string{"event", "result"})
+
+	kafkaPublishDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "kafka_publish_duration_seconds",
+		Help:      "Kafka publish latency by event type.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"event"})
+
+	kafkaConsumed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "kafka_consumed_total",
+		Help:      "Kafka messages consumed by event type and outcome (processed, failed or dlq).",
+	}, []string{"event", "outcome"})
+
+	kafkaProcessDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "kafka_process_duration_seconds",
+		Help:      "Time spent handling a consumed message, by event type.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"event"})
+
+	kafkaCommitLatency = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "kafka_commit_latency_seconds",
+		Help:      "Time from a message being written to its offset being committed.",
+		Buckets:   []float64{.01, .05, .1, .5, 1, 5, 10, 30, 60, 300},
+	})
+
+	kafkaConsumerLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "kafka_consumer_lag_messages",
+		Help:      "Messages between the last committed offset and the partition high-water mark.",
+	}, []string{"partition"})
+
+	ordersCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "orders_created_total",
+		Help:      "Orders created.",
+	})
+
+	ordersPaid = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "orders_paid_total",
+		Help:      "Orders paid.",
+	})
+
+	ordersCancelled = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "orders_cancelled_total",
+		Help:      "Orders cancelled.",
+	})
+
+	ordersExpired = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "orders_expired_total",
+		Help:      "Pending orders cancelled for being left unpaid.",
+	})
+
+	revenue = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "revenue_total",
+		Help:      "Grand total of paid orders in major currency units, by currency.",
+	}, []string{"currency"})
+
+	orderTotalDrift = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "order_total_drift_orders",
+		Help:      "Live orders whose stored totals disagreed with their items at the last check.",
+	})
+)
+
+// Handler serves the default registry in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// RegisterDB exposes the connection pool statistics of db, labelled with
+// name.
+func RegisterDB(db *sql.DB, name string) error {
+	return prometheus.Register(collectors.NewDBStatsCollector(db, name))
+}
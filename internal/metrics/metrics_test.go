@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMiddlewareLabelsByRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware())
+	r.GET("/orders/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for _, path := range []string{"/orders/1", "/orders/2", "/nope"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if got := testutil.ToFloat64(httpRequests.WithLabelValues("GET", "/orders/:id", "200")); got != 2 {
+		t.Errorf("route requests = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(httpRequests.WithLabelValues("GET", "unmatched", "404")); got != 1 {
+		t.Errorf("unmatched requests = %v, want 1", got)
+	}
+}
+
+func TestOrderPaidRevenueInMajorUnits(t *testing.T) {
+	OrderPaid("INR", 12345)
+	OrderPaid("JPY", 500)
+
+	if got := testutil.ToFloat64(revenue.WithLabelValues("INR")); got != 123.45 {
+		t.Errorf("INR revenue = %v, want 123.45", got)
+	}
+	if got := testutil.ToFloat64(revenue.WithLabelValues("JPY")); got != 500 {
+		t.Errorf("JPY revenue = %v, want 500", got)
+	}
+}
@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hitanshu0729/order_go/internal/money"
+)
+
+// Outcomes of a consumed message.
+const (
+	OutcomeProcessed = "processed"
+	OutcomeFailed    = "failed"
+	OutcomeDLQ       = "dlq"
+)
+
+// Middleware records a request count and latency per matched Gin route.
+// Unmatched paths share one label so that scanners cannot inflate the
+// series count.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		httpRequests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		httpDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// ObservePublish records one producer write.
+func ObservePublish(event string, d time.Duration, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	kafkaPublished.WithLabelValues(event, result).Inc()
+	kafkaPublishDuration.WithLabelValues(event).Observe(d.Seconds())
+}
+
+// ObserveConsumed records the outcome and handling time of one message.
+func ObserveConsumed(event, outcome string, d time.Duration) {
+	kafkaConsumed.WithLabelValues(event, outcome).Inc()
+	kafkaProcessDuration.WithLabelValues(event).Observe(d.Seconds())
+}
+
+// ObserveCommit records the lag of a partition after committing offset.
+func ObserveCommit(partition int, offset, highWaterMark int64, written time.Time) {
+	kafkaConsumerLag.WithLabelValues(strconv.Itoa(partition)).Set(float64(max(highWaterMark-offset-1, 0)))
+	if !written.IsZero() {
+		kafkaCommitLatency.Observe(time.Since(written).Seconds())
+	}
+}
+
+func OrderCreated() {
+	ordersCreated.Inc()
+}
+
+// OrderPaid counts a payment and adds its grand total, given in minor
+// units, to the revenue of its currency.
+func OrderPaid(currency string, amount int64) {
+	ordersPaid.Inc()
+	revenue.WithLabelValues(currency).Add(float64(amount) / math.Pow10(money.Exponent(currency)))
+}
+
+func OrderCancelled() {
+	ordersCancelled.Inc()
+}
+
+// OrdersExpired counts n pending orders cancelled for being left unpaid,
+// which are also counted as cancelled.
+func OrdersExpired(n int) {
+	ordersExpired.Add(float64(n))
+	ordersCancelled.Add(float64(n))
+}
+
+// OrderTotalDrift records how many orders the last consistency check
+// flagged.
+func OrderTotalDrift(n int) {
+	orderTotalDrift.Set(float64(n))
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// APIKey is a long-lived credential belonging to a user. Only the SHA-256
+// hash of the key is stored.
+type APIKey struct {
+	ID         int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID     int64      `gorm:"not null;index" json:"user_id"`
+	Name       string     `gorm:"not null" json:"name"`
+	Prefix     string     `gorm:"not null" json:"prefix"`
+	KeyHash    string     `gorm:"not null;unique" json:"-"`
+	CreatedAt  time.Time  `gorm:"not null;autoCreateTime" json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Category is a node of the product category tree. Top-level categories
+// have no parent.
+type Category struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name      string    `gorm:"not null" json:"name"`
+	Slug      string    `gorm:"not null;unique" json:"slug"`
+	ParentID  *int64    `gorm:"index" json:"parent_id,omitempty"`
+	CreatedAt time.Time `gorm:"not null;autoCreateTime" json:"created_at"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Coupon kinds.
+const (
+	CouponKindPercentage = "percentage"
+	CouponKindFixed      = "fixed"
+	CouponKindBuyXGetY   = "buy_x_get_y"
+)
+
+// Coupon is a discount code. AmountOff and MinOrderAmount are in the base
+// currency and converted at each order's snapshotted exchange rate. Zero
+// limits mean unlimited.
+type Coupon struct {
+	ID             int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Code           string     `gorm:"not null;unique" json:"code"`
+	Description    string     `gorm:"not null" json:"description"`
+	Kind           string     `gorm:"not null;check:kind IN ('percentage','fixed','buy_x_get_y')" json:"kind"`
+	PercentOff     int64      `gorm:"not null" json:"percent_off,omitempty"`
+	AmountOff      int64      `gorm:"not null" json:"amount_off,omitempty"`
+	ProductID      *int64     `json:"product_id,omitempty"`
+	BuyQuantity    int64      `gorm:"not null" json:"buy_quantity,omitempty"`
+	GetQuantity    int64      `gorm:"not null" json:"get_quantity,omitempty"`
+	MinOrderAmount int64      `gorm:"not null" json:"min_order_amount"`
+	MaxUses        int64      `gorm:"not null" json:"max_uses"`
+	MaxUsesPerUser int64      `gorm:"not null" json:"max_uses_per_user"`
+	TimesUsed      int64      `gorm:"not null" json:"times_used"`
+	StartsAt       *time.Time `json:"starts_at,omitempty"`
+	EndsAt         *time.Time `json:"ends_at,omitempty"`
+	Active         bool       `gorm:"not null" json:"active"`
+	CreatedAt      time.Time  `gorm:"not null;autoCreateTime" json:"created_at"`
+}
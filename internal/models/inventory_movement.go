@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Inventory movement reasons.
+const (
+	MovementReasonOrder      = "order"
+	MovementReasonRestock    = "restock"
+	MovementReasonAdjustment = "adjustment"
+	MovementReasonReturn     = "return"
+)
+
+// InventoryMovement is an append-only ledger entry for a stock change.
+// The sum of a product's movements must equal its current stock; movements
+// with a VariantID are the variant's and sum to its stock instead.
+type InventoryMovement struct {
+	ID          int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	ProductID   int64     `gorm:"not null;index" json:"product_id"`
+	VariantID   *int64    `json:"variant_id,omitempty"`
+	Quantity    int64     `gorm:"not null" json:"quantity"`
+	Reason      string    `gorm:"not null;check:reason IN ('order','restock','adjustment','return')" json:"reason"`
+	ReferenceID *int64    `json:"reference_id,omitempty"`
+	Note        string    `gorm:"not null" json:"note,omitempty"`
+	CreatedAt   time.Time `gorm:"not null;autoCreateTime" json:"created_at"`
+}
+
+// InventoryDrift reports a product or variant whose stock disagrees with
+// its ledger.
+type InventoryDrift struct {
+	ProductID int64  `json:"product_id"`
+	VariantID *int64 `json:"variant_id,omitempty"`
+	Stock     int64  `json:"stock"`
+	LedgerSum int64  `json:"ledger_sum"`
+	Drift     int64  `json:"drift"`
+}
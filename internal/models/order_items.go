@@ -1,10 +1,18 @@
 package models
 
-// OrderItem represents an item in an order.
+// OrderItem represents an item in an order. An order has one line per
+// product and variant; adding the product again adds to that line. Lines
+// for a catalog variant have its VariantID and its SKU as Variant.
 type OrderItem struct {
     ID        int64 `gorm:"primaryKey;autoIncrement" json:"id"`
     OrderID   int64 `gorm:"not null;index" json:"order_id"`
     ProductID int64 `gorm:"not null;index" json:"product_id"`
     Quantity  int64 `gorm:"not null;check:quantity > 0" json:"quantity"`
     Price     int64 `gorm:"not null;check:price > 0" json:"price"`
+    VariantID *int64 `gorm:"index" json:"variant_id,omitempty"`
+    Variant   string `gorm:"not null" json:"variant,omitempty"`
+    Note      string `gorm:"not null" json:"note,omitempty"`
+
+    // Product is the product as it was when the line was added.
+    Product *ProductSnapshot `gorm:"column:product_snapshot;serializer:json" json:"product,omitempty"`
 }
\ No newline at end of file
@@ -1,12 +1,84 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"github.com/hitanshu0729/order_go/internal/money"
+	"github.com/hitanshu0729/order_go/internal/pricing"
+)
+
+// CancellationReasonExpired is the cancellation reason of orders left
+// unpaid for too long.
+const CancellationReasonExpired = "expired"
 
 // Order represents an order in the system.
+// ExchangeRate is the base→Currency rate snapshotted when the order was
+// created, so item prices and totals can be reproduced later. TotalAmount is
+// the grand total of the persisted Pricing breakdown.
 type Order struct {
-	ID          int64     `gorm:"primaryKey;autoIncrement" json:"id"`
-	UserID      int64     `gorm:"not null;index" json:"user_id"`
-	Status      string    `gorm:"not null;check:status IN ('pending','paid','cancelled','completed')" json:"status"`
-	TotalAmount int64     `gorm:"not null;check:total_amount > 0" json:"total_amount"`
-	CreatedAt   time.Time `gorm:"not null;autoCreateTime" json:"created_at"`
+	ID              int64              `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID          int64              `gorm:"not null;index" json:"user_id"`
+	Status          string             `gorm:"not null;check:status IN ('pending','paid','partially_shipped','shipped','delivered','cancelled')" json:"status"`
+	TotalAmount     int64              `gorm:"not null;check:total_amount > 0" json:"total_amount"`
+	SubtotalAmount  int64              `gorm:"not null" json:"subtotal_amount"`
+	DiscountAmount  int64              `gorm:"not null" json:"discount_amount"`
+	TaxAmount       int64              `gorm:"not null" json:"tax_amount"`
+	ShippingAmount  int64              `gorm:"not null" json:"shipping_amount"`
+	Currency        string             `gorm:"not null" json:"currency"`
+	ExchangeRate    string             `gorm:"not null" json:"exchange_rate"`
+	TaxJurisdiction string             `gorm:"not null" json:"tax_jurisdiction"`
+	CouponID        *int64             `json:"coupon_id,omitempty"`
+	Pricing         *pricing.Breakdown `gorm:"serializer:json" json:"pricing,omitempty"`
+	ShippingAddress *Address           `gorm:"serializer:json" json:"shipping_address,omitempty"`
+	// CancellationReason is set when the order is cancelled.
+	CancellationReason string     `gorm:"not null;default:''" json:"cancellation_reason,omitempty"`
+	Version            int64      `gorm:"not null;default:1" json:"version"`
+	CreatedAt          time.Time  `gorm:"not null;autoCreateTime" json:"created_at"`
+	DeletedAt          *time.Time `gorm:"index" json:"deleted_at,omitempty"`
+
+	// Items and User are only populated by endpoints that return the full
+	// or expanded order.
+	Items []*OrderItem `gorm:"-" json:"items,omitempty"`
+	User  *UserSummary `gorm:"-" json:"user,omitempty"`
+}
+
+// OrderTotalDrift reports an order whose stored totals disagree with its
+// items: the subtotal with the sum of its lines, or the grand total with
+// its pricing breakdown.
+type OrderTotalDrift struct {
+	OrderID        int64  `json:"order_id"`
+	Status         string `json:"status"`
+	SubtotalAmount int64  `json:"subtotal_amount"`
+	ItemsSubtotal  int64  `json:"items_subtotal"`
+	TotalAmount    int64  `json:"total_amount"`
+	PricedTotal    int64  `json:"priced_total"`
+}
+
+// OrderExpirySchedule describes how unpaid orders are expired: orders
+// pending for longer than TTL are cancelled by an orders.expire job queued
+// every Interval, which also fails payments pending without a provider
+// reference for longer than PaymentTimeout. Runs are the latest of those
+// jobs, newest first.
+type OrderExpirySchedule struct {
+	TTL            string     `json:"ttl"`
+	PaymentTimeout string     `json:"payment_timeout"`
+	Interval       string     `json:"interval"`
+	NextRunAt      *time.Time `json:"next_run_at,omitempty"`
+	Runs           []*Job     `json:"runs"`
+}
+
+// Address is a postal address. Country is an ISO 3166-1 alpha-2 code.
+type Address struct {
+	Name       string `json:"name" binding:"required,max=200"`
+	Line1      string `json:"line1" binding:"required,max=200"`
+	Line2      string `json:"line2,omitempty" binding:"max=200"`
+	City       string `json:"city" binding:"required,max=100"`
+	Region     string `json:"region,omitempty" binding:"max=100"`
+	PostalCode string `json:"postal_code" binding:"required,max=20"`
+	Country    string `json:"country" binding:"required,len=2,uppercase"`
+}
+
+// Total returns the order total as Money.
+func (o *Order) Total() money.Money {
+	return money.New(o.TotalAmount, o.Currency)
 }
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// OutboxEvent is an event written in the same transaction as the change it
+// describes, waiting to be published. RequestID and TraceContext carry the
+// originating request across to the publish.
+type OutboxEvent struct {
+	ID           int64             `json:"id"`
+	Type         string            `json:"type"`
+	Payload      json.RawMessage   `json:"payload"`
+	RequestID    string            `json:"request_id,omitempty"`
+	TraceContext map[string]string `json:"trace_context,omitempty"`
+	CreatedAt    time.Time         `json:"created_at"`
+	Attempts     int64             `json:"attempts"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Payment statuses. A payment is pending until the provider authorizes it,
+// or until the provider's webhook reports the outcome when the customer has
+// to complete it with the provider. Captured payments have paid their order;
+// failed, voided and refunded are final.
+const (
+	PaymentStatusPending    = "pending"
+	PaymentStatusAuthorized = "authorized"
+	PaymentStatusCaptured   = "captured"
+	PaymentStatusFailed     = "failed"
+	PaymentStatusVoided     = "voided"
+	PaymentStatusRefunded   = "refunded"
+)
+
+// Payment is an attempt to pay an order's grand total through a payment
+// provider. Amount and RefundedAmount are in Currency, the order's.
+type Payment struct {
+	ID             int64     `json:"id"`
+	OrderID        int64     `json:"order_id"`
+	Provider       string    `json:"provider"`
+	Reference      string    `json:"reference,omitempty"`
+	Method         string    `json:"method,omitempty"`
+	Status         string    `json:"status"`
+	Amount         int64     `json:"amount"`
+	Currency       string    `json:"currency"`
+	RefundedAmount int64     `json:"refunded_amount"`
+	Error          string    `json:"error,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
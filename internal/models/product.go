@@ -1,10 +1,80 @@
 package models
 
-import _ "gorm.io/gorm"
+import (
+	"maps"
+	"time"
 
+	"github.com/hitanshu0729/order_go/internal/money"
+
+	_ "gorm.io/gorm"
+)
+
+// Product is a catalog entry. A product without variants is sold as is,
+// at Price and from Stock; a product with variants is sold as one of them.
 type Product struct {
-	ID    int64  `gorm:"primaryKey;autoIncrement" json:"id"`
-	Name  string `gorm:"not null" json:"name"`
-	Price int64  `gorm:"not null;check:price > 0" json:"price"`
-	Stock int64  `gorm:"not null;check:stock >= 0" json:"stock"`
+	ID          int64             `gorm:"primaryKey;autoIncrement" json:"id"`
+	SKU         string            `gorm:"not null;unique" json:"sku"`
+	Name        string            `gorm:"not null" json:"name"`
+	Description string            `gorm:"not null" json:"description,omitempty"`
+	CategoryID  *int64            `gorm:"index" json:"category_id,omitempty"`
+	Attributes  map[string]string `gorm:"serializer:json" json:"attributes,omitempty"`
+	Price       int64             `gorm:"not null;check:price > 0" json:"price"`
+	Stock       int64             `gorm:"not null;check:stock >= 0" json:"stock"`
+	Version     int64             `gorm:"not null;default:1" json:"version"`
+	DeletedAt   *time.Time        `gorm:"index" json:"deleted_at,omitempty"`
+
+	// Prices holds explicit prices in non-base currencies. Price is always
+	// in the base currency.
+	Prices []money.Money `gorm:"-" json:"prices,omitempty"`
+
+	// Variants holds the product's live variants.
+	Variants []*ProductVariant `gorm:"-" json:"variants,omitempty"`
+}
+
+// ProductVariant is a sellable variant of a product, such as a size or
+// colour, with its own SKU, base-currency price and stock. Its attributes
+// add to, and override, the product's.
+type ProductVariant struct {
+	ID         int64             `gorm:"primaryKey;autoIncrement" json:"id"`
+	ProductID  int64             `gorm:"not null;index" json:"product_id"`
+	SKU        string            `gorm:"not null;unique" json:"sku"`
+	Attributes map[string]string `gorm:"serializer:json" json:"attributes,omitempty"`
+	Price      int64             `gorm:"not null;check:price > 0" json:"price"`
+	Stock      int64             `gorm:"not null;check:stock >= 0" json:"stock"`
+	DeletedAt  *time.Time        `gorm:"index" json:"deleted_at,omitempty"`
+}
+
+// WithVariant returns the product as sold in variant v: with the variant's
+// SKU, price and stock and the merged attributes. Explicit currency prices
+// belong to the product itself and do not carry over.
+func (p *Product) WithVariant(v *ProductVariant) *Product {
+	attrs := maps.Clone(p.Attributes)
+	if attrs == nil {
+		attrs = map[string]string{}
+	}
+	maps.Copy(attrs, v.Attributes)
+	return &Product{
+		ID:          p.ID,
+		SKU:         v.SKU,
+		Name:        p.Name,
+		Description: p.Description,
+		CategoryID:  p.CategoryID,
+		Attributes:  attrs,
+		Price:       v.Price,
+		Stock:       v.Stock,
+		Version:     p.Version,
+	}
+}
+
+// ProductSnapshot is what an order line records about its product, so the
+// order reads the same after the product is renamed or deleted.
+type ProductSnapshot struct {
+	Name       string            `json:"name"`
+	SKU        string            `json:"sku,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// Snapshot returns the product's snapshot for an order line.
+func (p *Product) Snapshot() *ProductSnapshot {
+	return &ProductSnapshot{Name: p.Name, SKU: p.SKU, Attributes: p.Attributes}
 }
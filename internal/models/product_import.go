@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+// Product import statuses.
+const (
+	ImportStatusPending   = "pending"
+	ImportStatusRunning   = "running"
+	ImportStatusCompleted = "completed"
+	ImportStatusFailed    = "failed"
+)
+
+// Product import and export file formats.
+const (
+	ImportFormatCSV    = "csv"
+	ImportFormatNDJSON = "ndjson"
+)
+
+// ProductImport records a bulk product import. An import is applied all or
+// nothing: it fails without changing anything when any row is invalid, and
+// a dry run only validates.
+type ProductImport struct {
+	ID         int64            `json:"id"`
+	Status     string           `json:"status"`
+	Format     string           `json:"format"`
+	DryRun     bool             `json:"dry_run"`
+	TotalRows  int64            `json:"total_rows"`
+	Created    int64            `json:"created"`
+	Updated    int64            `json:"updated"`
+	Failed     int64            `json:"failed"`
+	Errors     []ImportRowError `json:"errors"`
+	Error      string           `json:"error,omitempty"`
+	CreatedBy  int64            `json:"created_by"`
+	CreatedAt  time.Time        `json:"created_at"`
+	StartedAt  *time.Time       `json:"started_at,omitempty"`
+	FinishedAt *time.Time       `json:"finished_at,omitempty"`
+}
+
+// ImportRowError explains why one row of an import was rejected. Line is
+// the 1-based line of the file, counting a CSV header.
+type ImportRowError struct {
+	Line    int    `json:"line"`
+	SKU     string `json:"sku,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e *ImportRowError) Error() string {
+	return e.Message
+}
+
+// ProductRow is one line of a product import or export file: a product, or
+// a variant of the product whose SKU is ParentSKU. On import, nil fields
+// leave the stored value unchanged and Attributes are merged into the
+// existing ones.
+type ProductRow struct {
+	Line        int               `json:"-"`
+	SKU         string            `json:"sku"`
+	ParentSKU   string            `json:"parent_sku,omitempty"`
+	Name        *string           `json:"name,omitempty"`
+	Description *string           `json:"description,omitempty"`
+	Category    *string           `json:"category,omitempty"` // slug
+	Price       *int64            `json:"price,omitempty"`
+	Stock       *int64            `json:"stock,omitempty"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+}
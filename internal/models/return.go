@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// Return statuses. A return is requested by the customer, approved or
+// rejected by staff, and received once the goods are back; rejected and
+// received are final.
+const (
+	ReturnStatusRequested = "requested"
+	ReturnStatusApproved  = "approved"
+	ReturnStatusRejected  = "rejected"
+	ReturnStatusReceived  = "received"
+)
+
+// Return reasons.
+const (
+	ReturnReasonDamaged        = "damaged"
+	ReturnReasonDefective      = "defective"
+	ReturnReasonWrongItem      = "wrong_item"
+	ReturnReasonNotAsDescribed = "not_as_described"
+	ReturnReasonChangedMind    = "changed_mind"
+	ReturnReasonOther          = "other"
+)
+
+// Return is a request to send back shipped units of an order's lines.
+// RefundAmount, in the order's currency, is the sum of its items' refunds
+// and is owed to the customer once the return is received.
+type Return struct {
+	ID           int64         `json:"id"`
+	OrderID      int64         `json:"order_id"`
+	UserID       int64         `json:"user_id"`
+	Status       string        `json:"status"`
+	Currency     string        `json:"currency"`
+	RefundAmount int64         `json:"refund_amount"`
+	Note         string        `json:"note,omitempty"`
+	Items        []*ReturnItem `json:"items"`
+	CreatedAt    time.Time     `json:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+	DecidedAt    *time.Time    `json:"decided_at,omitempty"`
+	ReceivedAt   *time.Time    `json:"received_at,omitempty"`
+}
+
+// ReturnItem is a quantity of one order line being returned.
+type ReturnItem struct {
+	ID           int64  `json:"id"`
+	ReturnID     int64  `json:"return_id"`
+	OrderItemID  int64  `json:"order_item_id"`
+	ProductID    int64  `json:"product_id"`
+	VariantID    *int64 `json:"variant_id,omitempty"`
+	Quantity     int64  `json:"quantity"`
+	Reason       string `json:"reason"`
+	Note         string `json:"note,omitempty"`
+	RefundAmount int64  `json:"refund_amount"`
+}
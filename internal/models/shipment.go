@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Shipment statuses. A shipment is packed, then shipped, then delivered.
+// Only packed shipments can be deleted.
+const (
+	ShipmentStatusPacked    = "packed"
+	ShipmentStatusShipped   = "shipped"
+	ShipmentStatusDelivered = "delivered"
+)
+
+// Shipment sends units of a paid order's lines. The order's status follows
+// its shipments: partially_shipped while some units are shipped, shipped
+// once all are, and delivered once all are delivered.
+type Shipment struct {
+	ID             int64           `json:"id"`
+	OrderID        int64           `json:"order_id"`
+	Status         string          `json:"status"`
+	Carrier        string          `json:"carrier,omitempty"`
+	TrackingNumber string          `json:"tracking_number,omitempty"`
+	Items          []*ShipmentItem `json:"items"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+	ShippedAt      *time.Time      `json:"shipped_at,omitempty"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
+
+// ShipmentItem is a quantity of one order line in a shipment.
+type ShipmentItem struct {
+	ID          int64 `json:"id"`
+	ShipmentID  int64 `json:"shipment_id"`
+	OrderItemID int64 `json:"order_item_id"`
+	Quantity    int64 `json:"quantity"`
+}
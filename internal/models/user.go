@@ -1,10 +1,36 @@
 package models
 
-import _ "gorm.io/gorm"
+import (
+	"time"
+
+	_ "gorm.io/gorm"
+)
+
+// User roles, from least to most privileged.
+const (
+	RoleCustomer = "customer"
+	RoleStaff    = "staff"
+	RoleAdmin    = "admin"
+)
 
 // User represents a user in the system.
 type User struct {
-	ID    uint   `gorm:"primaryKey;autoIncrement" json:"id"`
-	Name  string `gorm:"not null" json:"name"`
-	Email string `gorm:"not null;unique" json:"email"`
+	ID        uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name      string     `gorm:"not null" json:"name"`
+	Email     string     `gorm:"not null;unique" json:"email"`
+	Role      string     `gorm:"not null;default:customer" json:"role"`
+	Version   int64      `gorm:"not null;default:1" json:"version"`
+	DeletedAt *time.Time `gorm:"index" json:"deleted_at,omitempty"`
+
+	PasswordHash string `json:"-"`
+	// CredentialsChangedAt is when the password last changed; tokens issued
+	// before it are rejected.
+	CredentialsChangedAt *time.Time `json:"-"`
+}
+
+// UserSummary identifies a user on responses about other resources.
+type UserSummary struct {
+	ID    int64  `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
 }
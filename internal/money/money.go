@@ -0,0 +1,132 @@
+// Package money represents amounts in a currency's minor unit (paise, cents)
+// together with their ISO 4217 currency code.
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+)
+
+var (
+	// ErrCurrencyMismatch indicates arithmetic between different currencies
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+
+	// ErrUnknownCurrency indicates a currency with no configured exchange rate
+	ErrUnknownCurrency = errors.New("unknown currency")
+
+	// ErrInvalidRate indicates an exchange rate that is not a positive decimal
+	ErrInvalidRate = errors.New("invalid exchange rate")
+)
+
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// zeroDecimal lists currencies whose minor unit equals the major unit.
+var zeroDecimal = map[string]bool{
+	"JPY": true,
+	"KRW": true,
+	"VND": true,
+	"CLP": true,
+	"ISK": true,
+}
+
+// Money is an amount in the minor unit of Currency.
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+func New(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// ValidCurrency reports whether code looks like an ISO 4217 code.
+func ValidCurrency(code string) bool {
+	return currencyCode.MatchString(code)
+}
+
+// Exponent returns the number of decimal places in a currency's minor unit.
+func Exponent(currency string) int {
+	if zeroDecimal[currency] {
+		return 0
+	}
+	return 2
+}
+
+func (m Money) Add(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, fmt.Errorf("%w: %s + %s", ErrCurrencyMismatch, m.Currency, o.Currency)
+	}
+	return Money{Amount: m.Amount + o.Amount, Currency: m.Currency}, nil
+}
+
+func (m Money) Mul(n int64) Money {
+	return Money{Amount: m.Amount * n, Currency: m.Currency}
+}
+
+func (m Money) String() string {
+	exp := Exponent(m.Currency)
+	if exp == 0 {
+		return fmt.Sprintf("%d %s", m.Amount, m.Currency)
+	}
+	sign := ""
+	amount := m.Amount
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	return fmt.Sprintf("%s%d.%02d %s", sign, amount/100, amount%100, m.Currency)
+}
+
+// Convert converts m into currency to using rate, a decimal string giving
+// the number of major units of to per major unit of m.Currency. The result
+// is rounded half away from zero to the target minor unit.
+func Convert(m Money, to, rate string) (Money, error) {
+	if m.Currency == to {
+		return m, nil
+	}
+	r, ok := new(big.Rat).SetString(rate)
+	if !ok || r.Sign() <= 0 {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidRate, rate)
+	}
+
+	v := new(big.Rat).SetInt64(m.Amount)
+	v.Mul(v, r)
+	v.Mul(v, pow10(Exponent(to)))
+	v.Quo(v, pow10(Exponent(m.Currency)))
+
+	return Money{Amount: round(v), Currency: to}, nil
+}
+
+// MulRate multiplies m by a decimal rate such as a tax rate "0.18", rounding
+// half away from zero to the minor unit.
+func MulRate(m Money, rate string) (Money, error) {
+	r, ok := new(big.Rat).SetString(rate)
+	if !ok || r.Sign() < 0 {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidRate, rate)
+	}
+	v := new(big.Rat).SetInt64(m.Amount)
+	v.Mul(v, r)
+	return Money{Amount: round(v), Currency: m.Currency}, nil
+}
+
+func pow10(n int) *big.Rat {
+	return new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil))
+}
+
+// round rounds half away from zero.
+func round(v *big.Rat) int64 {
+	num := new(big.Int).Set(v.Num())
+	den := v.Denom()
+	neg := num.Sign() < 0
+	num.Abs(num)
+
+	q, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if rem.Mul(rem, big.NewInt(2)).Cmp(den) >= 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	if neg {
+		q.Neg(q)
+	}
+	return q.Int64()
+}
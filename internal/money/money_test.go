@@ -0,0 +1,73 @@
+package money
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		name string
+		in   Money
+		to   string
+		rate string
+		want Money
+	}{
+		{"same currency", New(1999, "INR"), "INR", "1", New(1999, "INR")},
+		{"two decimals", New(10000, "INR"), "USD", "0.012", New(120, "USD")},
+		{"rounds down below half", New(125, "INR"), "USD", "0.01", New(1, "USD")},
+		{"rounds half away from zero", New(150, "INR"), "USD", "0.01", New(2, "USD")},
+		{"zero decimal target", New(10000, "INR"), "JPY", "1.79", New(179, "JPY")},
+		{"negative amount", New(-150, "INR"), "USD", "0.01", New(-2, "USD")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Convert(tt.in, tt.to, tt.rate)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Convert(%v, %s, %s) = %v, want %v", tt.in, tt.to, tt.rate, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertInvalidRate(t *testing.T) {
+	for _, rate := range []string{"", "abc", "0", "-1"} {
+		if _, err := Convert(New(100, "INR"), "USD", rate); !errors.Is(err, ErrInvalidRate) {
+			t.Errorf("rate %q: got %v, want ErrInvalidRate", rate, err)
+		}
+	}
+}
+
+func TestAddCurrencyMismatch(t *testing.T) {
+	if _, err := New(1, "INR").Add(New(1, "USD")); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("got %v, want ErrCurrencyMismatch", err)
+	}
+}
+
+func TestLoadRates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	if err := os.WriteFile(path, []byte(`{"base":"INR","rates":{"USD":"0.012","EUR":"0.011"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := LoadRates(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rate, _ := r.Rate("INR"); rate != "1" {
+		t.Errorf("base rate = %q, want 1", rate)
+	}
+	if rate, _ := r.Rate("USD"); rate != "0.012" {
+		t.Errorf("USD rate = %q, want 0.012", rate)
+	}
+	if _, err := r.Rate("GBP"); !errors.Is(err, ErrUnknownCurrency) {
+		t.Errorf("GBP: got %v, want ErrUnknownCurrency", err)
+	}
+	if got := r.Currencies(); len(got) != 3 || got[0] != "INR" || got[1] != "EUR" {
+		t.Errorf("Currencies() = %v", got)
+	}
+}
@@ -0,0 +1,80 @@
+package money
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"time"
+)
+
+// Rates is an exchange-rate table quoted against a single base currency.
+// Product prices are stored in the base currency.
+type Rates struct {
+	Base     string            `json:"base"`
+	Rates    map[string]string `json:"rates"`
+	LoadedAt time.Time         `json:"loaded_at"`
+}
+
+// BaseOnly returns a table that only knows its base currency.
+func BaseOnly(base string) *Rates {
+	return &Rates{Base: base, Rates: map[string]string{}, LoadedAt: time.Now().UTC()}
+}
+
+// LoadRates reads a rate table from a JSON file of the form
+//
+//	{"base": "INR", "rates": {"USD": "0.012", "EUR": "0.011"}}
+//
+// Rates are decimal strings so that conversions are exact and reproducible.
+func LoadRates(path string) (*Rates, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var r Rates
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if !ValidCurrency(r.Base) {
+		return nil, fmt.Errorf("parse %s: invalid base currency %q", path, r.Base)
+	}
+	if r.Rates == nil {
+		r.Rates = map[string]string{}
+	}
+	for code, rate := range r.Rates {
+		if !ValidCurrency(code) {
+			return nil, fmt.Errorf("parse %s: invalid currency %q", path, code)
+		}
+		if v, ok := new(big.Rat).SetString(rate); !ok || v.Sign() <= 0 {
+			return nil, fmt.Errorf("parse %s: %w for %s: %q", path, ErrInvalidRate, code, rate)
+		}
+	}
+	r.LoadedAt = time.Now().UTC()
+	return &r, nil
+}
+
+// Rate returns the base→currency rate. The base currency always has rate "1".
+func (r *Rates) Rate(currency string) (string, error) {
+	if currency == r.Base {
+		return "1", nil
+	}
+	rate, ok := r.Rates[currency]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownCurrency, currency)
+	}
+	return rate, nil
+}
+
+// Currencies lists every currency an order can be placed in.
+func (r *Rates) Currencies() []string {
+	codes := []string{r.Base}
+	for code := range r.Rates {
+		if code != r.Base {
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes[1:])
+	return codes
+}
@@ -0,0 +1,65 @@
+// Package orders runs background jobs over orders.
+package orders
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/hitanshu0729/order_go/internal/metrics"
+	"github.com/hitanshu0729/order_go/internal/models"
+	"github.com/hitanshu0729/order_go/internal/storage/sqlite"
+)
+
+// TotalsChecker periodically verifies that every order's stored totals
+// agree with its items and reports any that do not.
+type TotalsChecker struct {
+	repo     *sqlite.Repo
+	interval time.Duration
+}
+
+func NewTotalsChecker(repo *sqlite.Repo, interval time.Duration) *TotalsChecker {
+	return &TotalsChecker{repo: repo, interval: interval}
+}
+
+// Start runs a check immediately and then on every tick until ctx is
+// cancelled.
+func (c *TotalsChecker) Start(ctx context.Context) {
+	slog.Info("order totals checker started", "interval", c.interval)
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := c.Run(ctx); err != nil {
+			slog.Error("order totals check failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			slog.Info("order totals checker stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Run performs a single check.
+func (c *TotalsChecker) Run(ctx context.Context) ([]models.OrderTotalDrift, error) {
+	drifts, err := c.repo.CheckOrderTotals(ctx)
+	if err != nil {
+		return nil, err
+	}
+	metrics.OrderTotalDrift(len(drifts))
+	for _, d := range drifts {
+		slog.WarnContext(ctx, "order totals disagree with items",
+			"order_id", d.OrderID, "status", d.Status,
+			"subtotal", d.SubtotalAmount, "items_subtotal", d.ItemsSubtotal,
+			"total", d.TotalAmount, "priced_total", d.PricedTotal,
+		)
+	}
+	if len(drifts) == 0 {
+		slog.InfoContext(ctx, "order totals check OK, no drift")
+	}
+	return drifts, nil
+}
@@ -0,0 +1,139 @@
+package orders
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/hitanshu0729/order_go/internal/jobs"
+	"github.com/hitanshu0729/order_go/internal/metrics"
+	"github.com/hitanshu0729/order_go/internal/models"
+	"github.com/hitanshu0729/order_go/internal/storage/sqlite"
+)
+
+// ExpireJobType is the job type that expires unpaid orders.
+const ExpireJobType = "orders.expire"
+
+// expireBatch is how many orders are expired per query.
+const expireBatch = 100
+
+// recentRuns is how many past runs Schedule reports.
+const recentRuns = 10
+
+// ExpiryResult is the result of an ExpireJobType job.
+type ExpiryResult struct {
+	Expired           int     `json:"expired"`
+	OrderIDs          []int64 `json:"order_ids"`
+	AbandonedPayments []int64 `json:"abandoned_payment_ids"`
+}
+
+// Expirer cancels orders left pending for longer than a TTL, and fails
+// payments abandoned before their provider answered. Start queues an
+// ExpireJobType job on every tick, so each run is recorded as a job.
+type Expirer struct {
+	repo           *sqlite.Repo
+	jobs           *jobs.Runner
+	ttl            time.Duration
+	paymentTimeout time.Duration
+	interval       time.Duration
+
+	mu      sync.Mutex
+	nextRun time.Time
+}
+
+func NewExpirer(repo *sqlite.Repo, runner *jobs.Runner, ttl, paymentTimeout, interval time.Duration) *Expirer {
+	return &Expirer{repo: repo, jobs: runner, ttl: ttl, paymentTimeout: paymentTimeout, interval: interval}
+}
+
+// Start queues an expiry run immediately and then on every tick until ctx
+// is cancelled. A tick is skipped while the previous run has not finished.
+func (e *Expirer) Start(ctx context.Context) {
+	slog.Info("order expirer started", "ttl", e.ttl, "payment_timeout", e.paymentTimeout, "interval", e.interval)
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := e.enqueue(ctx); err != nil {
+			slog.Error("failed to queue order expiry", "error", err)
+		}
+		e.mu.Lock()
+		e.nextRun = time.Now().Add(e.interval)
+		e.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			slog.Info("order expirer stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *Expirer) enqueue(ctx context.Context) error {
+	last, err := e.repo.ListJobs(ctx, ExpireJobType, 1)
+	if err != nil {
+		return err
+	}
+	if len(last) > 0 && !last[0].Finished() {
+		slog.Warn("previous order expiry still pending, skipping", "job_id", last[0].ID, "status", last[0].Status)
+		return nil
+	}
+	return e.jobs.Enqueue(ctx, &models.Job{Type: ExpireJobType, MaxAttempts: 1})
+}
+
+// RunJob is the jobs.Func for ExpireJobType. Abandoned payments are failed
+// first. Each order is then cancelled in its own transaction, so orders
+// expired before a failure stay expired.
+func (e *Expirer) RunJob(ctx context.Context, _ *models.Job) (any, error) {
+	result := ExpiryResult{OrderIDs: []int64{}}
+	defer func() { metrics.OrdersExpired(result.Expired) }()
+
+	abandoned, err := e.repo.FailAbandonedPayments(ctx, e.paymentTimeout)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range abandoned {
+		slog.WarnContext(ctx, "payment abandoned before the provider answered", "payment_id", id, "timeout", e.paymentTimeout)
+	}
+	result.AbandonedPayments = abandoned
+
+	for {
+		ids, err := e.repo.ExpirePendingOrders(ctx, e.ttl, expireBatch)
+		result.Expired += len(ids)
+		result.OrderIDs = append(result.OrderIDs, ids...)
+		for _, id := range ids {
+			slog.InfoContext(ctx, "order expired", "order_id", id, "ttl", e.ttl)
+		}
+		if err != nil {
+			return nil, err
+		}
+		jobs.Progress(ctx, 0, fmt.Sprintf("%d orders expired", result.Expired))
+		if len(ids) < expireBatch {
+			return result, nil
+		}
+	}
+}
+
+// Schedule reports the expiry settings, the next run and recent runs.
+func (e *Expirer) Schedule(ctx context.Context) (*models.OrderExpirySchedule, error) {
+	runs, err := e.repo.ListJobs(ctx, ExpireJobType, recentRuns)
+	if err != nil {
+		return nil, err
+	}
+	s := &models.OrderExpirySchedule{
+		TTL:            e.ttl.String(),
+		PaymentTimeout: e.paymentTimeout.String(),
+		Interval:       e.interval.String(),
+		Runs:           runs,
+	}
+	e.mu.Lock()
+	if !e.nextRun.IsZero() {
+		next := e.nextRun
+		s.NextRunAt = &next
+	}
+	e.mu.Unlock()
+	return s, nil
+}
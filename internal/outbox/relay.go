@@ -0,0 +1,87 @@
+// Package outbox publishes events queued in the transactional outbox.
+// Writers insert an event in the same transaction as the change it
+// describes; the relay delivers it to Kafka afterwards, so an event is
+// published at least once if and only if its transaction committed.
+package outbox
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/hitanshu0729/order_go/internal/kafka"
+	"github.com/hitanshu0729/order_go/internal/logging"
+	"github.com/hitanshu0729/order_go/internal/storage/sqlite"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// batchSize bounds the events published per pass.
+const batchSize = 100
+
+// Relay polls the outbox and publishes pending events in order.
+type Relay struct {
+	repo     *sqlite.Repo
+	producer *kafka.Producer
+	interval time.Duration
+}
+
+func NewRelay(repo *sqlite.Repo, producer *kafka.Producer, interval time.Duration) *Relay {
+	return &Relay{repo: repo, producer: producer, interval: interval}
+}
+
+// Start publishes pending events immediately and then on every tick until
+// ctx is cancelled.
+func (r *Relay) Start(ctx context.Context) {
+	slog.Info("outbox relay started", "interval", r.interval)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.Run(ctx); err != nil {
+			slog.Error("outbox relay failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			slog.Info("outbox relay stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Run performs a single pass and returns the number of events published.
+// It stops at the first failure so that events are never published out of
+// order; the failed event is retried on the next pass.
+func (r *Relay) Run(ctx context.Context) (int, error) {
+	published := 0
+	for {
+		events, err := r.repo.GetUnpublishedEvents(ctx, batchSize)
+		if err != nil {
+			return published, err
+		}
+		for _, e := range events {
+			// Publish as part of the request that wrote the event.
+			ectx := logging.WithRequestID(ctx, e.RequestID)
+			ectx = otel.GetTextMapPropagator().Extract(ectx, propagation.MapCarrier(e.TraceContext))
+
+			if err := r.producer.Publish(ectx, e.Type, e.Payload); err != nil {
+				slog.WarnContext(ectx, "outbox publish failed",
+					"event", e.Type, "outbox_id", e.ID, "attempts", e.Attempts+1, "error", err)
+				if rerr := r.repo.RecordEventFailure(ctx, e.ID, err); rerr != nil {
+					return published, rerr
+				}
+				return published, nil
+			}
+			if err := r.repo.MarkEventPublished(ctx, e.ID); err != nil {
+				return published, err
+			}
+			published++
+		}
+		if len(events) < batchSize {
+			return published, nil
+		}
+	}
+}
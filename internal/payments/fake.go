@@ -0,0 +1,77 @@
+package payments
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hitanshu0729/order_go/internal/domain"
+)
+
+// Payment methods understood by the Fake provider. Any other method is
+// authorized and captured at once.
+const (
+	FakeMethodOK           = "tok_ok"
+	FakeMethodDeclined     = "tok_declined"
+	FakeMethodCaptureFails = "tok_capture_fails"
+	FakeMethodAsync        = "tok_async"
+)
+
+// fakePrefix starts every reference handed out by the Fake.
+const fakePrefix = "fake_"
+
+// Fake is a Provider for local development and tests that never moves
+// money. Its answers depend only on the request, so they are the same on
+// every run: the reference of a payment is derived from its id and method,
+// and FakeMethodDeclined, FakeMethodCaptureFails and FakeMethodAsync select
+// a declined authorization, a failing capture and a pending authorization
+// completed by webhook.
+type Fake struct{}
+
+func NewFake() *Fake {
+	return &Fake{}
+}
+
+func (f *Fake) Name() string {
+	return "fake"
+}
+
+func (f *Fake) Authorize(_ context.Context, req AuthorizeRequest) (Authorization, error) {
+	if req.Method == FakeMethodDeclined {
+		return Authorization{}, fmt.Errorf("%w: card declined", domain.ErrPaymentDeclined)
+	}
+	method := req.Method
+	if method == "" {
+		method = FakeMethodOK
+	}
+	return Authorization{
+		Reference: fmt.Sprintf("%s%s_%d", fakePrefix, strings.TrimPrefix(method, "tok_"), req.PaymentID),
+		Pending:   req.Method == FakeMethodAsync,
+	}, nil
+}
+
+func (f *Fake) Capture(_ context.Context, reference string, _ int64) error {
+	if err := f.check(reference); err != nil {
+		return err
+	}
+	if strings.HasPrefix(reference, fakePrefix+strings.TrimPrefix(FakeMethodCaptureFails, "tok_")+"_") {
+		return fmt.Errorf("%w: capture rejected", domain.ErrPaymentDeclined)
+	}
+	return nil
+}
+
+func (f *Fake) Void(_ context.Context, reference string) error {
+	return f.check(reference)
+}
+
+func (f *Fake) Refund(_ context.Context, reference string, _ int64) error {
+	return f.check(reference)
+}
+
+// check rejects references the Fake did not hand out.
+func (f *Fake) check(reference string) error {
+	if !strings.HasPrefix(reference, fakePrefix) {
+		return fmt.Errorf("%w: unknown payment %q", domain.ErrPaymentProvider, reference)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hitanshu0729/order_go/internal/domain"
+)
+
+func TestVerify(t *testing.T) {
+	secret := []byte("whsec_test")
+	body := []byte(`{"type":"payment.captured","data":{"reference":"fake_async_1"}}`)
+	now := time.Unix(1700000000, 0)
+	sig := Sign(secret, now, body)
+
+	tests := []struct {
+		name    string
+		secret  []byte
+		header  string
+		body    []byte
+		now     time.Time
+		wantErr bool
+	}{
+		{"valid", secret, sig, body, now, false},
+		{"within tolerance", secret, sig, body, now.Add(4 * time.Minute), false},
+		{"stale", secret, sig, body, now.Add(6 * time.Minute), true},
+		{"from the future", secret, sig, body, now.Add(-6 * time.Minute), true},
+		{"tampered body", secret, sig, []byte(`{"type":"payment.failed"}`), now, true},
+		{"wrong secret", []byte("other"), sig, body, now, true},
+		{"no secret", nil, sig, body, now, true},
+		{"missing header", secret, "", body, now, true},
+		{"no signature", secret, "t=1700000000", body, now, true},
+		{"one of several signatures", secret, sig + ",v1=00ff", body, now, false},
+	}
+	for _, tt := range tests {
+		err := Verify(tt.secret, tt.header, tt.body, tt.now, 5*time.Minute)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: Verify() = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+		if err != nil && !errors.Is(err, domain.ErrInvalidSignature) {
+			t.Errorf("%s: Verify() = %v, want ErrInvalidSignature", tt.name, err)
+		}
+	}
+}
+
+func TestFake(t *testing.T) {
+	ctx := context.Background()
+	f := NewFake()
+
+	auth, err := f.Authorize(ctx, AuthorizeRequest{PaymentID: 7, Amount: 100, Currency: "INR"})
+	if err != nil || auth.Reference != "fake_ok_7" || auth.Pending {
+		t.Fatalf("Authorize() = %+v, %v; want fake_ok_7, not pending", auth, err)
+	}
+	if err := f.Capture(ctx, auth.Reference, 100); err != nil {
+		t.Errorf("Capture() = %v", err)
+	}
+	if err := f.Refund(ctx, auth.Reference, 50); err != nil {
+		t.Errorf("Refund() = %v", err)
+	}
+
+	if _, err := f.Authorize(ctx, AuthorizeRequest{PaymentID: 8, Method: FakeMethodDeclined}); !errors.Is(err, domain.ErrPaymentDeclined) {
+		t.Errorf("Authorize(declined) = %v, want ErrPaymentDeclined", err)
+	}
+
+	auth, _ = f.Authorize(ctx, AuthorizeRequest{PaymentID: 9, Method: FakeMethodCaptureFails})
+	if err := f.Capture(ctx, auth.Reference, 100); !errors.Is(err, domain.ErrPaymentDeclined) {
+		t.Errorf("Capture(%s) = %v, want ErrPaymentDeclined", auth.Reference, err)
+	}
+	if err := f.Void(ctx, auth.Reference); err != nil {
+		t.Errorf("Void() = %v", err)
+	}
+
+	auth, _ = f.Authorize(ctx, AuthorizeRequest{PaymentID: 10, Method: FakeMethodAsync})
+	if !auth.Pending || auth.Reference != "fake_async_10" {
+		t.Errorf("Authorize(async) = %+v, want pending fake_async_10", auth)
+	}
+
+	if err := f.Refund(ctx, "ch_123", 1); !errors.Is(err, domain.ErrPaymentProvider) {
+		t.Errorf("Refund(unknown) = %v, want ErrPaymentProvider", err)
+	}
+}
@@ -0,0 +1,43 @@
+// Package payments takes payment for orders through a payment provider.
+// A payment is authorized and captured when the customer pays; providers
+// that need the customer to complete the payment with them report the
+// outcome later through a signed webhook. Either way, capturing a payment is
+// what moves its order to paid.
+package payments
+
+import "context"
+
+// AuthorizeRequest asks a provider to hold Amount, in Currency's minor
+// units, on the customer's payment method. PaymentID identifies the
+// attempt, so a provider can treat a repeated request as the same one.
+type AuthorizeRequest struct {
+	PaymentID int64
+	OrderID   int64
+	Amount    int64
+	Currency  string
+	Method    string
+}
+
+// Authorization is a provider's answer to an AuthorizeRequest. Reference
+// names the payment in later calls and webhooks. A Pending authorization
+// is completed by the customer with the provider, which then reports the
+// outcome through a webhook.
+type Authorization struct {
+	Reference string
+	Pending   bool
+}
+
+// Provider is a payment gateway. Errors wrap domain.ErrPaymentDeclined when
+// the provider refused the request and domain.ErrPaymentProvider when it
+// could not process it.
+type Provider interface {
+	// Name identifies the provider on payment records.
+	Name() string
+	Authorize(ctx context.Context, req AuthorizeRequest) (Authorization, error)
+	// Capture takes amount of an authorized payment.
+	Capture(ctx context.Context, reference string, amount int64) error
+	// Void releases an authorized payment that will not be captured.
+	Void(ctx context.Context, reference string) error
+	// Refund returns amount of a captured payment to the customer.
+	Refund(ctx context.Context, reference string, amount int64) error
+}
@@ -0,0 +1,260 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/hitanshu0729/order_go/internal/domain"
+	"github.com/hitanshu0729/order_go/internal/metrics"
+	"github.com/hitanshu0729/order_go/internal/models"
+	"github.com/hitanshu0729/order_go/internal/storage/sqlite"
+)
+
+// Service pays orders through a Provider and keeps their payment records.
+type Service struct {
+	repo      *sqlite.Repo
+	provider  Provider
+	secret    []byte
+	tolerance time.Duration
+}
+
+// NewService returns a Service taking payments through provider and
+// accepting webhooks signed with secret up to tolerance old.
+func NewService(repo *sqlite.Repo, provider Provider, secret []byte, tolerance time.Duration) *Service {
+	return &Service{repo: repo, provider: provider, secret: secret, tolerance: tolerance}
+}
+
+// Pay charges the grand total of a pending order to the payment method.
+// A non-zero version must match the order's. The returned payment is
+// captured, and the order paid, unless the provider left it pending for a
+// webhook to complete.
+func (s *Service) Pay(ctx context.Context, orderID, version int64, method string) (*models.Payment, error) {
+	p := &models.Payment{OrderID: orderID, Provider: s.provider.Name(), Method: method}
+	if err := s.repo.CreatePayment(ctx, p, version); err != nil {
+		return nil, err
+	}
+	log := slog.With("payment_id", p.ID, "order_id", orderID, "provider", p.Provider)
+
+	auth, err := s.provider.Authorize(ctx, AuthorizeRequest{
+		PaymentID: p.ID,
+		OrderID:   orderID,
+		Amount:    p.Amount,
+		Currency:  p.Currency,
+		Method:    method,
+	})
+	if err != nil {
+		log.WarnContext(ctx, "payment authorization failed", "error", err)
+		return nil, s.fail(ctx, p.ID, models.PaymentStatusPending, "", err)
+	}
+	if auth.Pending {
+		log.InfoContext(ctx, "payment pending with provider", "reference", auth.Reference)
+		return s.repo.UpdatePayment(ctx, p.ID, models.PaymentStatusPending, models.PaymentStatusPending, auth.Reference, "")
+	}
+	if p, err = s.repo.UpdatePayment(ctx, p.ID, models.PaymentStatusPending, models.PaymentStatusAuthorized, auth.Reference, ""); err != nil {
+		return nil, err
+	}
+
+	if err := s.provider.Capture(ctx, p.Reference, p.Amount); err != nil {
+		log.WarnContext(ctx, "payment capture failed, voiding authorization", "error", err)
+		if verr := s.provider.Void(ctx, p.Reference); verr != nil {
+			log.ErrorContext(ctx, "failed to void authorization", "error", verr)
+		}
+		return nil, s.fail(ctx, p.ID, models.PaymentStatusAuthorized, models.PaymentStatusVoided, err)
+	}
+	return s.capture(ctx, p, models.PaymentStatusAuthorized)
+}
+
+// capture records payment p, taken by the provider while in status from,
+// as captured and pays its order. If the order can no longer be paid, the
+// payment is refunded in full instead.
+func (s *Service) capture(ctx context.Context, p *models.Payment, from string) (*models.Payment, error) {
+	captured, err := s.repo.CapturePayment(ctx, p.ID, from)
+	if err == nil {
+		metrics.OrderPaid(captured.Currency, captured.Amount)
+		slog.InfoContext(ctx, "order paid", "order_id", captured.OrderID, "payment_id", captured.ID, "amount", captured.Amount)
+		return captured, nil
+	}
+	if !unpayable(err) {
+		return nil, err
+	}
+
+	slog.WarnContext(ctx, "order cannot be paid, refunding payment", "order_id", p.OrderID, "payment_id", p.ID, "error", err)
+	if _, uerr := s.repo.UpdatePayment(ctx, p.ID, from, models.PaymentStatusCaptured, "", ""); uerr != nil {
+		return nil, uerr
+	}
+	if _, rerr := s.refund(ctx, p.ID, p.Amount, "refunded automatically: "+err.Error()); rerr != nil {
+		slog.ErrorContext(ctx, "failed to refund unpayable order", "payment_id", p.ID, "error", rerr)
+	}
+	return nil, err
+}
+
+// unpayable reports whether err means the order of a payment cannot be
+// paid any more, because it was cancelled or deleted or its coupon can no
+// longer be redeemed.
+func unpayable(err error) bool {
+	return errors.Is(err, domain.ErrInvalidOrderStatus) ||
+		errors.Is(err, domain.ErrOrderNotFound) ||
+		errors.Is(err, domain.ErrCouponInactive) ||
+		errors.Is(err, domain.ErrCouponUsageLimit)
+}
+
+// fail records the payment, in status from, as failed, or as to when
+// given, and returns cause.
+func (s *Service) fail(ctx context.Context, id int64, from, to string, cause error) error {
+	if to == "" {
+		to = models.PaymentStatusFailed
+	}
+	if _, err := s.repo.UpdatePayment(ctx, id, from, to, "", cause.Error()); err != nil {
+		slog.ErrorContext(ctx, "failed to record payment failure", "payment_id", id, "error", err)
+	}
+	return cause
+}
+
+// Get returns a payment by id.
+func (s *Service) Get(ctx context.Context, id int64) (*models.Payment, error) {
+	return s.repo.GetPayment(ctx, id)
+}
+
+// OrderPayments returns an order's payments, oldest first.
+func (s *Service) OrderPayments(ctx context.Context, orderID int64) ([]*models.Payment, error) {
+	return s.repo.ListOrderPayments(ctx, orderID)
+}
+
+// Refund returns amount of a captured payment to the customer, or all that
+// is left of it when amount is 0.
+func (s *Service) Refund(ctx context.Context, id, amount int64) (*models.Payment, error) {
+	return s.refund(ctx, id, amount, "")
+}
+
+func (s *Service) refund(ctx context.Context, id, amount int64, reason string) (*models.Payment, error) {
+	p, err := s.repo.GetPayment(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if p.Status != models.PaymentStatusCaptured {
+		return nil, fmt.Errorf("%w: only captured payments can be refunded, payment is %s", domain.ErrInvalidPaymentStatus, p.Status)
+	}
+	left := p.Amount - p.RefundedAmount
+	if amount == 0 {
+		amount = left
+	}
+	if amount > left {
+		return nil, fmt.Errorf("%w: %d of %d left to refund", domain.ErrInvalidRefund, left, p.Amount)
+	}
+	// Record the refund first, so that a concurrent one for the same money
+	// is refused before it reaches the provider.
+	refunded, err := s.repo.RefundPayment(ctx, id, amount, reason)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.provider.Refund(ctx, p.Reference, amount); err != nil {
+		slog.WarnContext(ctx, "payment refund failed, releasing it", "payment_id", p.ID, "amount", amount, "error", err)
+		if _, rerr := s.repo.ReleaseRefund(ctx, id, amount, err.Error()); rerr != nil {
+			slog.ErrorContext(ctx, "failed to release refund", "payment_id", p.ID, "amount", amount, "error", rerr)
+		}
+		return nil, err
+	}
+	slog.InfoContext(ctx, "payment refunded", "payment_id", p.ID, "order_id", p.OrderID, "amount", amount)
+	return refunded, nil
+}
+
+// CancelOrder cancels an order, with the rules of sqlite.Repo.CancelOrder,
+// and refunds everything captured for it. The refunds are recorded with
+// the cancellation; one the provider fails to make is released, leaving
+// its payment captured with the provider's error so staff can refund it
+// again. The order stays cancelled either way.
+func (s *Service) CancelOrder(ctx context.Context, orderID, version int64, reason string) error {
+	refunds, err := s.repo.CancelOrder(ctx, orderID, version, reason)
+	if err != nil {
+		return err
+	}
+	for _, p := range refunds {
+		amount := p.Amount - p.RefundedAmount
+		if err := s.provider.Refund(ctx, p.Reference, amount); err != nil {
+			slog.ErrorContext(ctx, "refund of cancelled order failed, releasing it", "payment_id", p.ID, "order_id", orderID, "amount", amount, "error", err)
+			if _, rerr := s.repo.ReleaseRefund(ctx, p.ID, amount, "refund of cancelled order failed: "+err.Error()); rerr != nil {
+				slog.ErrorContext(ctx, "failed to release refund", "payment_id", p.ID, "amount", amount, "error", rerr)
+			}
+			continue
+		}
+		slog.InfoContext(ctx, "payment refunded", "payment_id", p.ID, "order_id", orderID, "amount", amount)
+	}
+	return nil
+}
+
+// Void cancels a payment that is still pending with the provider, so the
+// order can be paid again.
+func (s *Service) Void(ctx context.Context, id int64) (*models.Payment, error) {
+	p, err := s.repo.GetPayment(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if p.Status != models.PaymentStatusPending || p.Reference == "" {
+		return nil, fmt.Errorf("%w: only payments pending with the provider can be voided, payment is %s", domain.ErrInvalidPaymentStatus, p.Status)
+	}
+	if err := s.provider.Void(ctx, p.Reference); err != nil {
+		return nil, err
+	}
+	return s.repo.UpdatePayment(ctx, id, models.PaymentStatusPending, models.PaymentStatusVoided, "", "")
+}
+
+// HandleWebhook verifies a provider webhook against its SignatureHeader
+// value and applies it. A payment.captured event pays the order of a
+// pending payment, and a payment.failed event fails it. Events for
+// payments that already have that outcome are ignored, so redelivery is
+// harmless, as are event types the service does not handle.
+func (s *Service) HandleWebhook(ctx context.Context, signature string, body []byte) error {
+	if err := Verify(s.secret, signature, body, time.Now(), s.tolerance); err != nil {
+		return err
+	}
+	var event WebhookEvent
+	if err := json.Unmarshal(body, &event); err != nil || event.Type == "" {
+		return fmt.Errorf("%w: webhook is not a payment event", domain.ErrInvalidPayload)
+	}
+	log := slog.With("event_id", event.ID, "type", event.Type, "reference", event.Data.Reference)
+
+	var target string
+	switch event.Type {
+	case EventPaymentCaptured:
+		target = models.PaymentStatusCaptured
+	case EventPaymentFailed:
+		target = models.PaymentStatusFailed
+	default:
+		log.DebugContext(ctx, "ignoring payment webhook")
+		return nil
+	}
+	if event.Data.Reference == "" {
+		return fmt.Errorf("%w: webhook names no payment", domain.ErrInvalidPayload)
+	}
+	p, err := s.repo.GetPaymentByReference(ctx, s.provider.Name(), event.Data.Reference)
+	if err != nil {
+		return err
+	}
+	if p.Status == target {
+		log.InfoContext(ctx, "payment webhook already applied", "payment_id", p.ID)
+		return nil
+	}
+	log.InfoContext(ctx, "applying payment webhook", "payment_id", p.ID, "status", p.Status)
+
+	if target == models.PaymentStatusFailed {
+		reason := event.Data.Reason
+		if reason == "" {
+			reason = "failed with the provider"
+		}
+		_, err := s.repo.UpdatePayment(ctx, p.ID, models.PaymentStatusPending, models.PaymentStatusFailed, "", reason)
+		return err
+	}
+	if p.Status != models.PaymentStatusPending {
+		return fmt.Errorf("%w: payment must be pending, payment is %s", domain.ErrInvalidPaymentStatus, p.Status)
+	}
+	_, err = s.capture(ctx, p, models.PaymentStatusPending)
+	if unpayable(err) {
+		// The payment was refunded; the provider has nothing to retry.
+		return nil
+	}
+	return err
+}
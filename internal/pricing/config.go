@@ -0,0 +1,56 @@
+package pricing
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+// TaxComponent is one named tax levied in a jurisdiction, e.g. CGST at "0.09".
+type TaxComponent struct {
+	Name string `json:"name"`
+	Rate string `json:"rate"`
+}
+
+// ShippingRule is a flat shipping fee waived above a threshold. Both values
+// are in the base currency's smallest unit and converted per order.
+type ShippingRule struct {
+	FlatFee   int64 `json:"flat_fee"`
+	FreeAbove int64 `json:"free_above"`
+}
+
+// Config holds tax and shipping rules, loaded from a local JSON file.
+type Config struct {
+	DefaultJurisdiction string                    `json:"default_jurisdiction"`
+	Tax                 map[string][]TaxComponent `json:"tax"`
+	Shipping            ShippingRule              `json:"shipping"`
+}
+
+// LoadConfig reads pricing rules from path.
+func LoadConfig(path string) (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for jurisdiction, components := range cfg.Tax {
+		for _, tc := range components {
+			if r, ok := new(big.Rat).SetString(tc.Rate); !ok || r.Sign() < 0 {
+				return cfg, fmt.Errorf("parse %s: invalid %s rate %q for %s", path, tc.Name, tc.Rate, jurisdiction)
+			}
+		}
+	}
+	if cfg.DefaultJurisdiction != "" {
+		if _, ok := cfg.Tax[cfg.DefaultJurisdiction]; !ok {
+			return cfg, fmt.Errorf("parse %s: default jurisdiction %q has no tax rates", path, cfg.DefaultJurisdiction)
+		}
+	}
+	if cfg.Shipping.FlatFee < 0 || cfg.Shipping.FreeAbove < 0 {
+		return cfg, fmt.Errorf("parse %s: shipping amounts must not be negative", path)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,219 @@
+// Package pricing turns an order's lines into a price breakdown: subtotal,
+// line and order discounts, tax per jurisdiction, shipping and grand total.
+package pricing
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hitanshu0729/order_go/internal/money"
+)
+
+// ErrUnknownJurisdiction indicates a tax jurisdiction with no configured rates.
+var ErrUnknownJurisdiction = errors.New("unknown tax jurisdiction")
+
+// Line is one order line in the order currency.
+type Line struct {
+	ItemID    int64
+	ProductID int64
+	Quantity  int64
+	UnitPrice int64
+}
+
+// Discount is a reduction in the order currency. A discount with a
+// ProductID applies to that product's lines, otherwise to the whole order.
+type Discount struct {
+	Code        string `json:"code"`
+	Description string `json:"description,omitempty"`
+	ProductID   int64  `json:"product_id,omitempty"`
+	Amount      int64  `json:"amount"`
+}
+
+// Input is everything the engine needs to price an order.
+type Input struct {
+	Currency     string
+	ExchangeRate string
+	Jurisdiction string
+	Lines        []Line
+	Discounts    []Discount
+}
+
+type LineBreakdown struct {
+	ItemID    int64 `json:"item_id,omitempty"`
+	ProductID int64 `json:"product_id"`
+	Quantity  int64 `json:"quantity"`
+	UnitPrice int64 `json:"unit_price"`
+	Gross     int64 `json:"gross"`
+	Discount  int64 `json:"discount"`
+	Net       int64 `json:"net"`
+}
+
+type TaxLine struct {
+	Jurisdiction string `json:"jurisdiction"`
+	Name         string `json:"name"`
+	Rate         string `json:"rate"`
+	Taxable      int64  `json:"taxable"`
+	Amount       int64  `json:"amount"`
+}
+
+// Breakdown is the priced order. All amounts are in Currency's smallest unit.
+type Breakdown struct {
+	Currency           string          `json:"currency"`
+	Lines              []LineBreakdown `json:"lines"`
+	Subtotal           int64           `json:"subtotal"`
+	LineDiscountTotal  int64           `json:"line_discount_total"`
+	OrderDiscounts     []Discount      `json:"order_discounts"`
+	OrderDiscountTotal int64           `json:"order_discount_total"`
+	DiscountTotal      int64           `json:"discount_total"`
+	Taxes              []TaxLine       `json:"taxes"`
+	TaxTotal           int64           `json:"tax_total"`
+	Shipping           int64           `json:"shipping"`
+	GrandTotal         int64           `json:"grand_total"`
+}
+
+type Engine struct {
+	cfg  Config
+	base string
+}
+
+// NewEngine builds an engine whose shipping rules are expressed in base.
+func NewEngine(cfg Config, base string) *Engine {
+	return &Engine{cfg: cfg, base: base}
+}
+
+// Base is the currency configured amounts are expressed in.
+func (e *Engine) Base() string {
+	return e.base
+}
+
+// DefaultJurisdiction is used for orders created without one.
+func (e *Engine) DefaultJurisdiction() string {
+	return e.cfg.DefaultJurisdiction
+}
+
+// HasJurisdiction reports whether tax rates exist for j. The empty
+// jurisdiction is always valid and levies no tax.
+func (e *Engine) HasJurisdiction(j string) bool {
+	if j == "" {
+		return true
+	}
+	_, ok := e.cfg.Tax[j]
+	return ok
+}
+
+// Price computes the breakdown for in. Discounts never push a line or the
+// order below zero; tax is charged on the discounted amount and shipping is
+// neither discounted nor taxed.
+func (e *Engine) Price(in Input) (Breakdown, error) {
+	b := Breakdown{
+		Currency:       in.Currency,
+		Lines:          make([]LineBreakdown, 0, len(in.Lines)),
+		OrderDiscounts: []Discount{},
+		Taxes:          []TaxLine{},
+	}
+
+	for _, l := range in.Lines {
+		gross := l.UnitPrice * l.Quantity
+		b.Lines = append(b.Lines, LineBreakdown{
+			ItemID:    l.ItemID,
+			ProductID: l.ProductID,
+			Quantity:  l.Quantity,
+			UnitPrice: l.UnitPrice,
+			Gross:     gross,
+			Net:       gross,
+		})
+		b.Subtotal += gross
+	}
+
+	var orderDiscounts []Discount
+	for _, d := range in.Discounts {
+		if d.Amount <= 0 {
+			continue
+		}
+		if d.ProductID == 0 {
+			orderDiscounts = append(orderDiscounts, d)
+			continue
+		}
+		remaining := d.Amount
+		for i := range b.Lines {
+			if remaining == 0 {
+				break
+			}
+			l := &b.Lines[i]
+			if l.ProductID != d.ProductID {
+				continue
+			}
+			applied := min(remaining, l.Net)
+			l.Discount += applied
+			l.Net -= applied
+			remaining -= applied
+			b.LineDiscountTotal += applied
+		}
+	}
+
+	net := b.Subtotal - b.LineDiscountTotal
+	for _, d := range orderDiscounts {
+		applied := min(d.Amount, net-b.OrderDiscountTotal)
+		if applied <= 0 {
+			continue
+		}
+		d.Amount = applied
+		b.OrderDiscounts = append(b.OrderDiscounts, d)
+		b.OrderDiscountTotal += applied
+	}
+	b.DiscountTotal = b.LineDiscountTotal + b.OrderDiscountTotal
+	taxable := net - b.OrderDiscountTotal
+
+	if in.Jurisdiction != "" {
+		components, ok := e.cfg.Tax[in.Jurisdiction]
+		if !ok {
+			return b, fmt.Errorf("%w: %s", ErrUnknownJurisdiction, in.Jurisdiction)
+		}
+		for _, tc := range components {
+			tax, err := money.MulRate(money.New(taxable, in.Currency), tc.Rate)
+			if err != nil {
+				return b, err
+			}
+			b.Taxes = append(b.Taxes, TaxLine{
+				Jurisdiction: in.Jurisdiction,
+				Name:         tc.Name,
+				Rate:         tc.Rate,
+				Taxable:      taxable,
+				Amount:       tax.Amount,
+			})
+			b.TaxTotal += tax.Amount
+		}
+	}
+
+	if len(b.Lines) > 0 {
+		shipping, err := e.shipping(in, taxable)
+		if err != nil {
+			return b, err
+		}
+		b.Shipping = shipping
+	}
+
+	b.GrandTotal = taxable + b.TaxTotal + b.Shipping
+	return b, nil
+}
+
+func (e *Engine) shipping(in Input, taxable int64) (int64, error) {
+	rule := e.cfg.Shipping
+	if rule.FlatFee == 0 {
+		return 0, nil
+	}
+	fee, err := money.Convert(money.New(rule.FlatFee, e.base), in.Currency, in.ExchangeRate)
+	if err != nil {
+		return 0, err
+	}
+	if rule.FreeAbove > 0 {
+		threshold, err := money.Convert(money.New(rule.FreeAbove, e.base), in.Currency, in.ExchangeRate)
+		if err != nil {
+			return 0, err
+		}
+		if taxable >= threshold.Amount {
+			return 0, nil
+		}
+	}
+	return fee.Amount, nil
+}
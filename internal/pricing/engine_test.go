@@ -0,0 +1,169 @@
+package pricing
+
+import (
+	"errors"
+	"testing"
+)
+
+func testEngine() *Engine {
+	return NewEngine(Config{
+		DefaultJurisdiction: "IN-KA",
+		Tax: map[string][]TaxComponent{
+			"IN-KA": {{Name: "CGST", Rate: "0.09"}, {Name: "SGST", Rate: "0.09"}},
+		},
+		Shipping: ShippingRule{FlatFee: 5000, FreeAbove: 100000},
+	}, "INR")
+}
+
+func TestPriceBreakdown(t *testing.T) {
+	b, err := testEngine().Price(Input{
+		Currency:     "INR",
+		ExchangeRate: "1",
+		Jurisdiction: "IN-KA",
+		Lines: []Line{
+			{ProductID: 1, Quantity: 2, UnitPrice: 10000},
+			{ProductID: 2, Quantity: 1, UnitPrice: 5000},
+		},
+		Discounts: []Discount{
+			{Code: "LINE", ProductID: 1, Amount: 2000},
+			{Code: "ORDER", Amount: 1000},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b.Subtotal != 25000 {
+		t.Errorf("Subtotal = %d, want 25000", b.Subtotal)
+	}
+	if b.Lines[0].Net != 18000 || b.Lines[0].Discount != 2000 {
+		t.Errorf("line 0 = %+v, want net 18000 discount 2000", b.Lines[0])
+	}
+	if b.DiscountTotal != 3000 {
+		t.Errorf("DiscountTotal = %d, want 3000", b.DiscountTotal)
+	}
+	// 22000 taxable at 9% + 9%
+	if b.TaxTotal != 3960 || len(b.Taxes) != 2 {
+		t.Errorf("taxes = %+v total %d, want two lines totalling 3960", b.Taxes, b.TaxTotal)
+	}
+	if b.Shipping != 5000 {
+		t.Errorf("Shipping = %d, want 5000", b.Shipping)
+	}
+	if b.GrandTotal != 22000+3960+5000 {
+		t.Errorf("GrandTotal = %d, want %d", b.GrandTotal, 22000+3960+5000)
+	}
+}
+
+func TestPriceDiscountsNeverGoNegative(t *testing.T) {
+	b, err := testEngine().Price(Input{
+		Currency:     "INR",
+		ExchangeRate: "1",
+		Lines:        []Line{{ProductID: 1, Quantity: 1, UnitPrice: 1000}},
+		Discounts: []Discount{
+			{Code: "LINE", ProductID: 1, Amount: 5000},
+			{Code: "ORDER", Amount: 5000},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.LineDiscountTotal != 1000 || b.OrderDiscountTotal != 0 {
+		t.Errorf("discounts = line %d order %d, want 1000 and 0", b.LineDiscountTotal, b.OrderDiscountTotal)
+	}
+	if b.GrandTotal != 5000 {
+		t.Errorf("GrandTotal = %d, want shipping only (5000)", b.GrandTotal)
+	}
+}
+
+func TestPriceFreeShippingInOrderCurrency(t *testing.T) {
+	// 100000 paise threshold is 1200 cents at 0.012
+	b, err := testEngine().Price(Input{
+		Currency:     "USD",
+		ExchangeRate: "0.012",
+		Lines:        []Line{{ProductID: 1, Quantity: 1, UnitPrice: 1200}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Shipping != 0 {
+		t.Errorf("Shipping = %d, want 0 above threshold", b.Shipping)
+	}
+
+	b, err = testEngine().Price(Input{
+		Currency:     "USD",
+		ExchangeRate: "0.012",
+		Lines:        []Line{{ProductID: 1, Quantity: 1, UnitPrice: 1199}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Shipping != 60 {
+		t.Errorf("Shipping = %d, want 60", b.Shipping)
+	}
+}
+
+func TestPriceEmptyOrder(t *testing.T) {
+	b, err := testEngine().Price(Input{Currency: "INR", ExchangeRate: "1", Jurisdiction: "IN-KA"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.GrandTotal != 0 || b.Shipping != 0 {
+		t.Errorf("empty order = %+v, want zero total", b)
+	}
+}
+
+func TestPriceUnknownJurisdiction(t *testing.T) {
+	_, err := testEngine().Price(Input{Currency: "INR", ExchangeRate: "1", Jurisdiction: "XX"})
+	if !errors.Is(err, ErrUnknownJurisdiction) {
+		t.Errorf("got %v, want ErrUnknownJurisdiction", err)
+	}
+}
+
+func TestRefund(t *testing.T) {
+	b, err := testEngine().Price(Input{
+		Currency:     "INR",
+		ExchangeRate: "1",
+		Jurisdiction: "IN-KA",
+		Lines: []Line{
+			{ItemID: 11, ProductID: 1, Quantity: 2, UnitPrice: 10000},
+			{ItemID: 12, ProductID: 2, Quantity: 1, UnitPrice: 5000},
+		},
+		Discounts: []Discount{
+			{Code: "LINE", ProductID: 1, Amount: 2000},
+			{Code: "ORDER", Amount: 1000},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		itemID, quantity, want int64
+	}{
+		// net 18000, less 782 of the order discount, plus 3099 tax
+		{11, 1, 10158},
+		{11, 2, 20317},
+		// net 5000, less 217 of the order discount, plus 860 tax
+		{12, 1, 5643},
+	}
+	for _, tt := range tests {
+		got, err := b.Refund(tt.itemID, tt.quantity)
+		if err != nil {
+			t.Errorf("Refund(%d, %d): %v", tt.itemID, tt.quantity, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Refund(%d, %d) = %d, want %d", tt.itemID, tt.quantity, got, tt.want)
+		}
+	}
+	if full := int64(20317 + 5643); full > b.GrandTotal-b.Shipping {
+		t.Errorf("full refund %d exceeds %d paid for goods", full, b.GrandTotal-b.Shipping)
+	}
+
+	if _, err := b.Refund(99, 1); !errors.Is(err, ErrNoLine) {
+		t.Errorf("unknown item: err = %v, want ErrNoLine", err)
+	}
+	if _, err := b.Refund(11, 3); err == nil {
+		t.Error("quantity above the line's: got no error")
+	}
+}
@@ -0,0 +1,48 @@
+package pricing
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// ErrNoLine indicates an order item that is not part of a breakdown.
+var ErrNoLine = errors.New("item is not in the order's pricing")
+
+// Refund returns what the customer paid for quantity units of an order
+// item: the line's net amount less its share of order discounts, plus its
+// share of tax. Shares are proportional to the line's net amount and
+// rounded down, so refunds for all of an order never exceed its grand total
+// less shipping, which is not refunded.
+func (b Breakdown) Refund(itemID, quantity int64) (int64, error) {
+	var line *LineBreakdown
+	var net int64
+	for i := range b.Lines {
+		if b.Lines[i].ItemID == itemID {
+			line = &b.Lines[i]
+		}
+		net += b.Lines[i].Net
+	}
+	if line == nil {
+		return 0, fmt.Errorf("%w: %d", ErrNoLine, itemID)
+	}
+	if quantity <= 0 || quantity > line.Quantity {
+		return 0, fmt.Errorf("quantity %d out of range for item %d", quantity, itemID)
+	}
+	if net == 0 {
+		return 0, nil
+	}
+
+	taxable := line.Net - mulDiv(b.OrderDiscountTotal, line.Net, net)
+	paid := taxable
+	if orderTaxable := net - b.OrderDiscountTotal; orderTaxable > 0 {
+		paid += mulDiv(b.TaxTotal, taxable, orderTaxable)
+	}
+	return mulDiv(paid, quantity, line.Quantity), nil
+}
+
+// mulDiv returns a*b/c rounded down, without overflowing on the way.
+func mulDiv(a, b, c int64) int64 {
+	v := new(big.Int).Mul(big.NewInt(a), big.NewInt(b))
+	return v.Quo(v, big.NewInt(c)).Int64()
+}
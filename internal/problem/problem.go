@@ -0,0 +1,282 @@
+// Package problem renders errors as RFC 7807 problem details.
+//
+// Handlers report failures with c.Error and return; Middleware turns the
+// last error into an application/problem+json response. Domain sentinel
+// errors map to a fixed status and a stable machine-readable code, so
+// clients can branch on "code" without parsing messages.
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/hitanshu0729/order_go/internal/domain"
+	"github.com/hitanshu0729/order_go/internal/money"
+	"github.com/hitanshu0729/order_go/internal/pricing"
+)
+
+const ContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details document extended with a stable
+// error code and, for validation failures, the offending fields.
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     string       `json:"code"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError describes one invalid request field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error is a failure with an explicit status and code, for conditions that
+// have no domain sentinel such as malformed path parameters or missing
+// permissions.
+type Error struct {
+	Status int
+	Code   string
+	Detail string
+}
+
+func (e *Error) Error() string {
+	return e.Detail
+}
+
+// New returns an error rendered with the given status, code and detail.
+func New(status int, code, detail string) *Error {
+	return &Error{Status: status, Code: code, Detail: detail}
+}
+
+// BadRequest reports a malformed request, e.g. a non-numeric id.
+func BadRequest(code, detail string) *Error {
+	return New(http.StatusBadRequest, code, detail)
+}
+
+// Unprocessable reports a well-formed request that breaks a business rule.
+func Unprocessable(code, detail string) *Error {
+	return New(http.StatusUnprocessableEntity, code, detail)
+}
+
+func Unauthorized(detail string) *Error {
+	return New(http.StatusUnauthorized, "unauthorized", detail)
+}
+
+func Forbidden(detail string) *Error {
+	return New(http.StatusForbidden, "forbidden", detail)
+}
+
+func NotFound(code, detail string) *Error {
+	return New(http.StatusNotFound, code, detail)
+}
+
+// mapping ties a sentinel error to its HTTP status and code.
+type mapping struct {
+	err    error
+	status int
+	code   string
+}
+
+var mappings = []mapping{
+	{domain.ErrUserNotFound, http.StatusNotFound, "user_not_found"},
+	{domain.ErrProductNotFound, http.StatusNotFound, "product_not_found"},
+	{domain.ErrProductPriceNotFound, http.StatusNotFound, "product_price_not_found"},
+	{domain.ErrOrderNotFound, http.StatusNotFound, "order_not_found"},
+	{domain.ErrOrderItemNotFound, http.StatusNotFound, "order_item_not_found"},
+	{domain.ErrCouponNotFound, http.StatusNotFound, "coupon_not_found"},
+	{domain.ErrAPIKeyNotFound, http.StatusNotFound, "api_key_not_found"},
+	{domain.ErrVariantNotFound, http.StatusNotFound, "variant_not_found"},
+	{domain.ErrCategoryNotFound, http.StatusNotFound, "category_not_found"},
+	{domain.ErrImportNotFound, http.StatusNotFound, "import_not_found"},
+	{domain.ErrJobNotFound, http.StatusNotFound, "job_not_found"},
+	{domain.ErrReturnNotFound, http.StatusNotFound, "return_not_found"},
+	{domain.ErrPaymentNotFound, http.StatusNotFound, "payment_not_found"},
+	{domain.ErrShipmentNotFound, http.StatusNotFound, "shipment_not_found"},
+
+	{domain.ErrDuplicateEmail, http.StatusConflict, "duplicate_email"},
+	{domain.ErrDuplicateCouponCode, http.StatusConflict, "duplicate_coupon_code"},
+	{domain.ErrDuplicateSKU, http.StatusConflict, "duplicate_sku"},
+	{domain.ErrDuplicateCategorySlug, http.StatusConflict, "duplicate_category_slug"},
+	{domain.ErrInvalidOrderStatus, http.StatusConflict, "invalid_order_status"},
+	{domain.ErrOrderAlreadyProcessed, http.StatusConflict, "order_already_processed"},
+	{domain.ErrInsufficientStock, http.StatusConflict, "insufficient_stock"},
+	{domain.ErrCouponRedeemed, http.StatusConflict, "coupon_redeemed"},
+	{domain.ErrJobFinished, http.StatusConflict, "job_finished"},
+	{domain.ErrInvalidReturnStatus, http.StatusConflict, "invalid_return_status"},
+	{domain.ErrInvalidPaymentStatus, http.StatusConflict, "invalid_payment_status"},
+	{domain.ErrInvalidShipmentStatus, http.StatusConflict, "invalid_shipment_status"},
+
+	{domain.ErrVersionMismatch, http.StatusPreconditionFailed, "version_mismatch"},
+
+	{domain.ErrInvalidCoupon, http.StatusUnprocessableEntity, "invalid_coupon"},
+	{domain.ErrCouponInactive, http.StatusUnprocessableEntity, "coupon_inactive"},
+	{domain.ErrCouponUsageLimit, http.StatusUnprocessableEntity, "coupon_usage_limit"},
+	{domain.ErrCouponNotApplicable, http.StatusUnprocessableEntity, "coupon_not_applicable"},
+	{money.ErrUnknownCurrency, http.StatusUnprocessableEntity, "unsupported_currency"},
+	{money.ErrCurrencyMismatch, http.StatusUnprocessableEntity, "currency_mismatch"},
+	{pricing.ErrUnknownJurisdiction, http.StatusUnprocessableEntity, "unknown_tax_jurisdiction"},
+	{domain.ErrUnknownJobType, http.StatusUnprocessableEntity, "unknown_job_type"},
+	{domain.ErrInvalidReturn, http.StatusUnprocessableEntity, "invalid_return"},
+	{domain.ErrInvalidRefund, http.StatusUnprocessableEntity, "invalid_refund"},
+	{domain.ErrNothingToPay, http.StatusUnprocessableEntity, "nothing_to_pay"},
+	{domain.ErrInvalidShipment, http.StatusUnprocessableEntity, "invalid_shipment"},
+	{domain.ErrVariantRequired, http.StatusUnprocessableEntity, "variant_required"},
+
+	{domain.ErrPaymentDeclined, http.StatusPaymentRequired, "payment_declined"},
+
+	{domain.ErrInvalidPayload, http.StatusBadRequest, "invalid_payload"},
+	{domain.ErrInvalidSignature, http.StatusBadRequest, "invalid_signature"},
+
+	{domain.ErrDatabaseConnection, http.StatusServiceUnavailable, "database_unavailable"},
+	{domain.ErrKafkaConnection, http.StatusServiceUnavailable, "broker_unavailable"},
+	{domain.ErrPaymentProvider, http.StatusBadGateway, "payment_provider_error"},
+}
+
+// From converts err into a problem. Errors that are not recognised become
+// a 500 whose detail does not leak the underlying message.
+func From(err error) Problem {
+	var pe *Error
+	if errors.As(err, &pe) {
+		return newProblem(pe.Status, pe.Code, pe.Detail)
+	}
+	for _, m := range mappings {
+		if errors.Is(err, m.err) {
+			return newProblem(m.status, m.code, err.Error())
+		}
+	}
+
+	var verrs validator.ValidationErrors
+	if errors.As(err, &verrs) {
+		p := newProblem(http.StatusUnprocessableEntity, "validation_failed", "request body failed validation")
+		for _, fe := range verrs {
+			p.Errors = append(p.Errors, FieldError{Field: fieldName(fe), Message: fieldMessage(fe)})
+		}
+		return p
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return newProblem(http.StatusRequestEntityTooLarge, "payload_too_large",
+			fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit))
+	}
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return newProblem(http.StatusBadRequest, "malformed_json", "request body is not valid JSON")
+	case errors.As(err, &typeErr):
+		return newProblem(http.StatusBadRequest, "malformed_json",
+			fmt.Sprintf("%s must be a %s", typeErr.Field, typeErr.Type))
+	case errors.Is(err, io.EOF):
+		return newProblem(http.StatusBadRequest, "malformed_json", "request body is empty")
+	}
+
+	return newProblem(http.StatusInternalServerError, "internal_error", "an unexpected error occurred")
+}
+
+func newProblem(status int, code, detail string) Problem {
+	return Problem{
+		Type:   "urn:order_go:problem:" + code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// fieldName returns the JSON name of the invalid field, falling back to the
+// struct path when the tag is unavailable.
+func fieldName(fe validator.FieldError) string {
+	ns := fe.Namespace()
+	if i := strings.Index(ns, "."); i >= 0 {
+		ns = ns[i+1:]
+	}
+	return toSnake(ns)
+}
+
+func toSnake(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		if r >= 'A' && r <= 'Z' {
+			if i > 0 && s[i-1] != '.' && !(s[i-1] >= 'A' && s[i-1] <= 'Z') {
+				b.WriteByte('_')
+			}
+			r += 'a' - 'A'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "oneof":
+		return "must be one of: " + fe.Param()
+	case "min", "gte":
+		return "must be at least " + fe.Param()
+	case "gt":
+		return "must be greater than " + fe.Param()
+	case "len":
+		return "must have length " + fe.Param()
+	case "uppercase":
+		return "must be upper case"
+	}
+	return "failed " + fe.Tag() + " validation"
+}
+
+// Middleware renders the last error recorded on the context with c.Error
+// as a problem, unless the handler already wrote a response.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		Render(c, c.Errors.Last().Err)
+	}
+}
+
+// Render writes err as a problem response and aborts the chain.
+func Render(c *gin.Context, err error) {
+	p := From(err)
+	if p.Status >= http.StatusInternalServerError {
+		slog.ErrorContext(c.Request.Context(), "request failed",
+			"method", c.Request.Method, "path", c.Request.URL.Path, "error", err)
+	}
+	p.Instance = c.Request.URL.Path
+	c.Header("Content-Type", ContentType)
+	c.AbortWithStatusJSON(p.Status, p)
+}
+
+// Abort records err on the context and stops the handler chain. The
+// response is written by Middleware.
+func Abort(c *gin.Context, err error) {
+	_ = c.Error(err)
+	c.Abort()
+}
+
+// Recover is a gin.RecoveryFunc that answers panics with a 500 problem.
+func Recover(c *gin.Context, recovered any) {
+	Render(c, fmt.Errorf("panic: %v", recovered))
+}
+
+// NoRoute answers requests for unknown routes.
+func NoRoute(c *gin.Context) {
+	Render(c, NotFound("route_not_found", "no route for "+c.Request.Method+" "+c.Request.URL.Path))
+}
@@ -0,0 +1,78 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hitanshu0729/order_go/internal/domain"
+	"github.com/hitanshu0729/order_go/internal/money"
+)
+
+func TestFrom(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"sentinel", domain.ErrOrderNotFound, http.StatusNotFound, "order_not_found"},
+		{"wrapped sentinel", fmt.Errorf("%w: already shipped", domain.ErrInvalidOrderStatus), http.StatusConflict, "invalid_order_status"},
+		{"money sentinel", money.ErrUnknownCurrency, http.StatusUnprocessableEntity, "unsupported_currency"},
+		{"explicit", Forbidden("nope"), http.StatusForbidden, "forbidden"},
+		{"syntax error", json.Unmarshal([]byte("{"), &struct{}{}), http.StatusBadRequest, "malformed_json"},
+		{"unknown", errors.New("disk I/O error"), http.StatusInternalServerError, "internal_error"},
+	}
+	for _, tt := range tests {
+		p := From(tt.err)
+		if p.Status != tt.status || p.Code != tt.code {
+			t.Errorf("%s: got %d %s, want %d %s", tt.name, p.Status, p.Code, tt.status, tt.code)
+		}
+	}
+
+	if p := From(errors.New("disk I/O error")); strings.Contains(p.Detail, "disk") {
+		t.Errorf("internal error detail leaks the cause: %q", p.Detail)
+	}
+}
+
+func TestMiddlewareValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware())
+	r.POST("/users", func(c *gin.Context) {
+		var req struct {
+			Name      string `json:"name" binding:"required"`
+			UnitPrice int64  `json:"unit_price" binding:"gt=0"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Error(err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"unit_price":0}`)))
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want 422", rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, ContentType) {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	var p Problem
+	if err := json.Unmarshal(rr.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Code != "validation_failed" || p.Instance != "/users" || len(p.Errors) != 2 {
+		t.Fatalf("unexpected problem: %+v", p)
+	}
+	if p.Errors[0].Field != "name" || p.Errors[1].Field != "unit_price" {
+		t.Errorf("fields = %q, %q", p.Errors[0].Field, p.Errors[1].Field)
+	}
+}
@@ -0,0 +1,55 @@
+package retention
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/hitanshu0729/order_go/internal/storage/sqlite"
+)
+
+// Purger permanently removes soft-deleted rows once they are older than the
+// retention period.
+type Purger struct {
+	repo      *sqlite.Repo
+	retention time.Duration
+	interval  time.Duration
+}
+
+func NewPurger(repo *sqlite.Repo, retention, interval time.Duration) *Purger {
+	return &Purger{repo: repo, retention: retention, interval: interval}
+}
+
+// Start runs a purge immediately and then on every tick until ctx is
+// cancelled.
+func (p *Purger) Start(ctx context.Context) {
+	slog.Info("soft-delete purger started", "retention", p.retention, "interval", p.interval)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := p.Run(ctx); err != nil {
+			slog.Error("soft-delete purge failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			slog.Info("soft-delete purger stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Run performs a single purge pass.
+func (p *Purger) Run(ctx context.Context) (sqlite.PurgeResult, error) {
+	result, err := p.repo.PurgeDeleted(ctx, time.Now().Add(-p.retention))
+	if err != nil {
+		return result, err
+	}
+	slog.InfoContext(ctx, "purged soft-deleted rows",
+		"orders", result.Orders, "products", result.Products, "users", result.Users,
+	)
+	return result, nil
+}
@@ -0,0 +1,40 @@
+package server
+
+import "github.com/hitanshu0729/order_go/internal/limits"
+
+// listRoutes are the unpaginated list endpoints; they share one rate limit
+// bucket per client and a global concurrency cap.
+var listRoutes = []string{
+	"GET /api/v1/users",
+	"GET /api/v1/products",
+	"GET /api/v1/products/export",
+	"GET /api/v1/orders",
+	"GET /api/v1/orders/status/:status",
+	"GET /api/v1/coupons",
+	"GET /api/v1/inventory/reconciliation",
+}
+
+// addressLimits throttles each address before authentication, so that
+// guessing API keys or tokens is limited even though every guess fails
+// with 401. It is generous enough for several users behind one address.
+func addressLimits() limits.Config {
+	return limits.Config{Default: limits.PerMinute("address", 600, 120)}
+}
+
+// rateLimits is the central table of per-route rate limit policies.
+func rateLimits() limits.Config {
+	list := limits.PerMinute("list", 60, 20)
+	cfg := limits.Config{
+		Default: limits.PerMinute("default", 300, 60),
+		Routes: map[string]limits.Policy{
+			"POST /api/v1/auth/login": limits.PerMinute("login", 10, 5),
+			"POST /api/v1/users":      limits.PerMinute("signup", 5, 5),
+			"POST /api/v1/orders":     limits.PerMinute("create_order", 20, 5),
+			"POST /api/v1/checkout":   limits.PerMinute("create_order", 20, 5),
+		},
+	}
+	for _, route := range listRoutes {
+		cfg.Routes[route] = list
+	}
+	return cfg
+}
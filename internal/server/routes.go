@@ -1,60 +1,123 @@
 package server
 
 import (
-	"context"
-	"log"
 	"net/http"
+	"time"
 
+	"github.com/hitanshu0729/order_go/internal/auth"
 	"github.com/hitanshu0729/order_go/internal/handlers"
-	"github.com/hitanshu0729/order_go/internal/kafka"
-	"github.com/hitanshu0729/order_go/internal/storage/sqlite"
+	"github.com/hitanshu0729/order_go/internal/limits"
+	"github.com/hitanshu0729/order_go/internal/logging"
+	"github.com/hitanshu0729/order_go/internal/metrics"
+	"github.com/hitanshu0729/order_go/internal/problem"
+	"github.com/hitanshu0729/order_go/internal/tracing"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
 func (s *Server) RegisterRoutes() http.Handler {
-	r := gin.Default()
+	r := gin.New()
+	r.Use(
+		otelgin.Middleware(tracing.ServiceName),
+		logging.Middleware(),
+		metrics.Middleware(),
+		gin.CustomRecovery(problem.Recover),
+		problem.Middleware(),
+		limits.MaxBody(intFromEnv("MAX_BODY_BYTES", 1<<20), map[string]int64{
+			"POST /api/v1/products/import": intFromEnv("MAX_IMPORT_BYTES", 50<<20),
+		}),
+	)
+	r.NoRoute(problem.NoRoute)
 
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:5173"}, // Add your frontend URL
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
-		AllowHeaders:     []string{"Accept", "Authorization", "Content-Type"},
+		AllowHeaders:     []string{"Accept", "Authorization", "Content-Type", "X-API-Key", "If-Match", logging.HeaderRequestID},
+		ExposeHeaders:    []string{logging.HeaderRequestID, "ETag", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"},
 		AllowCredentials: true, // Enable cookies/auth
 	}))
 
+	Repo := s.repo
+
+	// Scraped by Prometheus; not part of the authenticated API.
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+
 	api := r.Group("/api/v1")
+	api.Use(limits.NewAddressRateLimiter(addressLimits()).Middleware())
+	api.Use(auth.Middleware(s.tokens, Repo,
+		"GET /api/v1/",
+		"GET /api/v1/health",
+		"GET /api/v1/exchange-rates",
+		"POST /api/v1/auth/login",
+		"POST /api/v1/users",
+		"POST /api/v1/payments/webhook",
+	))
+	api.Use(
+		limits.NewRateLimiter(rateLimits()).Middleware(),
+		limits.Concurrency(
+			int(intFromEnv("MAX_CONCURRENT_LIST_QUERIES", 8)),
+			durationFromEnv("LIST_QUERY_WAIT", 2*time.Second),
+			listRoutes...,
+		),
+	)
 	api.GET("/", s.HelloWorldHandler)
 	api.GET("/health", s.healthHandler)
+	api.GET("/exchange-rates", s.exchangeRatesHandler)
 
-	// User Routes
-	sqlDB, err := s.db.GetSqlDB()
-	if err != nil {
-		log.Fatal("Failed to get SQL DB:", err)
-	}
+	// Auth Routes
+	authHandler := handlers.NewAuthHandler(Repo, s.tokens)
+	authHandler.RegisterAuthRoutes(api)
 
-	Repo := sqlite.NewRepo(sqlDB)
+	// User Routes
 	userHandler := handlers.NewUserHandler(Repo)
 	userHandler.RegisterUserRoutes(api)
 
 	// Product Routes
-	productHandler := handlers.NewProductHandler(Repo)
+	productHandler := handlers.NewProductHandler(Repo, s.rates)
 	productHandler.RegisterProductRoutes(api)
 
+	// Job Routes
+	jobHandler := handlers.NewJobHandler(s.jobs)
+	jobHandler.RegisterJobRoutes(api)
+
+	// Product Import Routes
+	importHandler := handlers.NewImportHandler(Repo, s.importer, s.jobs, intFromEnv("IMPORT_SYNC_BYTES", 256<<10))
+	importHandler.RegisterImportRoutes(api)
+
+	// Category Routes
+	categoryHandler := handlers.NewCategoryHandler(Repo)
+	categoryHandler.RegisterCategoryRoutes(api)
+
 	// Order Routes
-	orderHandler := handlers.NewOrderHandler(Repo, s.KafkaProducer)
+	paymentService := loadPayments(Repo)
+	orderHandler := handlers.NewOrderHandler(Repo, s.KafkaProducer, s.rates, s.pricing, paymentService)
 	orderHandler.RegisterOrderRoutes(api)
 
-	inventoryConsumer := kafka.NewInventoryConsumer(Repo)
+	// Payment Routes
+	paymentHandler := handlers.NewPaymentHandler(paymentService, Repo)
+	paymentHandler.RegisterPaymentRoutes(api)
 
-	log.Println("Creating kafka consumer")
-	consumer := kafka.NewConsumer(
-		[]string{"localhost:9092"},
-		"order-service",
-	)
+	// Shipment Routes
+	shipmentHandler := handlers.NewShipmentHandler(Repo)
+	shipmentHandler.RegisterShipmentRoutes(api)
+
+	// Coupon Routes
+	couponHandler := handlers.NewCouponHandler(Repo)
+	couponHandler.RegisterCouponRoutes(api)
+
+	// Return Routes
+	returnHandler := handlers.NewReturnHandler(Repo)
+	returnHandler.RegisterReturnRoutes(api)
 
-	log.Println("Starting kafka consumer")
-	go consumer.Start(context.Background(), inventoryConsumer, s.DLQProducer)
+	// Inventory Routes
+	inventoryHandler := handlers.NewInventoryHandler(Repo, s.reconciler)
+	inventoryHandler.RegisterInventoryRoutes(api)
+
+	// Order Expiry Routes
+	orderExpiryHandler := handlers.NewOrderExpiryHandler(s.expirer)
+	orderExpiryHandler.RegisterOrderExpiryRoutes(api)
 
 	return r
 }
@@ -69,3 +132,7 @@ func (s *Server) HelloWorldHandler(c *gin.Context) {
 func (s *Server) healthHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, s.db.Health())
 }
+
+func (s *Server) exchangeRatesHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, s.rates)
+}
@@ -2,14 +2,26 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"io/fs"
+	"log/slog"
 	"net/http"
 	"os"
 	"strconv"
 	"time"
 
+	"github.com/hitanshu0729/order_go/internal/auth"
+	"github.com/hitanshu0729/order_go/internal/catalog"
+	"github.com/hitanshu0729/order_go/internal/inventory"
+	"github.com/hitanshu0729/order_go/internal/jobs"
 	"github.com/hitanshu0729/order_go/internal/kafka"
+	"github.com/hitanshu0729/order_go/internal/metrics"
+	"github.com/hitanshu0729/order_go/internal/money"
+	"github.com/hitanshu0729/order_go/internal/orders"
+	"github.com/hitanshu0729/order_go/internal/payments"
+	"github.com/hitanshu0729/order_go/internal/pricing"
+	"github.com/hitanshu0729/order_go/internal/storage/sqlite"
 	_ "github.com/joho/godotenv/autoload"
 
 	"github.com/hitanshu0729/order_go/internal/database"
@@ -23,21 +35,36 @@ type Server struct {
 	KafkaProducer *kafka.Producer
 
 	DLQProducer *kafka.DLQProducer
+
+	rates *money.Rates
+
+	pricing *pricing.Engine
+
+	tokens *auth.TokenService
+
+	repo *sqlite.Repo
+
+	jobs *jobs.Runner
+
+	importer *catalog.Importer
+
+	reconciler *inventory.Reconciler
+
+	expirer *orders.Expirer
 }
 
-func NewServer() *http.Server {
+// NewServer configures the HTTP server and the background workers, which
+// the caller starts alongside it.
+func NewServer() (*http.Server, *Workers) {
 	port, _ := strconv.Atoi(os.Getenv("PORT"))
 
-	log.Println("Creating kafka producer")
 	producer := kafka.NewProducer([]string{"localhost:9092"})
 	// defer producer.Close()
-	log.Println("Kafka producer created successfully.")
 
 	dlqproducer := kafka.NewDLQProducer([]string{"localhost:9092"})
 	// defer dlqproducer.Close()
-	log.Println("Kafka DLQ producer created successfully.")
+	slog.Info("kafka producers created")
 
-	log.Println("Started kafka consumer")
 	go func() {
 		time.Sleep(3 * time.Second)
 		err := producer.Publish(
@@ -46,12 +73,14 @@ func NewServer() *http.Server {
 			map[string]string{"msg": "hello from startup"},
 		)
 		if err != nil {
-			log.Println("startup publish failed:", err)
+			slog.Warn("startup publish failed", "error", err)
 		} else {
-			log.Println("startup publish success")
+			slog.Info("startup publish succeeded")
 		}
 	}()
 
+	rates := loadRates()
+
 	NewServer := &Server{
 		port: port,
 
@@ -60,9 +89,26 @@ func NewServer() *http.Server {
 		KafkaProducer: producer,
 
 		DLQProducer: dlqproducer,
+
+		rates: rates,
+
+		pricing: loadPricing(rates.Base),
+
+		tokens: loadAuth(),
+	}
+
+	slog.Info("database connected")
+
+	sqlDB, err := NewServer.db.GetSqlDB()
+	if err != nil {
+		fatal("failed to get SQL DB", err)
+	}
+	if err := metrics.RegisterDB(sqlDB, "sqlite"); err != nil {
+		fatal("failed to register database metrics", err)
 	}
+	NewServer.repo = sqlite.NewRepo(sqlDB, NewServer.pricing)
 
-	log.Println("Database connected successfully.")
+	workers := NewServer.newWorkers()
 
 	// Declare Server config
 	server := &http.Server{
@@ -73,7 +119,112 @@ func NewServer() *http.Server {
 		WriteTimeout: 30 * time.Second,
 	}
 
-	log.Printf("Server is running on port %d", NewServer.port)
+	slog.Info("server listening", "port", NewServer.port)
 
-	return server
+	return server, workers
+}
+
+// fatal logs a startup error and exits.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// durationFromEnv parses a time.Duration from the environment, falling back
+// to def when the variable is unset or invalid.
+func durationFromEnv(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		slog.Warn("invalid duration, using default", "key", key, "value", v, "default", def)
+		return def
+	}
+	return d
+}
+
+// intFromEnv parses a positive integer from the environment, falling back
+// to def when the variable is unset or invalid.
+func intFromEnv(key string, def int64) int64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n <= 0 {
+		slog.Warn("invalid integer, using default", "key", key, "value", v, "default", def)
+		return def
+	}
+	return n
+}
+
+// loadRates reads the exchange-rate table from EXCHANGE_RATES_FILE (default
+// exchange_rates.json). Without a file only the base currency is available.
+func loadRates() *money.Rates {
+	path := os.Getenv("EXCHANGE_RATES_FILE")
+	if path == "" {
+		path = "exchange_rates.json"
+	}
+	rates, err := money.LoadRates(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		slog.Warn("exchange rate file not found, only INR is available", "path", path)
+		return money.BaseOnly("INR")
+	}
+	if err != nil {
+		fatal("failed to load exchange rates", err)
+	}
+	slog.Info("loaded exchange rates", "base", rates.Base, "currencies", rates.Currencies())
+	return rates
+}
+
+// loadPricing reads tax and shipping rules from PRICING_CONFIG_FILE (default
+// pricing.json). Without a file orders carry no tax and ship free.
+func loadPricing(base string) *pricing.Engine {
+	path := os.Getenv("PRICING_CONFIG_FILE")
+	if path == "" {
+		path = "pricing.json"
+	}
+	cfg, err := pricing.LoadConfig(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		slog.Warn("pricing config not found, orders will carry no tax or shipping", "path", path)
+		return pricing.NewEngine(pricing.Config{}, base)
+	}
+	if err != nil {
+		fatal("failed to load pricing config", err)
+	}
+	slog.Info("loaded pricing config", "default_jurisdiction", cfg.DefaultJurisdiction)
+	return pricing.NewEngine(cfg, base)
+}
+
+// loadAuth configures JWT signing and verification from the JWT_* variables.
+func loadAuth() *auth.TokenService {
+	cfg := auth.ConfigFromEnv()
+	tokens, err := auth.NewTokenService(cfg)
+	if err != nil {
+		fatal("failed to configure authentication", err)
+	}
+	slog.Info("loaded auth config", "alg", cfg.Algorithm, "issuer", cfg.Issuer, "ttl", cfg.TokenTTL)
+	return tokens
+}
+
+// loadPayments configures the payment provider named by PAYMENTS_PROVIDER
+// (default and, for now, only "fake") and the webhook secret and tolerance
+// from PAYMENTS_WEBHOOK_SECRET and PAYMENTS_WEBHOOK_TOLERANCE.
+func loadPayments(repo *sqlite.Repo) *payments.Service {
+	var provider payments.Provider
+	switch name := os.Getenv("PAYMENTS_PROVIDER"); name {
+	case "", "fake":
+		provider = payments.NewFake()
+	default:
+		fatal("failed to configure payments", fmt.Errorf("unknown payment provider %q", name))
+	}
+	secret := os.Getenv("PAYMENTS_WEBHOOK_SECRET")
+	if secret == "" {
+		slog.Warn("PAYMENTS_WEBHOOK_SECRET not set, payment webhooks will be rejected")
+	}
+	tolerance := durationFromEnv("PAYMENTS_WEBHOOK_TOLERANCE", 5*time.Minute)
+	slog.Info("loaded payments config", "provider", provider.Name(), "webhook_tolerance", tolerance)
+	return payments.NewService(repo, provider, []byte(secret), tolerance)
 }
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hitanshu0729/order_go/internal/catalog"
+	"github.com/hitanshu0729/order_go/internal/inventory"
+	"github.com/hitanshu0729/order_go/internal/jobs"
+	"github.com/hitanshu0729/order_go/internal/kafka"
+	"github.com/hitanshu0729/order_go/internal/models"
+	"github.com/hitanshu0729/order_go/internal/orders"
+	"github.com/hitanshu0729/order_go/internal/outbox"
+	"github.com/hitanshu0729/order_go/internal/retention"
+)
+
+// Workers is the background work run alongside the HTTP server: the job
+// runner, the Kafka inventory consumer, the outbox relay and the periodic
+// reconciler, totals checker, expirer and purger. Start runs them all under
+// one context and Wait returns once every one of them has stopped.
+type Workers struct {
+	loops []func(ctx context.Context)
+	wg    sync.WaitGroup
+}
+
+// Start runs every worker in its own goroutine until ctx is cancelled.
+func (w *Workers) Start(ctx context.Context) {
+	for _, loop := range w.loops {
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			loop(ctx)
+		}()
+	}
+}
+
+// Wait blocks until every worker started by Start has returned.
+func (w *Workers) Wait() {
+	w.wg.Wait()
+}
+
+// newWorkers builds the background workers over s.repo and registers the
+// jobs they run. The handlers that trigger them are wired up from s by
+// RegisterRoutes.
+func (s *Server) newWorkers() *Workers {
+	w := &Workers{}
+
+	s.jobs = jobs.NewRunner(s.repo, jobs.Config{
+		Workers:      int(intFromEnv("JOB_WORKERS", 2)),
+		Lease:        durationFromEnv("JOB_LEASE", 30*time.Second),
+		PollInterval: durationFromEnv("JOB_POLL_INTERVAL", time.Second),
+	})
+	w.loops = append(w.loops, s.jobs.Start)
+
+	s.importer = catalog.NewImporter(s.repo)
+	s.jobs.Register(catalog.ImportJobType, s.importer.RunJob)
+
+	inventoryConsumer := kafka.NewInventoryConsumer(s.repo)
+	consumer := kafka.NewConsumer([]string{"localhost:9092"}, "order-service")
+	w.loops = append(w.loops, func(ctx context.Context) {
+		consumer.Start(ctx, inventoryConsumer, s.DLQProducer)
+	})
+
+	s.reconciler = inventory.NewReconciler(s.repo, durationFromEnv("INVENTORY_RECONCILE_INTERVAL", time.Hour))
+	w.loops = append(w.loops, s.reconciler.Start)
+	s.jobs.Register("inventory.reconcile", func(ctx context.Context, _ *models.Job) (any, error) {
+		return s.reconciler.Run(ctx)
+	})
+
+	totalsChecker := orders.NewTotalsChecker(s.repo, durationFromEnv("ORDER_TOTALS_CHECK_INTERVAL", time.Hour))
+	w.loops = append(w.loops, totalsChecker.Start)
+	s.jobs.Register("orders.check_totals", func(ctx context.Context, _ *models.Job) (any, error) {
+		return totalsChecker.Run(ctx)
+	})
+
+	s.expirer = orders.NewExpirer(s.repo, s.jobs,
+		durationFromEnv("ORDER_PENDING_TTL", 24*time.Hour),
+		durationFromEnv("PAYMENT_PENDING_TIMEOUT", 15*time.Minute),
+		durationFromEnv("ORDER_EXPIRY_INTERVAL", 15*time.Minute),
+	)
+	w.loops = append(w.loops, s.expirer.Start)
+	s.jobs.Register(orders.ExpireJobType, s.expirer.RunJob)
+
+	relay := outbox.NewRelay(s.repo, s.KafkaProducer, durationFromEnv("OUTBOX_POLL_INTERVAL", time.Second))
+	w.loops = append(w.loops, relay.Start)
+
+	purger := retention.NewPurger(
+		s.repo,
+		durationFromEnv("SOFT_DELETE_RETENTION", 30*24*time.Hour),
+		durationFromEnv("PURGE_INTERVAL", 24*time.Hour),
+	)
+	w.loops = append(w.loops, purger.Start)
+	s.jobs.Register("retention.purge", func(ctx context.Context, _ *models.Job) (any, error) {
+		return purger.Run(ctx)
+	})
+
+	return w
+}
@@ -0,0 +1,104 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/hitanshu0729/order_go/internal/domain"
+	"github.com/hitanshu0729/order_go/internal/models"
+)
+
+const apiKeyColumns = `id, user_id, name, prefix, key_hash, created_at, last_used_at, expires_at, revoked_at`
+
+// CreateAPIKey stores a hashed API key and sets k.ID.
+func (r *Repo) CreateAPIKey(ctx context.Context, k *models.APIKey) error {
+	res, err := r.db.ExecContext(
+		ctx,
+		`INSERT INTO api_keys (user_id, name, prefix, key_hash, expires_at) VALUES (?, ?, ?, ?, ?)`,
+		k.UserID,
+		k.Name,
+		k.Prefix,
+		k.KeyHash,
+		k.ExpiresAt,
+	)
+	if err != nil {
+		return err
+	}
+	k.ID, err = res.LastInsertId()
+	return err
+}
+
+// GetAPIKeys returns all keys of a user, including revoked ones.
+func (r *Repo) GetAPIKeys(ctx context.Context, userID int64) ([]*models.APIKey, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		`SELECT `+apiKeyColumns+` FROM api_keys WHERE user_id = ? ORDER BY id`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []*models.APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// GetAPIKeyByHash returns the key with the given hash.
+func (r *Repo) GetAPIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	k, err := scanAPIKey(r.db.QueryRowContext(
+		ctx,
+		`SELECT `+apiKeyColumns+` FROM api_keys WHERE key_hash = ?`,
+		keyHash,
+	))
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrAPIKeyNotFound
+	}
+	return k, err
+}
+
+// TouchAPIKey records that a key was just used.
+func (r *Repo) TouchAPIKey(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE api_keys SET last_used_at = CURRENT_TIMESTAMP WHERE id = ?`, id)
+	return err
+}
+
+// RevokeAPIKey revokes one of a user's live keys.
+func (r *Repo) RevokeAPIKey(ctx context.Context, userID, keyID int64) error {
+	res, err := r.db.ExecContext(
+		ctx,
+		`UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP
+		 WHERE id = ? AND user_id = ? AND revoked_at IS NULL`,
+		keyID,
+		userID,
+	)
+	return expectRow(res, err, domain.ErrAPIKeyNotFound)
+}
+
+func scanAPIKey(s scanner) (*models.APIKey, error) {
+	var k models.APIKey
+	var lastUsedAt, expiresAt, revokedAt sql.NullTime
+	if err := s.Scan(
+		&k.ID, &k.UserID, &k.Name, &k.Prefix, &k.KeyHash, &k.CreatedAt,
+		&lastUsedAt, &expiresAt, &revokedAt,
+	); err != nil {
+		return nil, err
+	}
+	if lastUsedAt.Valid {
+		k.LastUsedAt = &lastUsedAt.Time
+	}
+	if expiresAt.Valid {
+		k.ExpiresAt = &expiresAt.Time
+	}
+	if revokedAt.Valid {
+		k.RevokedAt = &revokedAt.Time
+	}
+	return &k, nil
+}
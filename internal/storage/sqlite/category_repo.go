@@ -0,0 +1,98 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/hitanshu0729/order_go/internal/domain"
+	"github.com/hitanshu0729/order_go/internal/models"
+)
+
+const categoryColumns = `id, name, slug, parent_id, created_at`
+
+// CreateCategory inserts a category under its parent, if any. c gets its
+// id and creation time.
+func (r *Repo) CreateCategory(ctx context.Context, c *models.Category) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if c.ParentID != nil {
+		if _, err := getCategory(ctx, tx, *c.ParentID); err != nil {
+			return fmt.Errorf("parent: %w", err)
+		}
+	}
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO categories (name, slug, parent_id) VALUES (?, ?, ?)`,
+		c.Name, c.Slug, c.ParentID,
+	)
+	if IsUniqueConstraintError(err) {
+		return fmt.Errorf("%w: %s", domain.ErrDuplicateCategorySlug, c.Slug)
+	}
+	if err != nil {
+		return err
+	}
+	if c.ID, err = res.LastInsertId(); err != nil {
+		return err
+	}
+	created, err := getCategory(ctx, tx, c.ID)
+	if err != nil {
+		return err
+	}
+	*c = *created
+	return tx.Commit()
+}
+
+// GetCategories returns all categories, parents before their children.
+func (r *Repo) GetCategories(ctx context.Context) ([]*models.Category, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`WITH RECURSIVE tree(id, depth) AS (
+			SELECT id, 0 FROM categories WHERE parent_id IS NULL
+			UNION ALL
+			SELECT c.id, t.depth + 1 FROM categories c JOIN tree t ON c.parent_id = t.id
+		)
+		SELECT `+categoryColumns+` FROM categories JOIN tree USING (id)
+		ORDER BY tree.depth, categories.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var categories []*models.Category
+	for rows.Next() {
+		c, err := scanCategory(rows)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, c)
+	}
+	return categories, rows.Err()
+}
+
+// GetCategoryByID returns a category.
+func (r *Repo) GetCategoryByID(ctx context.Context, id int64) (*models.Category, error) {
+	return getCategory(ctx, r.db, id)
+}
+
+func getCategory(ctx context.Context, q queryRower, id int64) (*models.Category, error) {
+	c, err := scanCategory(q.QueryRowContext(ctx, `SELECT `+categoryColumns+` FROM categories WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrCategoryNotFound
+	}
+	return c, err
+}
+
+func scanCategory(s scanner) (*models.Category, error) {
+	var c models.Category
+	var parentID sql.NullInt64
+	if err := s.Scan(&c.ID, &c.Name, &c.Slug, &parentID, &c.CreatedAt); err != nil {
+		return nil, err
+	}
+	if parentID.Valid {
+		c.ParentID = &parentID.Int64
+	}
+	return &c, nil
+}
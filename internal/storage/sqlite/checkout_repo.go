@@ -0,0 +1,173 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/hitanshu0729/order_go/internal/coupons"
+	"github.com/hitanshu0729/order_go/internal/domain"
+	"github.com/hitanshu0729/order_go/internal/models"
+	"github.com/hitanshu0729/order_go/internal/pricing"
+)
+
+// CheckoutLine is one cart line: a product or one of its variants, how
+// many of it and, optionally, a variant label and a note. Products with
+// variants must be ordered by VariantID and their lines are labelled with
+// the variant's SKU. Lines must differ in product or VariantID.
+type CheckoutLine struct {
+	ProductID int64
+	VariantID *int64
+	Quantity  int64
+	Variant   string
+	Note      string
+}
+
+// UnitPricer resolves a product's unit price in an order's currency.
+type UnitPricer func(p *models.Product, o *models.Order) (int64, error)
+
+// Checkout creates a priced pending order from a cart in one transaction.
+// Every product must be live and have enough stock for its line, the
+// coupon, when given, must apply to the cart, and the order.created event
+// is queued in the outbox so it is published if and only if the order
+// exists. o supplies the user, currency, exchange rate, jurisdiction and
+// shipping address; the stored order is returned with its items.
+func (r *Repo) Checkout(
+	ctx context.Context,
+	o *models.Order,
+	lines []CheckoutLine,
+	couponCode string,
+	unitPrice UnitPricer,
+) (*models.Order, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	lines = slices.Clone(lines) // variant lines are relabelled below
+	prices := make([]int64, len(lines))
+	snapshots := make([]string, len(lines))
+	// Stock is held per product, or per variant for variant lines.
+	type stockKey struct{ product, variant int64 }
+	requested := map[stockKey]int64{}
+	for i, line := range lines {
+		product, label, err := r.lineProduct(ctx, tx, line.ProductID, line.VariantID, line.Variant)
+		if err != nil {
+			return nil, err
+		}
+		lines[i].Variant = label
+		key := stockKey{product: line.ProductID}
+		if line.VariantID != nil {
+			key.variant = *line.VariantID
+		}
+		requested[key] += line.Quantity
+		if product.Stock < requested[key] {
+			return nil, fmt.Errorf("%w: %s has %d left, %d requested",
+				domain.ErrInsufficientStock, product.SKU, product.Stock, requested[key])
+		}
+		if prices[i], err = unitPrice(product, o); err != nil {
+			return nil, err
+		}
+		if snapshots[i], err = snapshotJSON(product.Snapshot()); err != nil {
+			return nil, err
+		}
+	}
+
+	o.Status = "pending"
+	if err := insertOrder(ctx, tx, o); err != nil {
+		return nil, err
+	}
+
+	in := pricing.Input{
+		Currency:     o.Currency,
+		ExchangeRate: o.ExchangeRate,
+		Jurisdiction: o.TaxJurisdiction,
+	}
+	for i, line := range lines {
+		res, err := tx.ExecContext(ctx,
+			`INSERT INTO order_items (order_id, product_id, quantity, price, variant_id, variant, note, product_snapshot)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			o.ID, line.ProductID, line.Quantity, prices[i], line.VariantID, line.Variant, line.Note, snapshots[i],
+		)
+		if err != nil {
+			return nil, err
+		}
+		itemID, err := res.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+		in.Lines = append(in.Lines, pricing.Line{
+			ItemID:    itemID,
+			ProductID: line.ProductID,
+			Quantity:  line.Quantity,
+			UnitPrice: prices[i],
+		})
+	}
+
+	if couponCode != "" {
+		if in.Discounts, err = r.checkoutCouponTx(ctx, tx, o, in, couponCode); err != nil {
+			return nil, err
+		}
+	}
+
+	breakdown, err := r.pricing.Price(in)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.updateOrderPricing(ctx, tx, o.ID, breakdown); err != nil {
+		return nil, err
+	}
+
+	err = r.enqueueEventTx(ctx, tx, "order.created", map[string]any{
+		"order_id":     o.ID,
+		"user_id":      o.UserID,
+		"currency":     o.Currency,
+		"total_amount": breakdown.GrandTotal,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	order, err := r.GetOrderByID(ctx, o.ID)
+	if err != nil {
+		return nil, err
+	}
+	if order.Items, err = r.GetOrderItems(ctx, o.ID); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// checkoutCouponTx attaches the coupon named by code to a new order and
+// returns its discounts. Unlike a coupon that stops applying to an existing
+// order, one that does not apply at checkout is an error.
+func (r *Repo) checkoutCouponTx(
+	ctx context.Context,
+	tx *sql.Tx,
+	o *models.Order,
+	in pricing.Input,
+	code string,
+) ([]pricing.Discount, error) {
+	coupon, err := couponByCode(ctx, tx, code)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.checkCouponUsage(ctx, tx, coupon, o.UserID); err != nil {
+		return nil, err
+	}
+	discounts, err := coupons.Discounts(coupon, in, r.pricing.Base(), time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE orders SET coupon_id = ? WHERE id = ?`, coupon.ID, o.ID); err != nil {
+		return nil, err
+	}
+	o.CouponID = &coupon.ID
+	return discounts, nil
+}
@@ -0,0 +1,96 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hitanshu0729/order_go/internal/domain"
+	"github.com/hitanshu0729/order_go/internal/models"
+)
+
+func basePrice(p *models.Product, _ *models.Order) (int64, error) {
+	return p.Price, nil
+}
+
+func countRows(t *testing.T, r *Repo, table string) int64 {
+	t.Helper()
+	var n int64
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM ` + table).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// newCheckoutOrder returns the order fields a checkout is given, for the
+// user of an existing test order.
+func newCheckoutOrder(t *testing.T, r *Repo) *models.Order {
+	t.Helper()
+	o := createTestOrder(t, r)
+	return &models.Order{
+		UserID:          o.UserID,
+		Currency:        o.Currency,
+		ExchangeRate:    o.ExchangeRate,
+		TaxJurisdiction: o.TaxJurisdiction,
+	}
+}
+
+func TestCheckoutCreatesPricedOrder(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+	a := createTestProduct(t, r, 100, 5)
+	b := createTestProduct(t, r, 250, 5)
+	outbox := countRows(t, r, "outbox")
+
+	order, err := r.Checkout(ctx, newCheckoutOrder(t, r), []CheckoutLine{
+		{ProductID: a.ID, Quantity: 2},
+		{ProductID: b.ID, Quantity: 1, Note: "gift wrap"},
+	}, "", basePrice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Status != "pending" || order.SubtotalAmount != 450 || len(order.Items) != 2 {
+		t.Fatalf("order = %+v, want a pending order of 2 lines totalling 450 before tax", order)
+	}
+	if got := countRows(t, r, "outbox"); got != outbox+1 {
+		t.Errorf("outbox grew by %d events, want 1", got-outbox)
+	}
+	drifts, err := r.CheckOrderTotals(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drifts) != 0 {
+		t.Errorf("drifts = %+v, want none", drifts)
+	}
+}
+
+func TestCheckoutRollsBackOnFailure(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+	a := createTestProduct(t, r, 100, 5)
+	b := createTestProduct(t, r, 250, 1)
+	o := newCheckoutOrder(t, r)
+	orders, items, outbox := countRows(t, r, "orders"), countRows(t, r, "order_items"), countRows(t, r, "outbox")
+
+	tests := []struct {
+		name    string
+		lines   []CheckoutLine
+		coupon  string
+		wantErr error
+	}{
+		{"short on a later line", []CheckoutLine{{ProductID: a.ID, Quantity: 2}, {ProductID: b.ID, Quantity: 2}}, "", domain.ErrInsufficientStock},
+		{"short across repeated lines", []CheckoutLine{{ProductID: b.ID, Quantity: 1}, {ProductID: b.ID, Quantity: 1}}, "", domain.ErrInsufficientStock},
+		{"unknown product", []CheckoutLine{{ProductID: a.ID, Quantity: 1}, {ProductID: b.ID + 100, Quantity: 1}}, "", domain.ErrProductNotFound},
+		{"unknown coupon", []CheckoutLine{{ProductID: a.ID, Quantity: 1}}, "NOPE", domain.ErrCouponNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := r.Checkout(ctx, o, tt.lines, tt.coupon, basePrice); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if countRows(t, r, "orders") != orders || countRows(t, r, "order_items") != items || countRows(t, r, "outbox") != outbox {
+				t.Error("failed checkout left rows behind")
+			}
+		})
+	}
+}
@@ -0,0 +1,344 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/hitanshu0729/order_go/internal/coupons"
+	"github.com/hitanshu0729/order_go/internal/domain"
+	"github.com/hitanshu0729/order_go/internal/models"
+	"github.com/hitanshu0729/order_go/internal/pricing"
+)
+
+const couponColumns = `id, code, description, kind, percent_off, amount_off, product_id,
+	buy_quantity, get_quantity, min_order_amount, max_uses, max_uses_per_user,
+	times_used, starts_at, ends_at, active, created_at`
+
+// CreateCoupon inserts a coupon and sets c.ID.
+func (r *Repo) CreateCoupon(ctx context.Context, c *models.Coupon) error {
+	res, err := r.db.ExecContext(
+		ctx,
+		`INSERT INTO coupons (code, description, kind, percent_off, amount_off, product_id,
+			buy_quantity, get_quantity, min_order_amount, max_uses, max_uses_per_user,
+			starts_at, ends_at, active)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		c.Code, c.Description, c.Kind, c.PercentOff, c.AmountOff, c.ProductID,
+		c.BuyQuantity, c.GetQuantity, c.MinOrderAmount, c.MaxUses, c.MaxUsesPerUser,
+		c.StartsAt, c.EndsAt, c.Active,
+	)
+	if err != nil {
+		if IsUniqueConstraintError(err) {
+			return domain.ErrDuplicateCouponCode
+		}
+		return err
+	}
+	c.ID, err = res.LastInsertId()
+	return err
+}
+
+func (r *Repo) GetCoupons(ctx context.Context) ([]*models.Coupon, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+couponColumns+` FROM coupons ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []*models.Coupon
+	for rows.Next() {
+		c, err := scanCoupon(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, c)
+	}
+	return list, rows.Err()
+}
+
+func (r *Repo) GetCouponByID(ctx context.Context, id int64) (*models.Coupon, error) {
+	c, err := scanCoupon(r.db.QueryRowContext(ctx, `SELECT `+couponColumns+` FROM coupons WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrCouponNotFound
+	}
+	return c, err
+}
+
+// GetCouponByCode looks a coupon up by its case-insensitive code.
+func (r *Repo) GetCouponByCode(ctx context.Context, code string) (*models.Coupon, error) {
+	return couponByCode(ctx, r.db, code)
+}
+
+func couponByCode(ctx context.Context, q queryRower, code string) (*models.Coupon, error) {
+	c, err := scanCoupon(q.QueryRowContext(
+		ctx,
+		`SELECT `+couponColumns+` FROM coupons WHERE code = ?`,
+		coupons.NormalizeCode(code),
+	))
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrCouponNotFound
+	}
+	return c, err
+}
+
+// UpdateCoupon replaces a coupon's definition. Usage counters are untouched.
+func (r *Repo) UpdateCoupon(ctx context.Context, c *models.Coupon) error {
+	res, err := r.db.ExecContext(
+		ctx,
+		`UPDATE coupons SET code = ?, description = ?, kind = ?, percent_off = ?, amount_off = ?,
+			product_id = ?, buy_quantity = ?, get_quantity = ?, min_order_amount = ?,
+			max_uses = ?, max_uses_per_user = ?, starts_at = ?, ends_at = ?, active = ?
+		 WHERE id = ?`,
+		c.Code, c.Description, c.Kind, c.PercentOff, c.AmountOff,
+		c.ProductID, c.BuyQuantity, c.GetQuantity, c.MinOrderAmount,
+		c.MaxUses, c.MaxUsesPerUser, c.StartsAt, c.EndsAt, c.Active,
+		c.ID,
+	)
+	if IsUniqueConstraintError(err) {
+		return domain.ErrDuplicateCouponCode
+	}
+	return expectRow(res, err, domain.ErrCouponNotFound)
+}
+
+// DeleteCoupon removes a coupon that has never been redeemed and detaches it
+// from any pending orders. Redeemed coupons must be deactivated instead.
+func (r *Repo) DeleteCoupon(ctx context.Context, id int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var timesUsed int64
+	err = tx.QueryRowContext(ctx, `SELECT times_used FROM coupons WHERE id = ?`, id).Scan(&timesUsed)
+	if err == sql.ErrNoRows {
+		return domain.ErrCouponNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if timesUsed > 0 {
+		return fmt.Errorf("%w, deactivate it instead", domain.ErrCouponRedeemed)
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE orders SET coupon_id = NULL, version = version + 1 WHERE coupon_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM coupons WHERE id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ApplyCoupon attaches a coupon to a pending order and reprices it in one
+// transaction. The coupon must be active, within its usage limits and
+// applicable to the order's current items. A non-zero version must match
+// the order's.
+func (r *Repo) ApplyCoupon(ctx context.Context, orderID int64, code string, version int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	order, err := scanOrder(tx.QueryRowContext(
+		ctx,
+		`SELECT `+orderColumns+` FROM orders WHERE id = ? AND deleted_at IS NULL`,
+		orderID,
+	))
+	if err == sql.ErrNoRows {
+		return domain.ErrOrderNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := versionError(version, order.Version); err != nil {
+		return err
+	}
+	if order.Status != "pending" {
+		return fmt.Errorf("%w: coupons can only be applied to pending orders", domain.ErrInvalidOrderStatus)
+	}
+	if err := paymentInFlight(ctx, tx, orderID); err != nil {
+		return err
+	}
+
+	coupon, err := couponByCode(ctx, tx, code)
+	if err != nil {
+		return err
+	}
+	if err := r.checkCouponUsage(ctx, tx, coupon, order.UserID); err != nil {
+		return err
+	}
+
+	in, err := pricingInput(ctx, tx, order)
+	if err != nil {
+		return err
+	}
+	if _, err := coupons.Discounts(coupon, in, r.pricing.Base(), time.Now()); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE orders SET coupon_id = ? WHERE id = ?`, coupon.ID, orderID); err != nil {
+		return err
+	}
+	if err := r.recalculateOrderTotal(ctx, tx, orderID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RemoveCoupon detaches the coupon from a pending order and reprices it in
+// one transaction. A non-zero version must match the order's.
+func (r *Repo) RemoveCoupon(ctx context.Context, orderID, version int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(
+		ctx,
+		`UPDATE orders SET coupon_id = NULL WHERE id = ? AND id `+pendingOrder,
+		orderID, version, version,
+	)
+	if err := expectRow(res, err, errNotPending); err != nil {
+		return r.pendingOrderError(ctx, tx, orderID, version, err, "coupons can only be removed from pending orders")
+	}
+	if err := r.recalculateOrderTotal(ctx, tx, orderID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// couponDiscounts returns the discounts granted by the order's coupon. A
+// coupon that no longer applies (expired, items removed below the minimum)
+// is detached from the order so that an attached coupon always means an
+// applied one.
+func (r *Repo) couponDiscounts(ctx context.Context, q dbtx, order *models.Order, in pricing.Input) ([]pricing.Discount, error) {
+	if order.CouponID == nil {
+		return nil, nil
+	}
+	coupon, err := scanCoupon(q.QueryRowContext(ctx, `SELECT `+couponColumns+` FROM coupons WHERE id = ?`, *order.CouponID))
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if coupon != nil {
+		discounts, err := coupons.Discounts(coupon, in, r.pricing.Base(), time.Now())
+		if err == nil {
+			return discounts, nil
+		}
+		if !errors.Is(err, domain.ErrCouponInactive) && !errors.Is(err, domain.ErrCouponNotApplicable) {
+			return nil, err
+		}
+		slog.InfoContext(ctx, "detaching coupon from order", "coupon", coupon.Code, "order_id", order.ID, "reason", err)
+	}
+	if _, err := q.ExecContext(ctx, `UPDATE orders SET coupon_id = NULL WHERE id = ?`, order.ID); err != nil {
+		return nil, err
+	}
+	order.CouponID = nil
+	return nil, nil
+}
+
+// checkCouponUsage enforces the global and per-user usage limits.
+func (r *Repo) checkCouponUsage(ctx context.Context, q queryRower, c *models.Coupon, userID int64) error {
+	if c.MaxUses > 0 && c.TimesUsed >= c.MaxUses {
+		return domain.ErrCouponUsageLimit
+	}
+	if c.MaxUsesPerUser > 0 {
+		var used int64
+		err := q.QueryRowContext(
+			ctx,
+			`SELECT COUNT(*) FROM coupon_redemptions WHERE coupon_id = ? AND user_id = ?`,
+			c.ID, userID,
+		).Scan(&used)
+		if err != nil {
+			return err
+		}
+		if used >= c.MaxUsesPerUser {
+			return fmt.Errorf("%w: already used %d time(s)", domain.ErrCouponUsageLimit, used)
+		}
+	}
+	return nil
+}
+
+// redeemCouponTx counts a coupon use for a paid order. The global limit is
+// enforced in the UPDATE itself so concurrent payments cannot oversubscribe.
+func (r *Repo) redeemCouponTx(ctx context.Context, tx *sql.Tx, order *models.Order) error {
+	coupon, err := scanCoupon(tx.QueryRowContext(ctx, `SELECT `+couponColumns+` FROM coupons WHERE id = ?`, *order.CouponID))
+	if err != nil {
+		return err
+	}
+	if err := coupons.CheckActive(coupon, time.Now()); err != nil {
+		return err
+	}
+	if err := r.checkCouponUsage(ctx, tx, coupon, order.UserID); err != nil {
+		return err
+	}
+
+	res, err := tx.ExecContext(
+		ctx,
+		`UPDATE coupons SET times_used = times_used + 1
+		 WHERE id = ? AND (max_uses = 0 OR times_used < max_uses)`,
+		coupon.ID,
+	)
+	if err != nil {
+		return err
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return domain.ErrCouponUsageLimit
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO coupon_redemptions (coupon_id, order_id, user_id) VALUES (?, ?, ?)`,
+		coupon.ID, order.ID, order.UserID,
+	)
+	return err
+}
+
+// queryRower is satisfied by both *sql.DB and *sql.Tx.
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx.
+type querier interface {
+	queryRower
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// dbtx is satisfied by both *sql.DB and *sql.Tx.
+type dbtx interface {
+	querier
+	execer
+}
+
+func scanCoupon(s scanner) (*models.Coupon, error) {
+	var c models.Coupon
+	var productID sql.NullInt64
+	var startsAt, endsAt sql.NullTime
+	if err := s.Scan(
+		&c.ID, &c.Code, &c.Description, &c.Kind, &c.PercentOff, &c.AmountOff, &productID,
+		&c.BuyQuantity, &c.GetQuantity, &c.MinOrderAmount, &c.MaxUses, &c.MaxUsesPerUser,
+		&c.TimesUsed, &startsAt, &endsAt, &c.Active, &c.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	if productID.Valid {
+		c.ProductID = &productID.Int64
+	}
+	if startsAt.Valid {
+		c.StartsAt = &startsAt.Time
+	}
+	if endsAt.Valid {
+		c.EndsAt = &endsAt.Time
+	}
+	return &c, nil
+}
@@ -0,0 +1,73 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/hitanshu0729/order_go/internal/domain"
+	"github.com/mattn/go-sqlite3"
+)
+
+// IsUniqueConstraintError reports whether err is SQLite rejecting a
+// duplicate unique or primary key.
+func IsUniqueConstraintError(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique ||
+			sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey
+	}
+	return false
+}
+
+// expectRow converts the result of an UPDATE or DELETE that should have
+// touched a row into notFound when it touched none.
+func expectRow(res sql.Result, err error, notFound error) error {
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return notFound
+	}
+	return nil
+}
+
+// versionMatch restricts an UPDATE of a versioned table to the version the
+// caller last read. It takes the version twice; version 0 matches any.
+const versionMatch = `(? = 0 OR version = ?)`
+
+// checkVersion explains a write guarded by versionMatch that touched no
+// row. When the row exists at another version the caller's copy is stale;
+// otherwise fallback, the error for the write's other conditions, applies.
+// table is always a package constant, never user input.
+func checkVersion(ctx context.Context, q queryRower, table string, id, version int64, fallback error) error {
+	if version == 0 {
+		return fallback
+	}
+	var current int64
+	err := q.QueryRowContext(ctx, `SELECT version FROM `+table+` WHERE id = ?`, id).Scan(&current)
+	if err == sql.ErrNoRows {
+		return fallback
+	}
+	if err != nil {
+		return err
+	}
+	if err := versionError(version, current); err != nil {
+		return err
+	}
+	return fallback
+}
+
+// versionError reports a row read at version current when the caller
+// expected version; version 0 matches any.
+func versionError(version, current int64) error {
+	if version != 0 && version != current {
+		return fmt.Errorf("%w: expected version %d, current version is %d", domain.ErrVersionMismatch, version, current)
+	}
+	return nil
+}
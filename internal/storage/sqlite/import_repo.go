@@ -0,0 +1,361 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+
+	"github.com/hitanshu0729/order_go/internal/domain"
+	"github.com/hitanshu0729/order_go/internal/models"
+)
+
+// maxImportErrors caps the row errors kept on an import; failed rows past
+// it are only counted.
+const maxImportErrors = 1000
+
+const productImportColumns = `id, status, format, dry_run, total_rows, created_rows, updated_rows, failed_rows,
+	errors, error, created_by, created_at, started_at, finished_at`
+
+// CreateProductImport records an import of payload with imp's status,
+// format, dry-run flag and creator. imp is reloaded with its id and
+// timestamps.
+func (r *Repo) CreateProductImport(ctx context.Context, imp *models.ProductImport, payload []byte) error {
+	var id int64
+	err := r.db.QueryRowContext(ctx,
+		`INSERT INTO product_imports (status, format, dry_run, payload, created_by, started_at)
+		 VALUES (?, ?, ?, ?, ?, CASE WHEN ? = 'running' THEN CURRENT_TIMESTAMP END)
+		 RETURNING id`,
+		imp.Status, imp.Format, imp.DryRun, payload, imp.CreatedBy, imp.Status,
+	).Scan(&id)
+	if err != nil {
+		return err
+	}
+	created, err := r.GetProductImport(ctx, id)
+	if err != nil {
+		return err
+	}
+	*imp = *created
+	return nil
+}
+
+// GetProductImport returns an import without its payload.
+func (r *Repo) GetProductImport(ctx context.Context, id int64) (*models.ProductImport, error) {
+	imp, err := scanProductImport(r.db.QueryRowContext(ctx,
+		`SELECT `+productImportColumns+` FROM product_imports WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrImportNotFound
+	}
+	return imp, err
+}
+
+// StartProductImport marks a pending import as running and returns it with
+// its payload. An import left running by an interrupted attempt is returned
+// again; its changes are only committed when it finishes, so running it again
+// is safe. It returns nil when the import has already finished.
+func (r *Repo) StartProductImport(ctx context.Context, id int64) (*models.ProductImport, []byte, error) {
+	row := r.db.QueryRowContext(ctx,
+		`UPDATE product_imports SET status = 'running', started_at = COALESCE(started_at, CURRENT_TIMESTAMP)
+		 WHERE id = ? AND status IN ('pending', 'running')
+		 RETURNING `+productImportColumns+`, payload`, id)
+	var payload []byte
+	imp, err := scanProductImport(row, &payload)
+	if err == sql.ErrNoRows {
+		if _, err := r.GetProductImport(ctx, id); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return imp, payload, nil
+}
+
+// FinishProductImport stores the outcome of an import and drops its
+// payload.
+func (r *Repo) FinishProductImport(ctx context.Context, imp *models.ProductImport) error {
+	rowErrors, err := json.Marshal(imp.Errors)
+	if err != nil {
+		return err
+	}
+	var finishedAt sql.NullTime
+	err = r.db.QueryRowContext(ctx,
+		`UPDATE product_imports
+		 SET status = ?, total_rows = ?, created_rows = ?, updated_rows = ?, failed_rows = ?,
+		     errors = ?, error = ?, payload = NULL, finished_at = CURRENT_TIMESTAMP
+		 WHERE id = ?
+		 RETURNING finished_at`,
+		imp.Status, imp.TotalRows, imp.Created, imp.Updated, imp.Failed,
+		string(rowErrors), imp.Error, imp.ID,
+	).Scan(&finishedAt)
+	if err == sql.ErrNoRows {
+		return domain.ErrImportNotFound
+	}
+	if finishedAt.Valid {
+		imp.FinishedAt = &finishedAt.Time
+	}
+	return err
+}
+
+// ImportProducts upserts the rows next yields by SKU in one transaction and
+// records the counts and row errors on imp. Rows next reports as a
+// *models.ImportRowError, and rows that cannot be stored, count as failed;
+// nothing is stored when any row failed or imp is a dry run. Any other
+// error from next or the database aborts the import.
+//
+// Variant rows must follow the row of their product when both are new.
+func (r *Repo) ImportProducts(ctx context.Context, imp *models.ProductImport, next func() (*models.ProductRow, error)) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	imp.TotalRows, imp.Created, imp.Updated, imp.Failed = 0, 0, 0, 0
+	imp.Errors = []models.ImportRowError{}
+	fail := func(e *models.ImportRowError) {
+		imp.Failed++
+		if len(imp.Errors) < maxImportErrors {
+			imp.Errors = append(imp.Errors, *e)
+		}
+	}
+	note := fmt.Sprintf("import %d", imp.ID)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		row, err := next()
+		if err == io.EOF {
+			break
+		}
+		var rowErr *models.ImportRowError
+		if errors.As(err, &rowErr) {
+			imp.TotalRows++
+			fail(rowErr)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		imp.TotalRows++
+		var created bool
+		if row.ParentSKU == "" {
+			created, err = r.importProduct(ctx, tx, row, note)
+		} else {
+			created, err = r.importVariant(ctx, tx, row, note)
+		}
+		switch {
+		case errors.As(err, &rowErr):
+			fail(rowErr)
+		case isImportRowError(err):
+			fail(&models.ImportRowError{Line: row.Line, SKU: row.SKU, Message: err.Error()})
+		case err != nil:
+			return err
+		case created:
+			imp.Created++
+		default:
+			imp.Updated++
+		}
+	}
+	if imp.Failed > 0 || imp.DryRun {
+		return nil
+	}
+	return tx.Commit()
+}
+
+// isImportRowError reports whether err is caused by the row being imported
+// rather than by the database.
+func isImportRowError(err error) bool {
+	for _, target := range []error{
+		domain.ErrDuplicateSKU,
+		domain.ErrCategoryNotFound,
+		domain.ErrProductNotFound,
+		domain.ErrVariantNotFound,
+		domain.ErrInsufficientStock,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Repo) importProduct(ctx context.Context, tx *sql.Tx, row *models.ProductRow, note string) (bool, error) {
+	rowError := func(msg string) error {
+		return &models.ImportRowError{Line: row.Line, SKU: row.SKU, Message: msg}
+	}
+	var categoryID *int64
+	if row.Category != nil && *row.Category != "" {
+		var id int64
+		err := tx.QueryRowContext(ctx, `SELECT id FROM categories WHERE slug = ?`, *row.Category).Scan(&id)
+		if err == sql.ErrNoRows {
+			return false, rowError("no category with slug " + *row.Category)
+		}
+		if err != nil {
+			return false, err
+		}
+		categoryID = &id
+	}
+
+	var id int64
+	var deleted bool
+	err := tx.QueryRowContext(ctx,
+		`SELECT id, deleted_at IS NOT NULL FROM products WHERE sku = ?`, row.SKU).Scan(&id, &deleted)
+	if err == sql.ErrNoRows {
+		if row.Name == nil || row.Price == nil {
+			return false, rowError("name and price are required for a new product")
+		}
+		p := &models.Product{
+			SKU:        row.SKU,
+			Name:       *row.Name,
+			CategoryID: categoryID,
+			Attributes: row.Attributes,
+			Price:      *row.Price,
+		}
+		if row.Description != nil {
+			p.Description = *row.Description
+		}
+		if row.Stock != nil {
+			p.Stock = *row.Stock
+		}
+		return true, r.insertProduct(ctx, tx, p)
+	}
+	if err != nil {
+		return false, err
+	}
+	if deleted {
+		return false, rowError("sku belongs to a deleted product")
+	}
+
+	p, err := scanProduct(tx.QueryRowContext(ctx, `SELECT `+productColumns+` FROM products WHERE id = ?`, id))
+	if err != nil {
+		return false, err
+	}
+	if row.Name != nil {
+		p.Name = *row.Name
+	}
+	if row.Description != nil {
+		p.Description = *row.Description
+	}
+	if row.Category != nil {
+		p.CategoryID = categoryID
+	}
+	if row.Price != nil {
+		p.Price = *row.Price
+	}
+	if p.Attributes == nil {
+		p.Attributes = map[string]string{}
+	}
+	maps.Copy(p.Attributes, row.Attributes)
+	attrs, err := attributesJSON(p.Attributes)
+	if err != nil {
+		return false, err
+	}
+	_, err = tx.ExecContext(ctx,
+		`UPDATE products
+		 SET name = ?, description = ?, category_id = ?, attributes = ?, price = ?, version = version + 1
+		 WHERE id = ?`,
+		p.Name, p.Description, p.CategoryID, attrs, p.Price, id,
+	)
+	if err != nil {
+		return false, err
+	}
+	if row.Stock != nil && *row.Stock != p.Stock {
+		err := r.AdjustProductStockTx(ctx, tx, id, *row.Stock-p.Stock, models.MovementReasonAdjustment, nil, note)
+		if err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+func (r *Repo) importVariant(ctx context.Context, tx *sql.Tx, row *models.ProductRow, note string) (bool, error) {
+	rowError := func(msg string) error {
+		return &models.ImportRowError{Line: row.Line, SKU: row.SKU, Message: msg}
+	}
+	var productID int64
+	err := tx.QueryRowContext(ctx,
+		`SELECT id FROM products WHERE sku = ? AND deleted_at IS NULL`, row.ParentSKU).Scan(&productID)
+	if err == sql.ErrNoRows {
+		return false, rowError("no product with sku " + row.ParentSKU)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	v, err := scanVariant(tx.QueryRowContext(ctx,
+		`SELECT `+variantColumns+` FROM product_variants WHERE sku = ?`, row.SKU))
+	if err == sql.ErrNoRows {
+		if row.Price == nil {
+			return false, rowError("price is required for a new variant")
+		}
+		v := &models.ProductVariant{ProductID: productID, SKU: row.SKU, Attributes: row.Attributes, Price: *row.Price}
+		if row.Stock != nil {
+			v.Stock = *row.Stock
+		}
+		if err := r.insertVariant(ctx, tx, v); err != nil {
+			return false, err
+		}
+		return true, bumpProductVersion(ctx, tx, productID)
+	}
+	if err != nil {
+		return false, err
+	}
+	switch {
+	case v.ProductID != productID:
+		return false, rowError("sku belongs to a variant of another product")
+	case v.DeletedAt != nil:
+		return false, rowError("sku belongs to a deleted variant")
+	}
+
+	if row.Price != nil {
+		v.Price = *row.Price
+	}
+	if v.Attributes == nil {
+		v.Attributes = map[string]string{}
+	}
+	maps.Copy(v.Attributes, row.Attributes)
+	attrs, err := attributesJSON(v.Attributes)
+	if err != nil {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE product_variants SET attributes = ?, price = ? WHERE id = ?`, attrs, v.Price, v.ID); err != nil {
+		return false, err
+	}
+	if row.Stock != nil && *row.Stock != v.Stock {
+		err := r.AdjustVariantStockTx(ctx, tx, v.ID, *row.Stock-v.Stock, models.MovementReasonAdjustment, nil, note)
+		if err != nil {
+			return false, err
+		}
+	}
+	return false, bumpProductVersion(ctx, tx, productID)
+}
+
+func scanProductImport(s scanner, extra ...any) (*models.ProductImport, error) {
+	var imp models.ProductImport
+	var rowErrors string
+	var startedAt, finishedAt sql.NullTime
+	dest := []any{
+		&imp.ID, &imp.Status, &imp.Format, &imp.DryRun, &imp.TotalRows, &imp.Created, &imp.Updated, &imp.Failed,
+		&rowErrors, &imp.Error, &imp.CreatedBy, &imp.CreatedAt, &startedAt, &finishedAt,
+	}
+	if err := s.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(rowErrors), &imp.Errors); err != nil {
+		return nil, err
+	}
+	if startedAt.Valid {
+		imp.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		imp.FinishedAt = &finishedAt.Time
+	}
+	return &imp, nil
+}
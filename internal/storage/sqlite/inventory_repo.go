@@ -0,0 +1,209 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/hitanshu0729/order_go/internal/domain"
+	"github.com/hitanshu0729/order_go/internal/models"
+)
+
+// AdjustProductStockTx applies a signed stock delta and appends the matching
+// ledger entry in the same transaction. Stock is never allowed to go negative.
+func (r *Repo) AdjustProductStockTx(
+	ctx context.Context,
+	tx *sql.Tx,
+	productID, delta int64,
+	reason string,
+	referenceID *int64,
+	note string,
+) error {
+	res, err := tx.ExecContext(
+		ctx,
+		`UPDATE products
+		 SET stock = stock + ?, version = version + 1
+		 WHERE id = ? AND stock + ? >= 0`,
+		delta, productID, delta,
+	)
+	if err != nil {
+		return err
+	}
+
+	rows, _ := res.RowsAffected()
+	if rows == 0 {
+		var exists int
+		err := tx.QueryRowContext(ctx, `SELECT 1 FROM products WHERE id = ?`, productID).Scan(&exists)
+		if err == sql.ErrNoRows {
+			return domain.ErrProductNotFound
+		}
+		if err != nil {
+			return err
+		}
+		return domain.ErrInsufficientStock
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO inventory_movements (product_id, quantity, reason, reference_id, note)
+		 VALUES (?, ?, ?, ?, ?)`,
+		productID, delta, reason, referenceID, note,
+	)
+	return err
+}
+
+// AdjustVariantStockTx is AdjustProductStockTx for a product variant. The
+// ledger entry carries both the product and the variant.
+func (r *Repo) AdjustVariantStockTx(
+	ctx context.Context,
+	tx *sql.Tx,
+	variantID, delta int64,
+	reason string,
+	referenceID *int64,
+	note string,
+) error {
+	var productID int64
+	err := tx.QueryRowContext(
+		ctx,
+		`UPDATE product_variants
+		 SET stock = stock + ?
+		 WHERE id = ? AND stock + ? >= 0
+		 RETURNING product_id`,
+		delta, variantID, delta,
+	).Scan(&productID)
+	if err == sql.ErrNoRows {
+		err := tx.QueryRowContext(ctx, `SELECT product_id FROM product_variants WHERE id = ?`, variantID).Scan(&productID)
+		if err == sql.ErrNoRows {
+			return domain.ErrVariantNotFound
+		}
+		if err != nil {
+			return err
+		}
+		return domain.ErrInsufficientStock
+	}
+	if err != nil {
+		return err
+	}
+	if err := bumpProductVersion(ctx, tx, productID); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO inventory_movements (product_id, variant_id, quantity, reason, reference_id, note)
+		 VALUES (?, ?, ?, ?, ?, ?)`,
+		productID, variantID, delta, reason, referenceID, note,
+	)
+	return err
+}
+
+// AdjustProductStock runs AdjustProductStockTx in its own transaction.
+func (r *Repo) AdjustProductStock(
+	ctx context.Context,
+	productID, delta int64,
+	reason, note string,
+) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := r.AdjustProductStockTx(ctx, tx, productID, delta, reason, nil, note); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// AdjustVariantStock runs AdjustVariantStockTx in its own transaction for a
+// live variant of the product.
+func (r *Repo) AdjustVariantStock(
+	ctx context.Context,
+	productID, variantID, delta int64,
+	reason, note string,
+) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := getProductVariant(ctx, tx, productID, variantID); err != nil {
+		return err
+	}
+	if err := r.AdjustVariantStockTx(ctx, tx, variantID, delta, reason, nil, note); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetProductMovements returns the ledger for a product and its variants,
+// oldest first.
+func (r *Repo) GetProductMovements(ctx context.Context, productID int64) ([]*models.InventoryMovement, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		`SELECT id, product_id, variant_id, quantity, reason, reference_id, note, created_at
+		 FROM inventory_movements
+		 WHERE product_id = ?
+		 ORDER BY id`,
+		productID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var movements []*models.InventoryMovement
+	for rows.Next() {
+		var m models.InventoryMovement
+		var variantID, ref sql.NullInt64
+		if err := rows.Scan(&m.ID, &m.ProductID, &variantID, &m.Quantity, &m.Reason, &ref, &m.Note, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		if variantID.Valid {
+			m.VariantID = &variantID.Int64
+		}
+		if ref.Valid {
+			m.ReferenceID = &ref.Int64
+		}
+		movements = append(movements, &m)
+	}
+	return movements, rows.Err()
+}
+
+// ReconcileInventory returns every product and variant whose stock column
+// does not equal the sum of its ledger entries.
+func (r *Repo) ReconcileInventory(ctx context.Context) ([]models.InventoryDrift, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		`SELECT p.id, NULL, p.stock, COALESCE(SUM(m.quantity), 0) AS ledger_sum
+		 FROM products p
+		 LEFT JOIN inventory_movements m ON m.product_id = p.id AND m.variant_id IS NULL
+		 GROUP BY p.id, p.stock
+		 HAVING p.stock != ledger_sum
+		 UNION ALL
+		 SELECT v.product_id, v.id, v.stock, COALESCE(SUM(m.quantity), 0) AS ledger_sum
+		 FROM product_variants v
+		 LEFT JOIN inventory_movements m ON m.variant_id = v.id
+		 GROUP BY v.id, v.stock
+		 HAVING v.stock != ledger_sum`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	drifts := []models.InventoryDrift{}
+	for rows.Next() {
+		var d models.InventoryDrift
+		var variantID sql.NullInt64
+		if err := rows.Scan(&d.ProductID, &variantID, &d.Stock, &d.LedgerSum); err != nil {
+			return nil, err
+		}
+		if variantID.Valid {
+			d.VariantID = &variantID.Int64
+		}
+		d.Drift = d.Stock - d.LedgerSum
+		drifts = append(drifts, d)
+	}
+	return drifts, rows.Err()
+}
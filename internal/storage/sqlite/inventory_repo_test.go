@@ -0,0 +1,187 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hitanshu0729/order_go/internal/domain"
+	"github.com/hitanshu0729/order_go/internal/models"
+)
+
+// ledgerSum returns the sum of a product's own ledger entries, excluding
+// those of its variants.
+func ledgerSum(t *testing.T, r *Repo, productID int64) int64 {
+	t.Helper()
+	movements, err := r.GetProductMovements(context.Background(), productID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sum int64
+	for _, m := range movements {
+		if m.VariantID == nil {
+			sum += m.Quantity
+		}
+	}
+	return sum
+}
+
+func productStock(t *testing.T, r *Repo, productID int64) int64 {
+	t.Helper()
+	p, err := r.GetProductByID(context.Background(), productID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p.Stock
+}
+
+func TestAdjustProductStockRecordsMovements(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+	p := createTestProduct(t, r, 100, 10)
+
+	if err := r.AdjustProductStock(ctx, p.ID, 5, models.MovementReasonRestock, "delivery"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.AdjustProductStock(ctx, p.ID, -3, models.MovementReasonAdjustment, "damaged"); err != nil {
+		t.Fatal(err)
+	}
+
+	movements, err := r.GetProductMovements(ctx, p.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		quantity int64
+		reason   string
+		note     string
+	}{
+		{10, models.MovementReasonRestock, "initial stock"},
+		{5, models.MovementReasonRestock, "delivery"},
+		{-3, models.MovementReasonAdjustment, "damaged"},
+	}
+	if len(movements) != len(want) {
+		t.Fatalf("got %d movements, want %d", len(movements), len(want))
+	}
+	for i, w := range want {
+		m := movements[i]
+		if m.Quantity != w.quantity || m.Reason != w.reason || m.Note != w.note {
+			t.Errorf("movement %d = %d %q %q, want %d %q %q", i, m.Quantity, m.Reason, m.Note, w.quantity, w.reason, w.note)
+		}
+	}
+	if stock := productStock(t, r, p.ID); stock != 12 || ledgerSum(t, r, p.ID) != stock {
+		t.Errorf("stock = %d, ledger = %d, want both 12", stock, ledgerSum(t, r, p.ID))
+	}
+}
+
+func TestAdjustProductStockRejectsNegativeStock(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+	p := createTestProduct(t, r, 100, 2)
+
+	err := r.AdjustProductStock(ctx, p.ID, -3, models.MovementReasonAdjustment, "")
+	if !errors.Is(err, domain.ErrInsufficientStock) {
+		t.Fatalf("err = %v, want %v", err, domain.ErrInsufficientStock)
+	}
+	err = r.AdjustProductStock(ctx, p.ID+1, 1, models.MovementReasonAdjustment, "")
+	if !errors.Is(err, domain.ErrProductNotFound) {
+		t.Fatalf("err = %v, want %v", err, domain.ErrProductNotFound)
+	}
+	if stock, sum := productStock(t, r, p.ID), ledgerSum(t, r, p.ID); stock != 2 || sum != 2 {
+		t.Errorf("stock = %d, ledger = %d, want both 2", stock, sum)
+	}
+}
+
+func TestOrderStockMovementsMatchStock(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+	p := createTestProduct(t, r, 100, 10)
+	orderID := createTestOrder(t, r).ID
+	item := OrderItem{ProductID: p.ID, Quantity: 4}
+
+	tx, err := r.BeginTx(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.DecreaseProductStockTx(ctx, tx, orderID, item); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if stock, sum := productStock(t, r, p.ID), ledgerSum(t, r, p.ID); stock != 6 || sum != 6 {
+		t.Errorf("after order: stock = %d, ledger = %d, want both 6", stock, sum)
+	}
+
+	tx, err = r.BeginTx(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RestockOrderItemTx(ctx, tx, orderID, item); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if stock, sum := productStock(t, r, p.ID), ledgerSum(t, r, p.ID); stock != 10 || sum != 10 {
+		t.Errorf("after restock: stock = %d, ledger = %d, want both 10", stock, sum)
+	}
+
+	movements, err := r.GetProductMovements(ctx, p.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range movements[1:] {
+		if m.ReferenceID == nil || *m.ReferenceID != orderID {
+			t.Errorf("movement %d references %v, want order %d", m.ID, m.ReferenceID, orderID)
+		}
+	}
+
+	drifts, err := r.ReconcileInventory(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drifts) != 0 {
+		t.Errorf("drifts = %+v, want none", drifts)
+	}
+}
+
+func TestReconcileInventoryReportsDrift(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+	p := createTestProduct(t, r, 100, 10)
+	v := &models.ProductVariant{ProductID: p.ID, SKU: "V-1", Price: 120, Stock: 3}
+	if err := r.CreateProductVariant(ctx, v); err != nil {
+		t.Fatal(err)
+	}
+
+	// Change stock behind the ledger's back.
+	if _, err := r.db.Exec(`UPDATE products SET stock = stock - 2 WHERE id = ?`, p.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.db.Exec(`UPDATE product_variants SET stock = stock + 1 WHERE id = ?`, v.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	drifts, err := r.ReconcileInventory(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drifts) != 2 {
+		t.Fatalf("got %d drifts, want 2: %+v", len(drifts), drifts)
+	}
+	for _, d := range drifts {
+		switch {
+		case d.VariantID == nil:
+			if d.ProductID != p.ID || d.Stock != 8 || d.LedgerSum != 10 || d.Drift != -2 {
+				t.Errorf("product drift = %+v", d)
+			}
+		case *d.VariantID == v.ID:
+			if d.Stock != 4 || d.LedgerSum != 3 || d.Drift != 1 {
+				t.Errorf("variant drift = %+v", d)
+			}
+		default:
+			t.Errorf("unexpected drift %+v", d)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hitanshu0729/order_go/internal/domain"
+	"github.com/hitanshu0729/order_go/internal/models"
+)
+
+// ErrJobLeaseLost is returned to a worker that no longer holds the lease on
+// its job, because the lease expired and another worker claimed the job.
+var ErrJobLeaseLost = errors.New("job lease lost")
+
+const jobColumns = `id, type, status, payload, result, error, progress, progress_message, attempts, max_attempts,
+	cancel_requested, run_at, created_by, created_at, updated_at, started_at, finished_at`
+
+// sqliteInterval formats d as a datetime() modifier, e.g. "+30 seconds".
+func sqliteInterval(d time.Duration) string {
+	return fmt.Sprintf("%+d seconds", int64(d/time.Second))
+}
+
+// CreateJob queues j to run now. j is reloaded with its id, status and
+// timestamps.
+func (r *Repo) CreateJob(ctx context.Context, j *models.Job) error {
+	payload := j.Payload
+	if len(payload) == 0 {
+		payload = json.RawMessage("{}")
+	}
+	var id int64
+	err := r.db.QueryRowContext(ctx,
+		`INSERT INTO jobs (type, payload, max_attempts, created_by) VALUES (?, ?, ?, ?) RETURNING id`,
+		j.Type, string(payload), j.MaxAttempts, j.CreatedBy,
+	).Scan(&id)
+	if err != nil {
+		return err
+	}
+	created, err := r.GetJob(ctx, id)
+	if err != nil {
+		return err
+	}
+	*j = *created
+	return nil
+}
+
+// GetJob returns a job by id.
+func (r *Repo) GetJob(ctx context.Context, id int64) (*models.Job, error) {
+	j, err := scanJob(r.db.QueryRowContext(ctx, `SELECT `+jobColumns+` FROM jobs WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrJobNotFound
+	}
+	return j, err
+}
+
+// ListJobs returns the latest limit jobs of type typ, newest first.
+func (r *Repo) ListJobs(ctx context.Context, typ string, limit int) ([]*models.Job, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+jobColumns+` FROM jobs WHERE type = ? ORDER BY id DESC LIMIT ?`, typ, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []*models.Job{}
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
+
+// ClaimJob leases the next job that is due, or whose worker's lease has
+// expired, to owner for lease and counts an attempt. It returns nil when
+// no job is ready.
+func (r *Repo) ClaimJob(ctx context.Context, owner string, lease time.Duration) (*models.Job, error) {
+	j, err := scanJob(r.db.QueryRowContext(ctx,
+		`UPDATE jobs
+		 SET status = 'running', attempts = attempts + 1,
+		     lease_owner = ?, lease_expires_at = datetime('now', ?),
+		     started_at = COALESCE(started_at, CURRENT_TIMESTAMP), updated_at = CURRENT_TIMESTAMP
+		 WHERE id = (
+		     SELECT id FROM jobs
+		     WHERE (status = 'queued' AND run_at <= CURRENT_TIMESTAMP)
+		        OR (status = 'running' AND lease_expires_at < CURRENT_TIMESTAMP)
+		     ORDER BY run_at, id
+		     LIMIT 1)
+		 RETURNING `+jobColumns,
+		owner, sqliteInterval(lease),
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return j, err
+}
+
+// HeartbeatJob renews owner's lease on a running job and stores its
+// progress. It reports whether cancellation has been requested.
+func (r *Repo) HeartbeatJob(ctx context.Context, id int64, owner string, lease time.Duration, progress int, message string) (bool, error) {
+	var cancel bool
+	err := r.db.QueryRowContext(ctx,
+		`UPDATE jobs
+		 SET lease_expires_at = datetime('now', ?), progress = ?, progress_message = ?, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = ? AND status = 'running' AND lease_owner = ?
+		 RETURNING cancel_requested`,
+		sqliteInterval(lease), progress, message, id, owner,
+	).Scan(&cancel)
+	if err == sql.ErrNoRows {
+		return false, ErrJobLeaseLost
+	}
+	return cancel, err
+}
+
+// FinishJob stores the final status, result, error and progress of owner's
+// running job and releases its lease.
+func (r *Repo) FinishJob(ctx context.Context, j *models.Job, owner string) error {
+	var result any
+	if len(j.Result) > 0 {
+		result = string(j.Result)
+	}
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE jobs
+		 SET status = ?, result = ?, error = ?, progress = ?, progress_message = ?,
+		     lease_owner = NULL, lease_expires_at = NULL,
+		     finished_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = ? AND status = 'running' AND lease_owner = ?`,
+		j.Status, result, j.Error, j.Progress, j.ProgressMessage, j.ID, owner,
+	)
+	return expectRow(res, err, ErrJobLeaseLost)
+}
+
+// RetryJob queues owner's running job to run again after delay, recording
+// the error of the failed attempt.
+func (r *Repo) RetryJob(ctx context.Context, id int64, owner, errMsg string, delay time.Duration) error {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE jobs
+		 SET status = 'queued', error = ?, run_at = datetime('now', ?),
+		     lease_owner = NULL, lease_expires_at = NULL, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = ? AND status = 'running' AND lease_owner = ?`,
+		errMsg, sqliteInterval(delay), id, owner,
+	)
+	return expectRow(res, err, ErrJobLeaseLost)
+}
+
+// ReleaseJob returns owner's running job to the queue without counting
+// the attempt, for a worker that is shutting down.
+func (r *Repo) ReleaseJob(ctx context.Context, id int64, owner string) error {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE jobs
+		 SET status = 'queued', attempts = attempts - 1, run_at = CURRENT_TIMESTAMP,
+		     lease_owner = NULL, lease_expires_at = NULL, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = ? AND status = 'running' AND lease_owner = ?`,
+		id, owner,
+	)
+	return expectRow(res, err, ErrJobLeaseLost)
+}
+
+// CancelJob cancels a queued job at once and asks the worker running a
+// running one to stop; the worker marks it cancelled. It returns the job
+// as it now is.
+func (r *Repo) CancelJob(ctx context.Context, id int64) (*models.Job, error) {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE jobs
+		 SET status = CASE status WHEN 'queued' THEN 'cancelled' ELSE status END,
+		     finished_at = CASE status WHEN 'queued' THEN CURRENT_TIMESTAMP ELSE finished_at END,
+		     cancel_requested = 1, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = ? AND status IN ('queued', 'running')`,
+		id,
+	)
+	if err := expectRow(res, err, domain.ErrJobFinished); err != domain.ErrJobFinished {
+		if err != nil {
+			return nil, err
+		}
+		return r.GetJob(ctx, id)
+	}
+	if _, err := r.GetJob(ctx, id); err != nil {
+		return nil, err
+	}
+	return nil, domain.ErrJobFinished
+}
+
+func scanJob(s scanner) (*models.Job, error) {
+	var j models.Job
+	var payload string
+	var result sql.NullString
+	var createdBy sql.NullInt64
+	var startedAt, finishedAt sql.NullTime
+	if err := s.Scan(
+		&j.ID, &j.Type, &j.Status, &payload, &result, &j.Error, &j.Progress, &j.ProgressMessage, &j.Attempts, &j.MaxAttempts,
+		&j.CancelRequested, &j.RunAt, &createdBy, &j.CreatedAt, &j.UpdatedAt, &startedAt, &finishedAt,
+	); err != nil {
+		return nil, err
+	}
+	j.Payload = json.RawMessage(payload)
+	if result.Valid {
+		j.Result = json.RawMessage(result.String)
+	}
+	if createdBy.Valid {
+		j.CreatedBy = &createdBy.Int64
+	}
+	if startedAt.Valid {
+		j.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		j.FinishedAt = &finishedAt.Time
+	}
+	return &j, nil
+}
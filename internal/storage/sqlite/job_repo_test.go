@@ -0,0 +1,138 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hitanshu0729/order_go/internal/domain"
+	"github.com/hitanshu0729/order_go/internal/models"
+)
+
+func createTestJob(t *testing.T, r *Repo) *models.Job {
+	t.Helper()
+	j := &models.Job{Type: "test", MaxAttempts: 3}
+	if err := r.CreateJob(context.Background(), j); err != nil {
+		t.Fatal(err)
+	}
+	return j
+}
+
+func claimTestJob(t *testing.T, r *Repo, owner string, lease time.Duration) *models.Job {
+	t.Helper()
+	j, err := r.ClaimJob(context.Background(), owner, lease)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return j
+}
+
+func TestClaimJobLeasesOnce(t *testing.T) {
+	r := newTestRepo(t)
+	job := createTestJob(t, r)
+
+	j := claimTestJob(t, r, "a", time.Minute)
+	if j == nil || j.ID != job.ID || j.Status != models.JobStatusRunning || j.Attempts != 1 {
+		t.Fatalf("claimed %+v, want job %d running on its first attempt", j, job.ID)
+	}
+	if j := claimTestJob(t, r, "b", time.Minute); j != nil {
+		t.Errorf("claimed leased job %d again", j.ID)
+	}
+}
+
+func TestExpiredJobLeaseIsReclaimed(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+	job := createTestJob(t, r)
+
+	// A lease that has already run out, as one whose worker died would.
+	if j := claimTestJob(t, r, "a", -time.Minute); j == nil {
+		t.Fatal("no job claimed")
+	}
+	j := claimTestJob(t, r, "b", time.Minute)
+	if j == nil || j.ID != job.ID || j.Attempts != 2 {
+		t.Fatalf("reclaimed %+v, want job %d on its second attempt", j, job.ID)
+	}
+
+	if _, err := r.HeartbeatJob(ctx, job.ID, "a", time.Minute, 50, ""); !errors.Is(err, ErrJobLeaseLost) {
+		t.Errorf("heartbeat by the old owner: err = %v, want %v", err, ErrJobLeaseLost)
+	}
+	j.Status = models.JobStatusSucceeded
+	if err := r.FinishJob(ctx, j, "a"); !errors.Is(err, ErrJobLeaseLost) {
+		t.Errorf("finish by the old owner: err = %v, want %v", err, ErrJobLeaseLost)
+	}
+	if err := r.FinishJob(ctx, j, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := r.GetJob(ctx, job.ID); err != nil || got.Status != models.JobStatusSucceeded {
+		t.Errorf("job = %+v, %v, want succeeded", got, err)
+	}
+}
+
+func TestRetryAndReleaseJob(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+	job := createTestJob(t, r)
+
+	claimTestJob(t, r, "a", time.Minute)
+	if err := r.RetryJob(ctx, job.ID, "a", "timeout", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if j := claimTestJob(t, r, "a", time.Minute); j != nil {
+		t.Fatalf("claimed job %d before its retry was due", j.ID)
+	}
+
+	// Make the retry due.
+	if _, err := r.db.Exec(`UPDATE jobs SET run_at = datetime('now', '-1 seconds') WHERE id = ?`, job.ID); err != nil {
+		t.Fatal(err)
+	}
+	j := claimTestJob(t, r, "a", time.Minute)
+	if j == nil || j.Attempts != 2 || j.Error != "timeout" {
+		t.Fatalf("retried %+v, want the second attempt after a timeout", j)
+	}
+
+	if err := r.ReleaseJob(ctx, job.ID, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if j := claimTestJob(t, r, "a", time.Minute); j == nil || j.Attempts != 2 {
+		t.Errorf("claimed %+v after release, want attempt 2 again", j)
+	}
+	if err := r.RetryJob(ctx, job.ID, "b", "", 0); !errors.Is(err, ErrJobLeaseLost) {
+		t.Errorf("retry by another worker: err = %v, want %v", err, ErrJobLeaseLost)
+	}
+}
+
+func TestCancelJob(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+	queued := createTestJob(t, r)
+
+	j, err := r.CancelJob(ctx, queued.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j.Status != models.JobStatusCancelled || j.FinishedAt == nil {
+		t.Errorf("cancelled queued job = %+v, want cancelled and finished", j)
+	}
+	if _, err := r.CancelJob(ctx, queued.ID); !errors.Is(err, domain.ErrJobFinished) {
+		t.Errorf("cancel twice: err = %v, want %v", err, domain.ErrJobFinished)
+	}
+
+	running := createTestJob(t, r)
+	claimTestJob(t, r, "a", time.Minute)
+	if j, err = r.CancelJob(ctx, running.ID); err != nil {
+		t.Fatal(err)
+	}
+	if j.Status != models.JobStatusRunning || !j.CancelRequested {
+		t.Errorf("cancelled running job = %+v, want it running with cancellation requested", j)
+	}
+	cancel, err := r.HeartbeatJob(ctx, running.ID, "a", time.Minute, 10, "")
+	if err != nil || !cancel {
+		t.Errorf("heartbeat = %v, %v, want cancellation reported", cancel, err)
+	}
+
+	if _, err := r.CancelJob(ctx, 999); !errors.Is(err, domain.ErrJobNotFound) {
+		t.Errorf("cancel missing job: err = %v, want %v", err, domain.ErrJobNotFound)
+	}
+}
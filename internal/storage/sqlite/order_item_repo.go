@@ -3,15 +3,25 @@ package sqlite
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/hitanshu0729/order_go/internal/domain"
 	"github.com/hitanshu0729/order_go/internal/models"
+	"github.com/hitanshu0729/order_go/internal/pricing"
 )
 
 // GetOrderItems returns all items for a given order.
 func (r *Repo) GetOrderItems(ctx context.Context, orderID int64) ([]*models.OrderItem, error) {
-	rows, err := r.db.QueryContext(ctx, `SELECT id, order_id, product_id, quantity, price FROM order_items WHERE order_id = ?`, orderID)
+	return getOrderItems(ctx, r.db, orderID)
+}
+
+func getOrderItems(ctx context.Context, q querier, orderID int64) ([]*models.OrderItem, error) {
+	rows, err := q.QueryContext(ctx,
+		`SELECT id, order_id, product_id, quantity, price, variant_id, variant, note, product_snapshot
+		 FROM order_items WHERE order_id = ? ORDER BY id`, orderID)
 	if err != nil {
 		return nil, err
 	}
@@ -20,104 +30,306 @@ func (r *Repo) GetOrderItems(ctx context.Context, orderID int64) ([]*models.Orde
 	var items []*models.OrderItem
 	for rows.Next() {
 		var item models.OrderItem
-		if err := rows.Scan(&item.ID, &item.OrderID, &item.ProductID, &item.Quantity, &item.Price); err != nil {
+		var variantID sql.NullInt64
+		var snapshot string
+		err := rows.Scan(&item.ID, &item.OrderID, &item.ProductID, &item.Quantity, &item.Price,
+			&variantID, &item.Variant, &item.Note, &snapshot)
+		if err != nil {
+			return nil, err
+		}
+		if variantID.Valid {
+			item.VariantID = &variantID.Int64
+		}
+		if err := json.Unmarshal([]byte(snapshot), &item.Product); err != nil {
 			return nil, err
 		}
 		items = append(items, &item)
 	}
-	return items, nil
+	return items, rows.Err()
 }
 
-// AddOrderItem adds a new item to an order (only if order is pending/created).
-func (r *Repo) AddOrderItem(ctx context.Context, orderID, productID, quantity, price int64) error {
-	// Check order status
-	order, err := r.GetOrderByID(ctx, orderID)
+// snapshotJSON encodes a line's product snapshot for storage.
+func snapshotJSON(p *models.ProductSnapshot) (string, error) {
+	if p == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(p)
+	return string(b), err
+}
+
+// pendingOrder is the condition, on an order id column, that the order is
+// live, still pending, has no payment in flight and, like versionMatch, is
+// at the expected version; it takes the version twice. Item and coupon
+// mutations include it in their write so that an order paid, cancelled or
+// edited concurrently, or whose total is being charged, cannot change.
+const pendingOrder = `IN (SELECT id FROM orders
+	WHERE status = 'pending' AND deleted_at IS NULL AND ` + versionMatch + `
+	AND NOT EXISTS (SELECT 1 FROM payments p
+		WHERE p.order_id = orders.id AND p.status IN ('pending', 'authorized')))`
+
+// AddOrderItem adds item to a pending order and reprices it in one
+// transaction. When the order already has a line for the product and
+// variant_id, the quantity is added to that line, its price and product
+// snapshot are replaced and so are its variant label and note if item has
+// them; item is updated to the stored line either way. A non-zero version
+// must match the order's.
+func (r *Repo) AddOrderItem(ctx context.Context, item *models.OrderItem, version int64) error {
+	snapshot, err := snapshotJSON(item.Product)
 	if err != nil {
 		return err
 	}
-	if order == nil || order.Status != "pending" {
-		return errors.New("can only add items to orders with status 'pending'")
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO order_items (order_id, product_id, quantity, price, variant_id, variant, note, product_snapshot)
+		 SELECT ?, ?, ?, ?, ?, ?, ?, ? WHERE ? `+pendingOrder+`
+		 ON CONFLICT (order_id, product_id, COALESCE(variant_id, 0)) DO UPDATE SET
+			quantity = quantity + excluded.quantity,
+			price = excluded.price,
+			variant = CASE WHEN excluded.variant = '' THEN variant ELSE excluded.variant END,
+			note = CASE WHEN excluded.note = '' THEN note ELSE excluded.note END,
+			product_snapshot = excluded.product_snapshot
+		 RETURNING id, quantity, note`,
+		item.OrderID, item.ProductID, item.Quantity, item.Price, item.VariantID, item.Variant, item.Note, snapshot,
+		item.OrderID, version, version,
+	).Scan(&item.ID, &item.Quantity, &item.Note)
+	if err == sql.ErrNoRows {
+		err = errNotPending
 	}
-	_, err = r.db.ExecContext(ctx,
-		`INSERT INTO order_items (order_id, product_id, quantity, price) VALUES (?, ?, ?, ?)`,
-		orderID, productID, quantity, price,
-	)
 	if err != nil {
+		return r.pendingOrderError(ctx, tx, item.OrderID, version, err, "can only add items to orders with status 'pending'")
+	}
+	if err := r.recalculateOrderTotal(ctx, tx, item.OrderID); err != nil {
 		return err
 	}
-	return r.recalculateOrderTotal(ctx, orderID)
+	return tx.Commit()
 }
 
-// UpdateOrderItemQuantity updates the quantity of an item (only if order is pending/created).
-func (r *Repo) UpdateOrderItemQuantity(ctx context.Context, orderID, itemID, quantity int64) error {
-	res, err := r.db.ExecContext(ctx,
-		`UPDATE order_items SET quantity = ? WHERE product_id = ? AND order_id = ?`,
-		quantity, itemID, orderID,
+// UpdateOrderItem sets the quantity and, when note is not nil, the note of
+// a line of a pending order and reprices it in one transaction. A non-zero
+// version must match the order's.
+func (r *Repo) UpdateOrderItem(ctx context.Context, orderID, itemID, quantity int64, note *string, version int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx,
+		`UPDATE order_items SET quantity = ?, note = COALESCE(?, note)
+		 WHERE id = ? AND order_id = ? AND order_id `+pendingOrder,
+		quantity, note, itemID, orderID, version, version,
 	)
+	if err := expectRow(res, err, errNotPending); err != nil {
+		return r.pendingOrderError(ctx, tx, orderID, version, err, "can only update items for orders with status 'pending'")
+	}
+	if err := r.recalculateOrderTotal(ctx, tx, orderID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RemoveOrderItem deletes a line from a pending order and reprices it in
+// one transaction. A non-zero version must match the order's.
+func (r *Repo) RemoveOrderItem(ctx context.Context, orderID, itemID, version int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
-	affected, _ := res.RowsAffected()
-	if affected == 0 {
-		return sql.ErrNoRows
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx,
+		`DELETE FROM order_items
+		 WHERE id = ? AND order_id = ? AND order_id `+pendingOrder,
+		itemID, orderID, version, version,
+	)
+	if err := expectRow(res, err, errNotPending); err != nil {
+		return r.pendingOrderError(ctx, tx, orderID, version, err, "can only remove items from orders with status 'pending'")
 	}
-	return r.recalculateOrderTotal(ctx, orderID)
+	if err := r.recalculateOrderTotal(ctx, tx, orderID); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-// RemoveOrderItem deletes an item from an order (only if order is pending/created).
-func (r *Repo) RemoveOrderItem(ctx context.Context, orderID, itemID int64) error {
-	order, err := r.GetOrderByID(ctx, orderID)
+// ReplaceOrderItems replaces all lines of a pending order with items, at
+// most one per product and variant_id, and reprices it in one transaction.
+// Lines whose product and variant_id are kept keep their id. A non-zero
+// version must match the order's.
+func (r *Repo) ReplaceOrderItems(ctx context.Context, orderID int64, items []*models.OrderItem, version int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
-	if order == nil || order.Status != "pending" {
-		return errors.New("can only remove items from orders with status 'pending'")
+	defer func() { _ = tx.Rollback() }()
+
+	// Transactions take the write lock when they begin, so the order
+	// cannot change between this check and the commit.
+	var ok bool
+	err = tx.QueryRowContext(ctx, `SELECT 1 FROM orders WHERE id = ? AND id `+pendingOrder,
+		orderID, version, version).Scan(&ok)
+	if err == sql.ErrNoRows {
+		err = errNotPending
+	}
+	if err != nil {
+		return r.pendingOrderError(ctx, tx, orderID, version, err, "can only replace items of orders with status 'pending'")
 	}
-	res, err := r.db.ExecContext(ctx,
-		`DELETE FROM order_items WHERE product_id = ? AND order_id = ?`,
-		itemID, orderID,
+
+	kept := make([]any, 0, len(items)+1)
+	kept = append(kept, orderID)
+	for _, item := range items {
+		item.OrderID = orderID
+		snapshot, err := snapshotJSON(item.Product)
+		if err != nil {
+			return err
+		}
+		err = tx.QueryRowContext(ctx,
+			`INSERT INTO order_items (order_id, product_id, quantity, price, variant_id, variant, note, product_snapshot)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			 ON CONFLICT (order_id, product_id, COALESCE(variant_id, 0)) DO UPDATE SET
+				quantity = excluded.quantity, price = excluded.price, variant = excluded.variant, note = excluded.note,
+				product_snapshot = excluded.product_snapshot
+			 RETURNING id`,
+			orderID, item.ProductID, item.Quantity, item.Price, item.VariantID, item.Variant, item.Note, snapshot,
+		).Scan(&item.ID)
+		if err != nil {
+			return err
+		}
+		kept = append(kept, item.ID)
+	}
+	_, err = tx.ExecContext(ctx,
+		`DELETE FROM order_items WHERE order_id = ? AND id NOT IN (`+placeholders(len(items))+`)`,
+		kept...,
 	)
 	if err != nil {
 		return err
 	}
-	affected, _ := res.RowsAffected()
-	if affected == 0 {
-		return sql.ErrNoRows
+
+	if err := r.recalculateOrderTotal(ctx, tx, orderID); err != nil {
+		return err
 	}
-	return r.recalculateOrderTotal(ctx, orderID)
+	return tx.Commit()
+}
+
+// placeholders returns n comma-separated bind parameters for an IN list.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
 }
 
-// recalculateOrderTotal updates the total_amount in orders table after item changes.
-func (r *Repo) recalculateOrderTotal(ctx context.Context, orderID int64) error {
-	row := r.db.QueryRowContext(ctx, `SELECT SUM(quantity * price) FROM order_items WHERE order_id = ?`, orderID)
-	var total sql.NullInt64
-	if err := row.Scan(&total); err != nil {
+// errNotPending marks a guarded write that touched no row; pendingOrderError
+// works out why.
+var errNotPending = errors.New("order not pending")
+
+// pendingOrderError explains why a write guarded by pendingOrder touched no
+// row: the order is missing, at another version, no longer pending, has a
+// payment in flight or, when none of these, the targeted item does not
+// exist. Other errors are returned unchanged.
+func (r *Repo) pendingOrderError(ctx context.Context, q queryRower, orderID, version int64, err error, msg string) error {
+	if !errors.Is(err, errNotPending) {
 		return err
 	}
-	return r.UpdateOrderTotal(ctx, orderID, total.Int64)
+	var status string
+	var current int64
+	err = q.QueryRowContext(ctx, `SELECT status, version FROM orders WHERE id = ? AND deleted_at IS NULL`, orderID).Scan(&status, &current)
+	if err == sql.ErrNoRows {
+		return domain.ErrOrderNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := versionError(version, current); err != nil {
+		return err
+	}
+	if status != "pending" {
+		return fmt.Errorf("%w: %s", domain.ErrInvalidOrderStatus, msg)
+	}
+	if err := paymentInFlight(ctx, q, orderID); err != nil {
+		return err
+	}
+	return domain.ErrOrderItemNotFound
 }
 
+// recalculateOrderTotal reprices an order from its items and coupon and
+// persists the breakdown and grand total on the orders row. Callers that
+// change items pass their transaction so the new total commits with them.
+func (r *Repo) recalculateOrderTotal(ctx context.Context, q dbtx, orderID int64) error {
+	order, err := scanOrder(q.QueryRowContext(ctx, `SELECT `+orderColumns+` FROM orders WHERE id = ?`, orderID))
+	if err == sql.ErrNoRows {
+		return domain.ErrOrderNotFound
+	}
+	if err != nil {
+		return err
+	}
+	in, err := pricingInput(ctx, q, order)
+	if err != nil {
+		return err
+	}
+	in.Discounts, err = r.couponDiscounts(ctx, q, order, in)
+	if err != nil {
+		return err
+	}
+
+	breakdown, err := r.pricing.Price(in)
+	if err != nil {
+		return err
+	}
+	return r.updateOrderPricing(ctx, q, orderID, breakdown)
+}
+
+// pricingInput describes an order's current items for the pricing engine.
+func pricingInput(ctx context.Context, q querier, order *models.Order) (pricing.Input, error) {
+	in := pricing.Input{
+		Currency:     order.Currency,
+		ExchangeRate: order.ExchangeRate,
+		Jurisdiction: order.TaxJurisdiction,
+	}
+	items, err := getOrderItems(ctx, q, order.ID)
+	if err != nil {
+		return in, err
+	}
+	for _, item := range items {
+		in.Lines = append(in.Lines, pricing.Line{
+			ItemID:    item.ID,
+			ProductID: item.ProductID,
+			Quantity:  item.Quantity,
+			UnitPrice: item.Price,
+		})
+	}
+	return in, nil
+}
+
+// DecreaseProductStockTx decrements stock for an order line, from its
+// variant when it has one, and records it in the inventory ledger.
 func (r *Repo) DecreaseProductStockTx(
 	ctx context.Context,
 	tx *sql.Tx,
-	productID, qty int64,
+	orderID int64,
+	item OrderItem,
 ) error {
-	res, err := tx.ExecContext(
-		ctx,
-		`UPDATE products
-		 SET stock = stock - ?
-		 WHERE id = ? AND stock >= ?`,
-		qty, productID, qty,
-	)
-	if err != nil {
-		return err
+	if item.VariantID != nil {
+		return r.AdjustVariantStockTx(ctx, tx, *item.VariantID, -item.Quantity, models.MovementReasonOrder, &orderID, "")
 	}
+	return r.AdjustProductStockTx(ctx, tx, item.ProductID, -item.Quantity, models.MovementReasonOrder, &orderID, "")
+}
 
-	rows, _ := res.RowsAffected()
-	if rows == 0 {
-		return domain.ErrInsufficientStock
+// RestockOrderItemTx returns the stock of an order line that was taken when
+// the order was paid, to its variant when it has one, and records it in the
+// inventory ledger.
+func (r *Repo) RestockOrderItemTx(
+	ctx context.Context,
+	tx *sql.Tx,
+	orderID int64,
+	item OrderItem,
+) error {
+	const note = "order cancelled"
+	if item.VariantID != nil {
+		return r.AdjustVariantStockTx(ctx, tx, *item.VariantID, item.Quantity, models.MovementReasonRestock, &orderID, note)
 	}
-	return nil
+	return r.AdjustProductStockTx(ctx, tx, item.ProductID, item.Quantity, models.MovementReasonRestock, &orderID, note)
 }
 
 func (r *Repo) BeginTx(ctx context.Context) (*sql.Tx, error) {
@@ -132,7 +344,7 @@ func (r *Repo) GetOrderItemsTx(
 
 	rows, err := tx.QueryContext(
 		ctx,
-		`SELECT product_id, quantity
+		`SELECT product_id, variant_id, quantity
 		 FROM order_items
 		 WHERE order_id = ?`,
 		orderID,
@@ -145,9 +357,13 @@ func (r *Repo) GetOrderItemsTx(
 	var items []OrderItem
 	for rows.Next() {
 		var it OrderItem
-		if err := rows.Scan(&it.ProductID, &it.Quantity); err != nil {
+		var variantID sql.NullInt64
+		if err := rows.Scan(&it.ProductID, &variantID, &it.Quantity); err != nil {
 			return nil, err
 		}
+		if variantID.Valid {
+			it.VariantID = &variantID.Int64
+		}
 		items = append(items, it)
 	}
 
@@ -156,5 +372,6 @@ func (r *Repo) GetOrderItemsTx(
 
 type OrderItem struct {
 	ProductID int64
+	VariantID *int64
 	Quantity  int64
 }
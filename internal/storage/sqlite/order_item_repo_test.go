@@ -0,0 +1,137 @@
+package sqlite
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hitanshu0729/order_go/internal/models"
+)
+
+func TestAddOrderItemMergesRepeatedProduct(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+	p := createTestProduct(t, r, 100, 10)
+	o := createTestOrder(t, r)
+
+	first := &models.OrderItem{OrderID: o.ID, ProductID: p.ID, Quantity: 2, Price: 100, Note: "gift wrap"}
+	if err := r.AddOrderItem(ctx, first, 0); err != nil {
+		t.Fatal(err)
+	}
+	// No note keeps the line's note; a new price replaces its price.
+	second := &models.OrderItem{OrderID: o.ID, ProductID: p.ID, Quantity: 3, Price: 120}
+	if err := r.AddOrderItem(ctx, second, 0); err != nil {
+		t.Fatal(err)
+	}
+	if second.ID != first.ID || second.Quantity != 5 || second.Note != "gift wrap" {
+		t.Fatalf("merged line = %+v, want id %d, quantity 5 and note %q", second, first.ID, "gift wrap")
+	}
+	third := &models.OrderItem{OrderID: o.ID, ProductID: p.ID, Quantity: 1, Price: 120, Note: "no wrap"}
+	if err := r.AddOrderItem(ctx, third, 0); err != nil {
+		t.Fatal(err)
+	}
+	if third.ID != first.ID || third.Note != "no wrap" {
+		t.Errorf("merged line = %+v, want id %d and note %q", third, first.ID, "no wrap")
+	}
+
+	items, err := r.GetOrderItems(ctx, o.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Quantity != 6 || items[0].Price != 120 {
+		t.Fatalf("items = %+v, want one line of 6 at 120", items)
+	}
+	if got := getTestOrder(t, r, o.ID).SubtotalAmount; got != 720 {
+		t.Errorf("subtotal = %d, want 720", got)
+	}
+}
+
+func TestReplaceOrderItems(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+	a := createTestProduct(t, r, 100, 10)
+	b := createTestProduct(t, r, 200, 10)
+	c := createTestProduct(t, r, 300, 10)
+	o := createTestOrder(t, r)
+
+	kept := &models.OrderItem{OrderID: o.ID, ProductID: a.ID, Quantity: 1, Price: 100}
+	if err := r.AddOrderItem(ctx, kept, 0); err != nil {
+		t.Fatal(err)
+	}
+	dropped := &models.OrderItem{OrderID: o.ID, ProductID: b.ID, Quantity: 1, Price: 200}
+	if err := r.AddOrderItem(ctx, dropped, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	err := r.ReplaceOrderItems(ctx, o.ID, []*models.OrderItem{
+		{ProductID: a.ID, Quantity: 4, Price: 100},
+		{ProductID: c.ID, Quantity: 1, Price: 300},
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err := r.GetOrderItems(ctx, o.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byProduct := make(map[int64]*models.OrderItem, len(items))
+	for _, item := range items {
+		byProduct[item.ProductID] = item
+	}
+	if len(items) != 2 || byProduct[a.ID] == nil || byProduct[c.ID] == nil {
+		t.Fatalf("items = %+v, want lines for products %d and %d", items, a.ID, c.ID)
+	}
+	if got := byProduct[a.ID]; got.ID != kept.ID || got.Quantity != 4 {
+		t.Errorf("kept line = %+v, want id %d with quantity 4", got, kept.ID)
+	}
+	if got := getTestOrder(t, r, o.ID).SubtotalAmount; got != 700 {
+		t.Errorf("subtotal = %d, want 700", got)
+	}
+
+	if err := r.ReplaceOrderItems(ctx, o.ID, nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	if items, err = r.GetOrderItems(ctx, o.ID); err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 0 {
+		t.Errorf("items = %+v, want none after replacing with an empty cart", items)
+	}
+	if got := getTestOrder(t, r, o.ID).TotalAmount; got != 0 {
+		t.Errorf("total = %d, want 0", got)
+	}
+}
+
+func TestOrderItemKeepsProductSnapshot(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+	p := &models.Product{SKU: "MUG-1", Name: "Mug", Price: 100, Stock: 10, Attributes: map[string]string{"colour": "blue"}}
+	if err := r.CreateProduct(ctx, p); err != nil {
+		t.Fatal(err)
+	}
+	o := createTestOrder(t, r)
+	item := &models.OrderItem{OrderID: o.ID, ProductID: p.ID, Quantity: 1, Price: p.Price, Product: p.Snapshot()}
+	if err := r.AddOrderItem(ctx, item, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	p.Name, p.SKU, p.Price, p.Attributes = "Large mug", "MUG-2", 150, map[string]string{"colour": "red"}
+	if err := r.UpdateProduct(ctx, p, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := r.GetOrderItems(ctx, o.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 {
+		t.Fatalf("items = %+v, want one line", items)
+	}
+	got := items[0]
+	if got.Price != 100 || got.Product == nil || got.Product.Name != "Mug" || got.Product.SKU != "MUG-1" ||
+		got.Product.Attributes["colour"] != "blue" {
+		t.Errorf("line = %+v with product %+v, want the mug as added at 100", got, got.Product)
+	}
+	if total := getTestOrder(t, r, o.ID).SubtotalAmount; total != 100 {
+		t.Errorf("subtotal = %d, want 100 after the product was repriced", total)
+	}
+}
@@ -1,105 +1,354 @@
-// UpdateOrderStatus updates the status of an order by id
-
 package sqlite
 
 import (
 	"context"
 	"database/sql"
-	"log"
+	"encoding/json"
+	"fmt"
+	"log/slog"
 	"strings"
 	"time"
 
+	"github.com/hitanshu0729/order_go/internal/domain"
 	"github.com/hitanshu0729/order_go/internal/models"
+	"github.com/hitanshu0729/order_go/internal/pricing"
 )
 
-func (r *Repo) CreateOrder(ctx context.Context, userID int64, status string, totalAmount int64) error {
-	_, err := r.db.ExecContext(
+// CreateOrder inserts a new order and sets o.ID. The caller picks the
+// currency and snapshots the base→currency exchange rate so totals remain
+// reproducible.
+func (r *Repo) CreateOrder(ctx context.Context, o *models.Order) error {
+	return insertOrder(ctx, r.db, o)
+}
+
+func insertOrder(ctx context.Context, x execer, o *models.Order) error {
+	var address *string
+	if o.ShippingAddress != nil {
+		b, err := json.Marshal(o.ShippingAddress)
+		if err != nil {
+			return err
+		}
+		s := string(b)
+		address = &s
+	}
+	res, err := x.ExecContext(
 		ctx,
-		`INSERT INTO orders (user_id, status, total_amount) VALUES (?, ?, ?)`,
-		userID,
-		status,
-		totalAmount,
+		`INSERT INTO orders (user_id, status, total_amount, currency, exchange_rate, tax_jurisdiction, shipping_address)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		o.UserID,
+		o.Status,
+		o.TotalAmount,
+		o.Currency,
+		o.ExchangeRate,
+		o.TaxJurisdiction,
+		address,
 	)
+	if err != nil {
+		return err
+	}
+	o.ID, err = res.LastInsertId()
 	return err
 }
 
+const orderColumns = `id, user_id, status, total_amount,
+	subtotal_amount, discount_amount, tax_amount, shipping_amount,
+	currency, exchange_rate, tax_jurisdiction, coupon_id, pricing, shipping_address,
+	cancellation_reason, version, created_at, deleted_at`
+
 func (r *Repo) GetOrders(ctx context.Context) ([]*models.Order, error) {
-	rows, err := r.db.QueryContext(ctx, `SELECT id, user_id, status, total_amount, created_at FROM orders`)
+	return r.queryOrders(ctx, `SELECT `+orderColumns+` FROM orders WHERE deleted_at IS NULL`)
+}
+
+// GetOrderByID returns a live (not soft-deleted) order.
+func (r *Repo) GetOrderByID(ctx context.Context, id int64) (*models.Order, error) {
+	return r.getOrder(ctx, `SELECT `+orderColumns+` FROM orders WHERE id = ? AND deleted_at IS NULL`, id)
+}
+
+// GetOrderByIDUnscoped returns an order regardless of soft-delete state.
+func (r *Repo) GetOrderByIDUnscoped(ctx context.Context, id int64) (*models.Order, error) {
+	return r.getOrder(ctx, `SELECT `+orderColumns+` FROM orders WHERE id = ?`, id)
+}
+
+func (r *Repo) getOrder(ctx context.Context, query string, id int64) (*models.Order, error) {
+	o, err := scanOrder(r.db.QueryRowContext(ctx, query, id))
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, domain.ErrOrderNotFound
+		}
 		return nil, err
 	}
-	defer rows.Close()
+	return o, nil
+}
 
-	var orders []*models.Order
+func (r *Repo) GetOrdersByStatus(ctx context.Context, status string) ([]*models.Order, error) {
+	return r.queryOrders(ctx, `SELECT `+orderColumns+` FROM orders WHERE status = ? AND deleted_at IS NULL`, status)
+}
+
+// markOrderPaidTx moves order, read inside tx, from pending to paid,
+// redeems its coupon so usage limits are counted exactly once per payment,
+// and queues an order.paid event.
+func (r *Repo) markOrderPaidTx(ctx context.Context, tx *sql.Tx, order *models.Order, paymentID int64) error {
+	res, err := tx.ExecContext(
+		ctx,
+		`UPDATE orders SET status = 'paid', version = version + 1 WHERE id = ? AND status = 'pending'`,
+		order.ID,
+	)
+	if err != nil {
+		return err
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return fmt.Errorf("%w: order can only be paid if status is 'pending'", domain.ErrInvalidOrderStatus)
+	}
+
+	if order.CouponID != nil {
+		if err := r.redeemCouponTx(ctx, tx, order); err != nil {
+			return err
+		}
+	}
+	return r.enqueueEventTx(ctx, tx, "order.paid", map[string]any{
+		"order_id":   order.ID,
+		"payment_id": paymentID,
+		"amount":     order.TotalAmount,
+		"currency":   order.Currency,
+	})
+}
+
+// CancelOrder cancels a live order that has not shipped or been cancelled
+// already, and queues an order.cancelled event. A non-zero version must
+// match the order's. What was captured for a paid order is recorded as
+// refunded with the cancellation; the payments are returned as they were
+// before, for the provider to refund the rest of each.
+func (r *Repo) CancelOrder(ctx context.Context, orderID, version int64, reason string) ([]*models.Payment, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	order, err := liveOrderTx(ctx, tx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if err := versionError(version, order.Version); err != nil {
+		return nil, err
+	}
+	refunds, err := r.cancelOrderTx(ctx, tx, order, reason)
+	if err != nil {
+		return nil, err
+	}
+	return refunds, tx.Commit()
+}
+
+// ExpirePendingOrders cancels up to limit live orders that have been
+// pending for longer than ttl with the reason 'expired', queueing an
+// order.expired event for each as well as order.cancelled, and returns
+// their ids.
+func (r *Repo) ExpirePendingOrders(ctx context.Context, ttl time.Duration, limit int) ([]int64, error) {
+	cutoff := sqliteInterval(-ttl)
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id FROM orders
+		 WHERE status = 'pending' AND deleted_at IS NULL AND created_at < datetime('now', ?)
+		 ORDER BY id LIMIT ?`,
+		cutoff, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int64
 	for rows.Next() {
-		var o models.Order
-		if err := rows.Scan(&o.ID, &o.UserID, &o.Status, &o.TotalAmount, &o.CreatedAt); err != nil {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
 			return nil, err
 		}
-		orders = append(orders, &o)
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
-	return orders, nil
-}
 
-func (r *Repo) GetOrderByID(ctx context.Context, id int64) (*models.Order, error) {
-	row := r.db.QueryRowContext(ctx, `SELECT id, user_id, status, total_amount, created_at FROM orders WHERE id = ?`, id)
-	var o models.Order
-	if err := row.Scan(&o.ID, &o.UserID, &o.Status, &o.TotalAmount, &o.CreatedAt); err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
+	expired := []int64{}
+	for _, id := range ids {
+		ok, err := r.expireOrder(ctx, id, cutoff)
+		if err != nil {
+			return expired, err
 		}
+		if ok {
+			expired = append(expired, id)
+		}
+	}
+	return expired, nil
+}
+
+// expireOrder cancels an order that is still pending and older than
+// cutoff. It reports false when the order was paid, cancelled or deleted
+// since it was selected.
+func (r *Repo) expireOrder(ctx context.Context, orderID int64, cutoff string) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	order, err := scanOrder(tx.QueryRowContext(ctx,
+		`SELECT `+orderColumns+` FROM orders
+		 WHERE id = ? AND status = 'pending' AND deleted_at IS NULL AND created_at < datetime('now', ?)`,
+		orderID, cutoff,
+	))
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	// Pending orders have no captured payments to refund.
+	if _, err := r.cancelOrderTx(ctx, tx, order, models.CancellationReasonExpired); err != nil {
+		return false, err
+	}
+	err = r.enqueueEventTx(ctx, tx, "order.expired", map[string]any{
+		"order_id":   order.ID,
+		"user_id":    order.UserID,
+		"created_at": order.CreatedAt,
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// liveOrderTx reads a live order inside tx.
+func liveOrderTx(ctx context.Context, tx *sql.Tx, orderID int64) (*models.Order, error) {
+	order, err := scanOrder(tx.QueryRowContext(ctx,
+		`SELECT `+orderColumns+` FROM orders WHERE id = ? AND deleted_at IS NULL`, orderID))
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrOrderNotFound
+	}
+	return order, err
+}
+
+// cancelOrderTx moves order, read inside tx, to cancelled, records its
+// captured payments as refunded and queues an order.cancelled event. It
+// returns those payments as they were before. Orders that have shipped, or
+// have packed shipments, and cancelled orders cannot be cancelled.
+func (r *Repo) cancelOrderTx(ctx context.Context, tx *sql.Tx, order *models.Order, reason string) ([]*models.Payment, error) {
+	switch order.Status {
+	case "partially_shipped", "shipped", "delivered":
+		return nil, fmt.Errorf("%w: cannot cancel an order that has shipped (%s)", domain.ErrInvalidOrderStatus, order.Status)
+	case "cancelled":
+		return nil, fmt.Errorf("%w: order is already cancelled", domain.ErrInvalidOrderStatus)
+	}
+	var packed int64
+	err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM shipments WHERE order_id = ?`, order.ID).Scan(&packed)
+	if err != nil {
 		return nil, err
 	}
-	return &o, nil
+	if packed > 0 {
+		return nil, fmt.Errorf("%w: order has packed shipments, delete them first", domain.ErrInvalidOrderStatus)
+	}
+	res, err := tx.ExecContext(ctx,
+		`UPDATE orders SET status = 'cancelled', cancellation_reason = ?, version = version + 1
+		 WHERE id = ? AND status IN ('pending', 'paid')`,
+		reason, order.ID)
+	if err != nil {
+		return nil, err
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return nil, fmt.Errorf("%w: only pending or paid orders can be cancelled", domain.ErrInvalidOrderStatus)
+	}
+	refunds, err := refundCancelledOrderTx(ctx, tx, order.ID)
+	if err != nil {
+		return nil, err
+	}
+	return refunds, r.enqueueEventTx(ctx, tx, "order.cancelled", map[string]any{
+		"order_id":        order.ID,
+		"user_id":         order.UserID,
+		"previous_status": order.Status,
+		"reason":          reason,
+	})
 }
 
-func (r *Repo) GetOrdersByStatus(ctx context.Context, status string) ([]*models.Order, error) {
-	rows, err := r.db.QueryContext(ctx, `SELECT id, user_id, status, total_amount, created_at FROM orders WHERE status = ?`, status)
+// updateOrderPricing persists a pricing breakdown and its grand total.
+func (r *Repo) updateOrderPricing(ctx context.Context, x execer, orderID int64, b pricing.Breakdown) error {
+	breakdown, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+	_, err = x.ExecContext(
+		ctx,
+		`UPDATE orders
+		 SET total_amount = ?, subtotal_amount = ?, discount_amount = ?,
+		     tax_amount = ?, shipping_amount = ?, pricing = ?, version = version + 1
+		 WHERE id = ?`,
+		b.GrandTotal,
+		b.Subtotal,
+		b.DiscountTotal,
+		b.TaxTotal,
+		b.Shipping,
+		string(breakdown),
+		orderID,
+	)
+	return err
+}
+
+// CheckOrderTotals returns every live order whose subtotal does not equal
+// the sum of its items or whose total does not equal the grand total of its
+// pricing breakdown.
+func (r *Repo) CheckOrderTotals(ctx context.Context) ([]models.OrderTotalDrift, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		`SELECT o.id, o.status, o.subtotal_amount, o.total_amount,
+		        COALESCE(SUM(oi.quantity * oi.price), 0) AS items_subtotal,
+		        COALESCE(json_extract(o.pricing, '$.grand_total'), o.total_amount) AS priced_total
+		 FROM orders o
+		 LEFT JOIN order_items oi ON oi.order_id = o.id
+		 WHERE o.deleted_at IS NULL
+		 GROUP BY o.id
+		 HAVING o.subtotal_amount != items_subtotal OR o.total_amount != priced_total`,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var orders []*models.Order
+	drifts := []models.OrderTotalDrift{}
 	for rows.Next() {
-		var o models.Order
-		if err := rows.Scan(&o.ID, &o.UserID, &o.Status, &o.TotalAmount, &o.CreatedAt); err != nil {
+		var d models.OrderTotalDrift
+		if err := rows.Scan(&d.OrderID, &d.Status, &d.SubtotalAmount, &d.TotalAmount, &d.ItemsSubtotal, &d.PricedTotal); err != nil {
 			return nil, err
 		}
-		orders = append(orders, &o)
+		drifts = append(drifts, d)
 	}
-	return orders, nil
+	return drifts, rows.Err()
 }
 
-func (r *Repo) UpdateOrderStatus(ctx context.Context, id int64, status string) error {
-	_, err := r.db.ExecContext(
-		ctx,
-		`UPDATE orders SET status = ? WHERE id = ?`,
-		status,
-		id,
-	)
-	return err
+// DeleteOrder soft-deletes an order; its items are kept.
+func (r *Repo) DeleteOrder(ctx context.Context, id, version int64) error {
+	return r.softDelete(ctx, "orders", id, version, domain.ErrOrderNotFound)
 }
 
-// UpdateOrderTotal sets the total_amount for a given order.
-func (r *Repo) UpdateOrderTotal(ctx context.Context, orderID int64, total int64) error {
-	_, err := r.db.ExecContext(ctx, `UPDATE orders SET total_amount = ? WHERE id = ?`, total, orderID)
-	return err
+// RestoreOrder clears the soft-delete marker on an order.
+func (r *Repo) RestoreOrder(ctx context.Context, id, version int64) error {
+	return r.restore(ctx, "orders", id, version, domain.ErrOrderNotFound)
 }
 
 // OrderFilter holds possible filter fields
 type OrderFilter struct {
-	UserID *int64
-	Status *string
-	From   *time.Time
-	To     *time.Time
+	UserID         *int64
+	Status         *string
+	From           *time.Time
+	To             *time.Time
+	IncludeDeleted bool
 }
 
 func (r *Repo) GetOrdersFiltered(ctx context.Context, filter OrderFilter) ([]*models.Order, error) {
-	query := "SELECT id, user_id, status, total_amount, created_at FROM orders"
+	query := "SELECT " + orderColumns + " FROM orders"
 	var args []interface{}
 	var conditions []string
 
+	if !filter.IncludeDeleted {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
 	if filter.UserID != nil {
 		conditions = append(conditions, "user_id = ?")
 		args = append(args, *filter.UserID)
@@ -121,6 +370,10 @@ func (r *Repo) GetOrdersFiltered(ctx context.Context, filter OrderFilter) ([]*mo
 		query += " WHERE " + strings.Join(conditions, " AND ")
 	}
 
+	return r.queryOrders(ctx, query, args...)
+}
+
+func (r *Repo) queryOrders(ctx context.Context, query string, args ...any) ([]*models.Order, error) {
 	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
@@ -129,15 +382,49 @@ func (r *Repo) GetOrdersFiltered(ctx context.Context, filter OrderFilter) ([]*mo
 
 	var orders []*models.Order
 	for rows.Next() {
-		var o models.Order
-		if err := rows.Scan(&o.ID, &o.UserID, &o.Status, &o.TotalAmount, &o.CreatedAt); err != nil {
+		o, err := scanOrder(rows)
+		if err != nil {
 			return nil, err
 		}
-		orders = append(orders, &o)
+		orders = append(orders, o)
 	}
 	return orders, nil
 }
 
+func scanOrder(s scanner) (*models.Order, error) {
+	var o models.Order
+	var couponID sql.NullInt64
+	var breakdown, address sql.NullString
+	var deletedAt sql.NullTime
+	if err := s.Scan(
+		&o.ID, &o.UserID, &o.Status, &o.TotalAmount,
+		&o.SubtotalAmount, &o.DiscountAmount, &o.TaxAmount, &o.ShippingAmount,
+		&o.Currency, &o.ExchangeRate, &o.TaxJurisdiction, &couponID, &breakdown, &address,
+		&o.CancellationReason, &o.Version, &o.CreatedAt, &deletedAt,
+	); err != nil {
+		return nil, err
+	}
+	if couponID.Valid {
+		o.CouponID = &couponID.Int64
+	}
+	if breakdown.Valid {
+		o.Pricing = &pricing.Breakdown{}
+		if err := json.Unmarshal([]byte(breakdown.String), o.Pricing); err != nil {
+			return nil, err
+		}
+	}
+	if address.Valid {
+		o.ShippingAddress = &models.Address{}
+		if err := json.Unmarshal([]byte(address.String), o.ShippingAddress); err != nil {
+			return nil, err
+		}
+	}
+	if deletedAt.Valid {
+		o.DeletedAt = &deletedAt.Time
+	}
+	return &o, nil
+}
+
 func (r *Repo) MarkEventProcessedTx(
 	ctx context.Context,
 	tx *sql.Tx,
@@ -151,7 +438,23 @@ func (r *Repo) MarkEventProcessedTx(
 		entityID,
 	)
 	if err == nil {
-		log.Printf("Successfully marked event processed: %s for entity ID %d", eventType, entityID)
+		slog.DebugContext(ctx, "marked event processed", "event", eventType, "entity_id", entityID)
 	}
 	return err
 }
+
+// EventProcessedTx reports whether an event for the entity has been marked
+// processed.
+func (r *Repo) EventProcessedTx(ctx context.Context, tx *sql.Tx, eventType string, entityID int64) (bool, error) {
+	var exists int
+	err := tx.QueryRowContext(
+		ctx,
+		`SELECT 1 FROM processed_events WHERE event_type = ? AND entity_id = ?`,
+		eventType,
+		entityID,
+	).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
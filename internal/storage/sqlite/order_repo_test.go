@@ -0,0 +1,321 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hitanshu0729/order_go/internal/domain"
+	"github.com/hitanshu0729/order_go/internal/models"
+)
+
+func addTestItem(ctx context.Context, r *Repo, orderID, productID, version int64) error {
+	item := &models.OrderItem{OrderID: orderID, ProductID: productID, Quantity: 1, Price: 100}
+	return r.AddOrderItem(ctx, item, version)
+}
+
+func getTestOrder(t *testing.T, r *Repo, id int64) *models.Order {
+	t.Helper()
+	o, err := r.GetOrderByID(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+// raceAddItems adds single units to the order from several goroutines,
+// runs finish once some of them have landed, and returns how many adds
+// succeeded. Every add must either succeed or be refused because the order
+// is no longer pending or is being paid.
+func raceAddItems(t *testing.T, r *Repo, orderID, productID int64, finish func() error) int64 {
+	t.Helper()
+	ctx := context.Background()
+	const adders = 8
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		added int64
+	)
+	start := make(chan struct{})
+	landed := make(chan struct{})
+	for range adders {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			for range 5 {
+				err := addTestItem(ctx, r, orderID, productID, 0)
+				if errors.Is(err, domain.ErrInvalidOrderStatus) || errors.Is(err, domain.ErrInvalidPaymentStatus) {
+					return
+				}
+				if err != nil {
+					t.Errorf("add item: %v", err)
+					return
+				}
+				mu.Lock()
+				added++
+				if added == adders {
+					close(landed)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	var finishErr error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		<-landed
+		finishErr = finish()
+	}()
+	close(start)
+	wg.Wait()
+	if finishErr != nil {
+		t.Fatal(finishErr)
+	}
+	return added
+}
+
+// assertOrderConsistent checks that the order's single line holds the
+// initial unit plus every successful add and that its totals match it.
+func assertOrderConsistent(t *testing.T, r *Repo, orderID, added int64) {
+	t.Helper()
+	items, err := r.GetOrderItems(context.Background(), orderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Quantity != added+1 {
+		t.Fatalf("order has %d lines, first %+v; want one line of %d", len(items), items[0], added+1)
+	}
+	drifts, err := r.CheckOrderTotals(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drifts) != 0 {
+		t.Errorf("drifts = %+v, want none", drifts)
+	}
+}
+
+func TestAddItemRacesCancel(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+	p := createTestProduct(t, r, 100, 1000)
+	o := createTestOrder(t, r)
+	if err := addTestItem(ctx, r, o.ID, p.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	added := raceAddItems(t, r, o.ID, p.ID, func() error {
+		_, err := r.CancelOrder(ctx, o.ID, 0, "changed my mind")
+		return err
+	})
+
+	if status := getTestOrder(t, r, o.ID).Status; status != "cancelled" {
+		t.Fatalf("status = %q, want cancelled", status)
+	}
+	assertOrderConsistent(t, r, o.ID, added)
+	if err := addTestItem(ctx, r, o.ID, p.ID, 0); !errors.Is(err, domain.ErrInvalidOrderStatus) {
+		t.Errorf("add to cancelled order: err = %v, want %v", err, domain.ErrInvalidOrderStatus)
+	}
+}
+
+func TestAddItemRacesPayment(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+	p := createTestProduct(t, r, 100, 1000)
+	o := createTestOrder(t, r)
+	if err := addTestItem(ctx, r, o.ID, p.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	var payment *models.Payment
+	added := raceAddItems(t, r, o.ID, p.ID, func() error {
+		payment = &models.Payment{OrderID: o.ID, Provider: "fake"}
+		if err := r.CreatePayment(ctx, payment, 0); err != nil {
+			return err
+		}
+		_, err := r.CapturePayment(ctx, payment.ID, models.PaymentStatusPending)
+		return err
+	})
+
+	paid := getTestOrder(t, r, o.ID)
+	if paid.Status != "paid" {
+		t.Fatalf("status = %q, want paid", paid.Status)
+	}
+	if payment.Amount != paid.TotalAmount {
+		t.Errorf("captured %d, want the order total %d", payment.Amount, paid.TotalAmount)
+	}
+	assertOrderConsistent(t, r, o.ID, added)
+	if err := addTestItem(ctx, r, o.ID, p.ID, 0); !errors.Is(err, domain.ErrInvalidOrderStatus) {
+		t.Errorf("add to paid order: err = %v, want %v", err, domain.ErrInvalidOrderStatus)
+	}
+}
+
+func TestStaleOrderVersion(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+	p := createTestProduct(t, r, 100, 10)
+	o := createTestOrder(t, r)
+
+	stale := getTestOrder(t, r, o.ID).Version
+	if err := addTestItem(ctx, r, o.ID, p.ID, stale); err != nil {
+		t.Fatal(err)
+	}
+	current := getTestOrder(t, r, o.ID).Version
+	if current == stale {
+		t.Fatalf("version not bumped by adding an item: %d", current)
+	}
+
+	if err := addTestItem(ctx, r, o.ID, p.ID, stale); !errors.Is(err, domain.ErrVersionMismatch) {
+		t.Errorf("add item: err = %v, want %v", err, domain.ErrVersionMismatch)
+	}
+	items, err := r.GetOrderItems(ctx, o.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.UpdateOrderItem(ctx, o.ID, items[0].ID, 3, nil, stale); !errors.Is(err, domain.ErrVersionMismatch) {
+		t.Errorf("update item: err = %v, want %v", err, domain.ErrVersionMismatch)
+	}
+	if err := r.RemoveOrderItem(ctx, o.ID, items[0].ID, stale); !errors.Is(err, domain.ErrVersionMismatch) {
+		t.Errorf("remove item: err = %v, want %v", err, domain.ErrVersionMismatch)
+	}
+	if _, err := r.CancelOrder(ctx, o.ID, stale, ""); !errors.Is(err, domain.ErrVersionMismatch) {
+		t.Errorf("cancel: err = %v, want %v", err, domain.ErrVersionMismatch)
+	}
+
+	if got := getTestOrder(t, r, o.ID); got.Version != current || got.Status != "pending" {
+		t.Errorf("order changed by stale writes: version %d status %q", got.Version, got.Status)
+	}
+	if _, err := r.CancelOrder(ctx, o.ID, current, ""); err != nil {
+		t.Errorf("cancel at current version: %v", err)
+	}
+}
+
+func TestCheckOrderTotalsReportsDrift(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+	p := createTestProduct(t, r, 100, 10)
+	itemsDrift := createTestOrder(t, r)
+	totalDrift := createTestOrder(t, r)
+	consistent := createTestOrder(t, r)
+	for _, o := range []*models.Order{itemsDrift, totalDrift, consistent} {
+		if err := addTestItem(ctx, r, o.ID, p.ID, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	drifts, err := r.CheckOrderTotals(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drifts) != 0 {
+		t.Fatalf("drifts before injection = %+v, want none", drifts)
+	}
+
+	// Change a line's price and another order's total without repricing.
+	if _, err := r.db.Exec(`UPDATE order_items SET price = price + 5 WHERE order_id = ?`, itemsDrift.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.db.Exec(`UPDATE orders SET total_amount = total_amount + 1 WHERE id = ?`, totalDrift.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	drifts, err = r.CheckOrderTotals(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[int64]models.OrderTotalDrift{}
+	for _, d := range drifts {
+		got[d.OrderID] = d
+	}
+	if len(got) != 2 {
+		t.Fatalf("drifts = %+v, want orders %d and %d", drifts, itemsDrift.ID, totalDrift.ID)
+	}
+	if d := got[itemsDrift.ID]; d.ItemsSubtotal != d.SubtotalAmount+5 {
+		t.Errorf("items drift = %+v, want items subtotal 5 above the stored subtotal", d)
+	}
+	if d := got[totalDrift.ID]; d.TotalAmount != d.PricedTotal+1 {
+		t.Errorf("total drift = %+v, want total 1 above the priced total", d)
+	}
+}
+
+func TestCancelOrderOnlyFromPendingOrPaid(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+	for _, status := range []string{"partially_shipped", "shipped", "delivered", "cancelled"} {
+		o := createTestOrder(t, r)
+		if _, err := r.db.Exec(`UPDATE orders SET status = ? WHERE id = ?`, status, o.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := r.CancelOrder(ctx, o.ID, 0, ""); !errors.Is(err, domain.ErrInvalidOrderStatus) {
+			t.Errorf("cancel %s order: err = %v, want %v", status, err, domain.ErrInvalidOrderStatus)
+		}
+		if got := getTestOrder(t, r, o.ID).Status; got != status {
+			t.Errorf("status = %q, want %q", got, status)
+		}
+	}
+	for _, status := range []string{"pending", "paid"} {
+		o := createTestOrder(t, r)
+		if _, err := r.db.Exec(`UPDATE orders SET status = ? WHERE id = ?`, status, o.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := r.CancelOrder(ctx, o.ID, 0, ""); err != nil {
+			t.Errorf("cancel %s order: %v", status, err)
+		}
+	}
+}
+
+// countEvents returns how many events of eventType are in the outbox.
+func countEvents(t *testing.T, r *Repo, eventType string) int64 {
+	t.Helper()
+	var n int64
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM outbox WHERE event_type = ?`, eventType).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestExpirePendingOrders(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+	stale := createTestOrder(t, r)
+	fresh := createTestOrder(t, r)
+	paid := createTestOrder(t, r)
+	_, err := r.db.Exec(`UPDATE orders SET created_at = datetime('now', '-2 hours') WHERE id IN (?, ?)`, stale.ID, paid.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.db.Exec(`UPDATE orders SET status = 'paid' WHERE id = ?`, paid.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	ids, err := r.ExpirePendingOrders(ctx, time.Hour, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 || ids[0] != stale.ID {
+		t.Fatalf("expired %v, want [%d]", ids, stale.ID)
+	}
+	if o := getTestOrder(t, r, stale.ID); o.Status != "cancelled" || o.CancellationReason != models.CancellationReasonExpired {
+		t.Errorf("stale order is %q (%q), want cancelled as expired", o.Status, o.CancellationReason)
+	}
+	if status := getTestOrder(t, r, fresh.ID).Status; status != "pending" {
+		t.Errorf("fresh order is %q, want pending", status)
+	}
+	if status := getTestOrder(t, r, paid.ID).Status; status != "paid" {
+		t.Errorf("paid order is %q, want paid", status)
+	}
+	if n := countEvents(t, r, "order.expired"); n != 1 {
+		t.Errorf("%d order.expired events, want 1", n)
+	}
+	if n := countEvents(t, r, "order.cancelled"); n != 1 {
+		t.Errorf("%d order.cancelled events, want 1", n)
+	}
+
+	if ids, err = r.ExpirePendingOrders(ctx, time.Hour, 10); err != nil || len(ids) != 0 {
+		t.Errorf("second run expired %v, %v, want none", ids, err)
+	}
+}
@@ -0,0 +1,80 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/hitanshu0729/order_go/internal/logging"
+	"github.com/hitanshu0729/order_go/internal/models"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// enqueueEventTx writes an event to the outbox inside tx, together with the
+// request id and trace context of ctx.
+func (r *Repo) enqueueEventTx(ctx context.Context, tx *sql.Tx, eventType string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	traceContext, err := json.Marshal(carrier)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO outbox (event_type, payload, request_id, trace_context) VALUES (?, ?, ?, ?)`,
+		eventType, string(body), logging.RequestID(ctx), string(traceContext),
+	)
+	return err
+}
+
+// GetUnpublishedEvents returns up to limit outbox events that have not been
+// published yet, oldest first.
+func (r *Repo) GetUnpublishedEvents(ctx context.Context, limit int) ([]*models.OutboxEvent, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		`SELECT id, event_type, payload, request_id, trace_context, created_at, attempts
+		 FROM outbox WHERE published_at IS NULL ORDER BY id LIMIT ?`,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*models.OutboxEvent
+	for rows.Next() {
+		var e models.OutboxEvent
+		var payload, traceContext string
+		if err := rows.Scan(&e.ID, &e.Type, &payload, &e.RequestID, &traceContext, &e.CreatedAt, &e.Attempts); err != nil {
+			return nil, err
+		}
+		e.Payload = json.RawMessage(payload)
+		if err := json.Unmarshal([]byte(traceContext), &e.TraceContext); err != nil {
+			return nil, err
+		}
+		events = append(events, &e)
+	}
+	return events, rows.Err()
+}
+
+// MarkEventPublished records that an outbox event reached the broker.
+func (r *Repo) MarkEventPublished(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE outbox SET published_at = CURRENT_TIMESTAMP WHERE id = ?`, id)
+	return err
+}
+
+// RecordEventFailure counts a failed publish attempt; the event stays in the
+// outbox and is retried.
+func (r *Repo) RecordEventFailure(ctx context.Context, id int64, cause error) error {
+	_, err := r.db.ExecContext(
+		ctx,
+		`UPDATE outbox SET attempts = attempts + 1, last_error = ? WHERE id = ?`,
+		cause.Error(), id,
+	)
+	return err
+}
@@ -0,0 +1,316 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/hitanshu0729/order_go/internal/domain"
+	"github.com/hitanshu0729/order_go/internal/models"
+)
+
+const paymentColumns = `id, order_id, provider, reference, method, status, amount, currency, refunded_amount, error,
+	created_at, updated_at`
+
+// CreatePayment records a pending payment of the grand total of the pending
+// order p.OrderID through p.Provider. A non-zero version must match the
+// order's. An order can only have one payment pending or authorized at a
+// time, and an order with nothing to pay none. p is reloaded with its id,
+// amount and status.
+func (r *Repo) CreatePayment(ctx context.Context, p *models.Payment, version int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	order, err := liveOrderTx(ctx, tx, p.OrderID)
+	if err != nil {
+		return err
+	}
+	if err := versionError(version, order.Version); err != nil {
+		return err
+	}
+	if order.Status != "pending" {
+		return fmt.Errorf("%w: order can only be paid if status is 'pending'", domain.ErrInvalidOrderStatus)
+	}
+	if order.TotalAmount <= 0 {
+		return fmt.Errorf("%w: order total is %d %s", domain.ErrNothingToPay, order.TotalAmount, order.Currency)
+	}
+	if err := paymentInFlight(ctx, tx, order.ID); err != nil {
+		return err
+	}
+
+	var id int64
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO payments (order_id, provider, method, amount, currency) VALUES (?, ?, ?, ?, ?) RETURNING id`,
+		order.ID, p.Provider, p.Method, order.TotalAmount, order.Currency,
+	).Scan(&id)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	created, err := r.GetPayment(ctx, id)
+	if err != nil {
+		return err
+	}
+	*p = *created
+	return nil
+}
+
+// paymentInFlight reports a payment of the order that is still pending or
+// authorized. While one is, the order's total is being charged, so the
+// order cannot be paid again and its items and coupon cannot change.
+func paymentInFlight(ctx context.Context, q queryRower, orderID int64) error {
+	var id int64
+	err := q.QueryRowContext(ctx,
+		`SELECT id FROM payments WHERE order_id = ? AND status IN ('pending', 'authorized')`, orderID,
+	).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: payment %d of the order is still in progress", domain.ErrInvalidPaymentStatus, id)
+}
+
+// FailAbandonedPayments fails payments left pending for longer than age
+// without a provider reference, and returns their ids. Such a payment was
+// recorded but the provider's answer never was, so no webhook can complete
+// it and, until it fails, its order cannot be paid or edited.
+func (r *Repo) FailAbandonedPayments(ctx context.Context, age time.Duration) ([]int64, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`UPDATE payments
+		 SET status = 'failed', error = 'abandoned before the provider answered', updated_at = CURRENT_TIMESTAMP
+		 WHERE status = 'pending' AND reference IS NULL AND created_at < datetime('now', ?)
+		 RETURNING id`,
+		sqliteInterval(-age),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// GetPayment returns a payment by id.
+func (r *Repo) GetPayment(ctx context.Context, id int64) (*models.Payment, error) {
+	p, err := scanPayment(r.db.QueryRowContext(ctx, `SELECT `+paymentColumns+` FROM payments WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrPaymentNotFound
+	}
+	return p, err
+}
+
+// GetPaymentByReference returns a payment by its provider's reference.
+func (r *Repo) GetPaymentByReference(ctx context.Context, provider, reference string) (*models.Payment, error) {
+	p, err := scanPayment(r.db.QueryRowContext(ctx,
+		`SELECT `+paymentColumns+` FROM payments WHERE provider = ? AND reference = ?`, provider, reference))
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrPaymentNotFound
+	}
+	return p, err
+}
+
+// ListOrderPayments returns an order's payments, oldest first.
+func (r *Repo) ListOrderPayments(ctx context.Context, orderID int64) ([]*models.Payment, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+paymentColumns+` FROM payments WHERE order_id = ? ORDER BY id`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payments := []*models.Payment{}
+	for rows.Next() {
+		p, err := scanPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, p)
+	}
+	return payments, rows.Err()
+}
+
+// UpdatePayment moves a payment from status from to status to, recording
+// the provider's reference when one is given and errMsg. It returns the
+// updated payment.
+func (r *Repo) UpdatePayment(ctx context.Context, id int64, from, to, reference, errMsg string) (*models.Payment, error) {
+	p, err := scanPayment(r.db.QueryRowContext(ctx,
+		`UPDATE payments
+		 SET status = ?, reference = COALESCE(NULLIF(?, ''), reference), error = ?, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = ? AND status = ?
+		 RETURNING `+paymentColumns,
+		to, reference, errMsg, id, from,
+	))
+	if err == sql.ErrNoRows {
+		return nil, paymentStatusError(ctx, r.db, id, "payment must be "+from)
+	}
+	return p, err
+}
+
+// CapturePayment records a payment in status from as captured and, in the
+// same transaction, moves its order to paid, redeems the order's coupon and
+// queues an order.paid event. Nothing is recorded when the order can no
+// longer be paid, including when the payment does not match its total.
+func (r *Repo) CapturePayment(ctx context.Context, id int64, from string) (*models.Payment, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	p, err := scanPayment(tx.QueryRowContext(ctx,
+		`UPDATE payments SET status = 'captured', error = '', updated_at = CURRENT_TIMESTAMP
+		 WHERE id = ? AND status = ?
+		 RETURNING `+paymentColumns,
+		id, from,
+	))
+	if err == sql.ErrNoRows {
+		return nil, paymentStatusError(ctx, tx, id, "payment must be "+from)
+	}
+	if err != nil {
+		return nil, err
+	}
+	order, err := liveOrderTx(ctx, tx, p.OrderID)
+	if err != nil {
+		return nil, err
+	}
+	if p.Amount != order.TotalAmount || p.Currency != order.Currency {
+		return nil, fmt.Errorf("%w: order total is %d %s, payment %d was for %d %s",
+			domain.ErrInvalidOrderStatus, order.TotalAmount, order.Currency, p.ID, p.Amount, p.Currency)
+	}
+	if err := r.markOrderPaidTx(ctx, tx, order, p.ID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// RefundPayment records amount of a captured payment as refunded. Once all
+// of it has been refunded the payment is refunded. errMsg, when given,
+// explains an automatic refund. The refund is recorded before the provider
+// makes it, so that concurrent refunds cannot exceed the payment;
+// ReleaseRefund takes it back when the provider fails.
+func (r *Repo) RefundPayment(ctx context.Context, id, amount int64, errMsg string) (*models.Payment, error) {
+	p, err := scanPayment(r.db.QueryRowContext(ctx,
+		`UPDATE payments
+		 SET refunded_amount = refunded_amount + ?,
+		     status = CASE WHEN refunded_amount + ? = amount THEN 'refunded' ELSE status END,
+		     error = CASE WHEN ? = '' THEN error ELSE ? END,
+		     updated_at = CURRENT_TIMESTAMP
+		 WHERE id = ? AND status = 'captured' AND refunded_amount + ? <= amount
+		 RETURNING `+paymentColumns,
+		amount, amount, errMsg, errMsg, id, amount,
+	))
+	if err != sql.ErrNoRows {
+		return p, err
+	}
+	current, err := r.GetPayment(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if current.Status != models.PaymentStatusCaptured {
+		return nil, fmt.Errorf("%w: only captured payments can be refunded, payment is %s", domain.ErrInvalidPaymentStatus, current.Status)
+	}
+	return nil, fmt.Errorf("%w: %d of %d left to refund", domain.ErrInvalidRefund, current.Amount-current.RefundedAmount, current.Amount)
+}
+
+// refundCancelledOrderTx records what is left of each captured payment of
+// an order being cancelled inside tx as refunded, and returns the payments
+// as they were before. The provider makes the refunds once tx commits;
+// ReleaseRefund takes back one it fails to make.
+func refundCancelledOrderTx(ctx context.Context, tx *sql.Tx, orderID int64) ([]*models.Payment, error) {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT `+paymentColumns+` FROM payments WHERE order_id = ? AND status = 'captured' ORDER BY id`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	var captured []*models.Payment
+	for rows.Next() {
+		p, err := scanPayment(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		captured = append(captured, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, p := range captured {
+		_, err := tx.ExecContext(ctx,
+			`UPDATE payments
+			 SET refunded_amount = amount, status = 'refunded', error = 'refunded automatically: order cancelled',
+			     updated_at = CURRENT_TIMESTAMP
+			 WHERE id = ?`,
+			p.ID,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return captured, nil
+}
+
+// ReleaseRefund takes back amount recorded by RefundPayment that the
+// provider failed to refund, leaving the payment captured with errMsg as
+// its error.
+func (r *Repo) ReleaseRefund(ctx context.Context, id, amount int64, errMsg string) (*models.Payment, error) {
+	p, err := scanPayment(r.db.QueryRowContext(ctx,
+		`UPDATE payments
+		 SET refunded_amount = refunded_amount - ?, status = 'captured', error = ?, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = ? AND status IN ('captured', 'refunded') AND refunded_amount >= ?
+		 RETURNING `+paymentColumns,
+		amount, errMsg, id, amount,
+	))
+	if err == sql.ErrNoRows {
+		return nil, paymentStatusError(ctx, r.db, id, "no refund to release")
+	}
+	return p, err
+}
+
+// paymentStatusError explains a status change guarded on the payment's
+// current status that touched no row.
+func paymentStatusError(ctx context.Context, q queryRower, id int64, msg string) error {
+	var status string
+	err := q.QueryRowContext(ctx, `SELECT status FROM payments WHERE id = ?`, id).Scan(&status)
+	if err == sql.ErrNoRows {
+		return domain.ErrPaymentNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: %s, payment is %s", domain.ErrInvalidPaymentStatus, msg, status)
+}
+
+func scanPayment(s scanner) (*models.Payment, error) {
+	var p models.Payment
+	var reference sql.NullString
+	if err := s.Scan(
+		&p.ID, &p.OrderID, &p.Provider, &reference, &p.Method, &p.Status, &p.Amount, &p.Currency, &p.RefundedAmount, &p.Error,
+		&p.CreatedAt, &p.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	p.Reference = reference.String
+	return &p, nil
+}
@@ -0,0 +1,234 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hitanshu0729/order_go/internal/domain"
+	"github.com/hitanshu0729/order_go/internal/models"
+)
+
+func TestPaymentInFlightBlocksOrderEdits(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+	p := createTestProduct(t, r, 100, 10)
+	o := createTestOrder(t, r)
+	if err := addTestItem(ctx, r, o.ID, p.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	items, err := r.GetOrderItems(ctx, o.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payment := &models.Payment{OrderID: o.ID, Provider: "fake"}
+	if err := r.CreatePayment(ctx, payment, 0); err != nil {
+		t.Fatal(err)
+	}
+	before := getTestOrder(t, r, o.ID)
+
+	edits := map[string]func() error{
+		"add item":      func() error { return addTestItem(ctx, r, o.ID, p.ID, 0) },
+		"update item":   func() error { return r.UpdateOrderItem(ctx, o.ID, items[0].ID, 3, nil, 0) },
+		"remove item":   func() error { return r.RemoveOrderItem(ctx, o.ID, items[0].ID, 0) },
+		"apply coupon":  func() error { return r.ApplyCoupon(ctx, o.ID, "SAVE10", 0) },
+		"remove coupon": func() error { return r.RemoveCoupon(ctx, o.ID, 0) },
+		"pay again": func() error {
+			return r.CreatePayment(ctx, &models.Payment{OrderID: o.ID, Provider: "fake"}, 0)
+		},
+	}
+	for name, edit := range edits {
+		if err := edit(); !errors.Is(err, domain.ErrInvalidPaymentStatus) {
+			t.Errorf("%s: err = %v, want %v", name, err, domain.ErrInvalidPaymentStatus)
+		}
+	}
+	if after := getTestOrder(t, r, o.ID); after.Version != before.Version || after.TotalAmount != before.TotalAmount {
+		t.Errorf("order changed while paying: %+v, was %+v", after, before)
+	}
+
+	if _, err := r.UpdatePayment(ctx, payment.ID, models.PaymentStatusPending, models.PaymentStatusFailed, "", "declined"); err != nil {
+		t.Fatal(err)
+	}
+	if err := addTestItem(ctx, r, o.ID, p.ID, 0); err != nil {
+		t.Errorf("add item after the payment failed: %v", err)
+	}
+}
+
+func TestCapturePaymentRefusesChangedTotal(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+	p := createTestProduct(t, r, 100, 10)
+	o := createTestOrder(t, r)
+	if err := addTestItem(ctx, r, o.ID, p.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	payment := &models.Payment{OrderID: o.ID, Provider: "fake"}
+	if err := r.CreatePayment(ctx, payment, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// Change the total behind the guard, as a write that bypassed it would.
+	if _, err := r.db.Exec(`UPDATE orders SET total_amount = total_amount + 50 WHERE id = ?`, o.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.CapturePayment(ctx, payment.ID, models.PaymentStatusPending); !errors.Is(err, domain.ErrInvalidOrderStatus) {
+		t.Fatalf("capture: err = %v, want %v", err, domain.ErrInvalidOrderStatus)
+	}
+	got, err := r.GetPayment(ctx, payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != models.PaymentStatusPending {
+		t.Errorf("payment status = %q, want %q", got.Status, models.PaymentStatusPending)
+	}
+	if status := getTestOrder(t, r, o.ID).Status; status != "pending" {
+		t.Errorf("order status = %q, want pending", status)
+	}
+}
+
+func TestRefundPaymentReservesAmount(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+	p := createTestProduct(t, r, 100, 10)
+	o := createTestOrder(t, r)
+	if err := addTestItem(ctx, r, o.ID, p.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	payment := &models.Payment{OrderID: o.ID, Provider: "fake"}
+	if err := r.CreatePayment(ctx, payment, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.CapturePayment(ctx, payment.ID, models.PaymentStatusPending); err != nil {
+		t.Fatal(err)
+	}
+
+	refunded, err := r.RefundPayment(ctx, payment.ID, payment.Amount, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refunded.Status != models.PaymentStatusRefunded {
+		t.Fatalf("status = %q, want %q", refunded.Status, models.PaymentStatusRefunded)
+	}
+	if _, err := r.RefundPayment(ctx, payment.ID, 1, ""); !errors.Is(err, domain.ErrInvalidPaymentStatus) {
+		t.Errorf("second refund: err = %v, want %v", err, domain.ErrInvalidPaymentStatus)
+	}
+
+	released, err := r.ReleaseRefund(ctx, payment.ID, payment.Amount, "provider unavailable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if released.Status != models.PaymentStatusCaptured || released.RefundedAmount != 0 {
+		t.Errorf("after release: status %q refunded %d, want captured and 0", released.Status, released.RefundedAmount)
+	}
+	if _, err := r.ReleaseRefund(ctx, payment.ID, 1, ""); !errors.Is(err, domain.ErrInvalidPaymentStatus) {
+		t.Errorf("release with nothing refunded: err = %v, want %v", err, domain.ErrInvalidPaymentStatus)
+	}
+}
+
+func TestCreatePaymentRefusesZeroTotal(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+	o := createTestOrder(t, r)
+
+	err := r.CreatePayment(ctx, &models.Payment{OrderID: o.ID, Provider: "fake"}, 0)
+	if !errors.Is(err, domain.ErrNothingToPay) {
+		t.Fatalf("pay empty order: err = %v, want %v", err, domain.ErrNothingToPay)
+	}
+	if payments, err := r.ListOrderPayments(ctx, o.ID); err != nil || len(payments) != 0 {
+		t.Errorf("payments = %+v, %v, want none", payments, err)
+	}
+}
+
+func TestFailAbandonedPayments(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+	p := createTestProduct(t, r, 100, 10)
+	pay := func() *models.Payment {
+		t.Helper()
+		o := createTestOrder(t, r)
+		if err := addTestItem(ctx, r, o.ID, p.ID, 0); err != nil {
+			t.Fatal(err)
+		}
+		payment := &models.Payment{OrderID: o.ID, Provider: "fake"}
+		if err := r.CreatePayment(ctx, payment, 0); err != nil {
+			t.Fatal(err)
+		}
+		return payment
+	}
+	abandoned, withProvider, recent := pay(), pay(), pay()
+	if _, err := r.UpdatePayment(ctx, withProvider.ID, models.PaymentStatusPending, models.PaymentStatusPending, "ref_1", ""); err != nil {
+		t.Fatal(err)
+	}
+	_, err := r.db.Exec(`UPDATE payments SET created_at = datetime('now', '-1 hours') WHERE id IN (?, ?)`,
+		abandoned.ID, withProvider.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids, err := r.FailAbandonedPayments(ctx, 15*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 || ids[0] != abandoned.ID {
+		t.Fatalf("failed %v, want [%d]", ids, abandoned.ID)
+	}
+	for _, want := range []struct {
+		payment *models.Payment
+		status  string
+	}{
+		{abandoned, models.PaymentStatusFailed},
+		{withProvider, models.PaymentStatusPending},
+		{recent, models.PaymentStatusPending},
+	} {
+		got, err := r.GetPayment(ctx, want.payment.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != want.status {
+			t.Errorf("payment %d is %q, want %q", got.ID, got.Status, want.status)
+		}
+	}
+	if err := r.CreatePayment(ctx, &models.Payment{OrderID: abandoned.OrderID, Provider: "fake"}, 0); err != nil {
+		t.Errorf("pay again after the abandoned payment failed: %v", err)
+	}
+}
+
+func TestCancelPaidOrderRefundsPayment(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+	o, _ := newPaidOrder(t, r, 2)
+	payments, err := r.ListOrderPayments(ctx, o.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.RefundPayment(ctx, payments[0].ID, 30, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	refunds, err := r.CancelOrder(ctx, o.ID, 0, "changed my mind")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refunds) != 1 || refunds[0].ID != payments[0].ID || refunds[0].RefundedAmount != 30 {
+		t.Fatalf("refunds = %+v, want payment %d with 30 refunded before", refunds, payments[0].ID)
+	}
+	if payments, err = r.ListOrderPayments(ctx, o.ID); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range payments {
+		if p.Status == models.PaymentStatusCaptured || p.RefundedAmount != p.Amount {
+			t.Errorf("payment %d is %q with %d of %d refunded, want all of it refunded", p.ID, p.Status, p.RefundedAmount, p.Amount)
+		}
+	}
+
+	// A refund the provider fails to make is taken back.
+	released, err := r.ReleaseRefund(ctx, refunds[0].ID, refunds[0].Amount-refunds[0].RefundedAmount, "provider unavailable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if released.Status != models.PaymentStatusCaptured || released.RefundedAmount != 30 {
+		t.Errorf("after release: status %q refunded %d, want captured and 30", released.Status, released.RefundedAmount)
+	}
+}
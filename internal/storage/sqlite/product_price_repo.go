@@ -0,0 +1,113 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/hitanshu0729/order_go/internal/domain"
+	"github.com/hitanshu0729/order_go/internal/money"
+)
+
+// SetProductPrice creates or replaces an explicit price for a product in a
+// non-base currency.
+func (r *Repo) SetProductPrice(ctx context.Context, productID int64, price money.Money) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO product_prices (product_id, currency, amount) VALUES (?, ?, ?)
+		 ON CONFLICT (product_id, currency) DO UPDATE SET amount = excluded.amount`,
+		productID,
+		price.Currency,
+		price.Amount,
+	)
+	if err != nil {
+		return err
+	}
+	if err := bumpProductVersion(ctx, tx, productID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteProductPrice removes an explicit price.
+func (r *Repo) DeleteProductPrice(ctx context.Context, productID int64, currency string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(
+		ctx,
+		`DELETE FROM product_prices WHERE product_id = ? AND currency = ?`,
+		productID,
+		currency,
+	)
+	if err := expectRow(res, err, domain.ErrProductPriceNotFound); err != nil {
+		return err
+	}
+	if err := bumpProductVersion(ctx, tx, productID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// bumpProductVersion marks a product as changed when one of its prices is,
+// since prices are part of the product's representation.
+func bumpProductVersion(ctx context.Context, tx *sql.Tx, productID int64) error {
+	_, err := tx.ExecContext(ctx, `UPDATE products SET version = version + 1 WHERE id = ?`, productID)
+	return err
+}
+
+// GetProductPrice returns the explicit price of a product in currency, if
+// one has been set.
+func (r *Repo) GetProductPrice(ctx context.Context, productID int64, currency string) (money.Money, bool, error) {
+	var amount int64
+	err := r.db.QueryRowContext(
+		ctx,
+		`SELECT amount FROM product_prices WHERE product_id = ? AND currency = ?`,
+		productID,
+		currency,
+	).Scan(&amount)
+	if err == sql.ErrNoRows {
+		return money.Money{}, false, nil
+	}
+	if err != nil {
+		return money.Money{}, false, err
+	}
+	return money.New(amount, currency), true, nil
+}
+
+// getProductPrices returns explicit prices keyed by product id. When
+// productID is non-zero only that product is loaded.
+func (r *Repo) getProductPrices(ctx context.Context, q querier, productID int64) (map[int64][]money.Money, error) {
+	query := `SELECT product_id, currency, amount FROM product_prices`
+	var args []any
+	if productID != 0 {
+		query += ` WHERE product_id = ?`
+		args = append(args, productID)
+	}
+	query += ` ORDER BY product_id, currency`
+
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prices := map[int64][]money.Money{}
+	for rows.Next() {
+		var id int64
+		var m money.Money
+		if err := rows.Scan(&id, &m.Currency, &m.Amount); err != nil {
+			return nil, err
+		}
+		prices[id] = append(prices[id], m)
+	}
+	return prices, rows.Err()
+}
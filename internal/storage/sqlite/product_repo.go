@@ -3,99 +3,408 @@ package sqlite
 import (
 	"context"
 	"database/sql"
-	"log"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
 
+	"github.com/hitanshu0729/order_go/internal/domain"
 	"github.com/hitanshu0729/order_go/internal/models"
 )
 
-// Create inserts a new product
-func (r *Repo) CreateProduct(
-	ctx context.Context,
-	name string,
-	price, stock int64,
-) error {
-	res, err := r.db.ExecContext(
+// CreateProduct inserts a new product with its variants and records their
+// initial stock in the ledger. A product without a SKU is given "P-<id>".
+// p and its variants get their ids.
+func (r *Repo) CreateProduct(ctx context.Context, p *models.Product) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := r.insertProduct(ctx, tx, p); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "product created",
+		"product_id", p.ID, "sku", p.SKU, "name", p.Name, "price", p.Price, "stock", p.Stock, "variants", len(p.Variants))
+	return nil
+}
+
+func (r *Repo) insertProduct(ctx context.Context, tx *sql.Tx, p *models.Product) error {
+	attrs, err := attributesJSON(p.Attributes)
+	if err != nil {
+		return err
+	}
+	if err := checkCategory(ctx, tx, p.CategoryID); err != nil {
+		return err
+	}
+	if err := checkSKU(ctx, tx, "product_variants", p.SKU); err != nil {
+		return err
+	}
+	res, err := tx.ExecContext(
 		ctx,
-		`INSERT INTO products (name, price, stock) VALUES (?, ?, ?)`,
-		name,
-		price,
-		stock,
+		`INSERT INTO products (sku, name, description, category_id, attributes, price, stock)
+		 VALUES (?, ?, ?, ?, ?, ?, 0)`,
+		p.SKU, p.Name, p.Description, p.CategoryID, attrs, p.Price,
 	)
 	if err != nil {
-		log.Printf("failed to create product: name=%s, price=%d, stock=%d, error=%v", name, price, stock, err)
+		if IsUniqueConstraintError(err) {
+			return fmt.Errorf("%w: %s", domain.ErrDuplicateSKU, p.SKU)
+		}
+		slog.ErrorContext(ctx, "failed to create product", "name", p.Name, "price", p.Price, "stock", p.Stock, "error", err)
 		return err
 	}
-	if rows, err := res.RowsAffected(); err == nil {
-		log.Printf("successfully created product: name=%s, price=%d, stock=%d, rows=%d", name, price, stock, rows)
+	if p.ID, err = res.LastInsertId(); err != nil {
+		return err
+	}
+	if p.SKU == "" {
+		p.SKU = fmt.Sprintf("P-%d", p.ID)
+		if err := checkSKU(ctx, tx, "product_variants", p.SKU); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `UPDATE products SET sku = ? WHERE id = ?`, p.SKU, p.ID)
+		if IsUniqueConstraintError(err) {
+			return fmt.Errorf("%w: %s", domain.ErrDuplicateSKU, p.SKU)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if p.Stock > 0 {
+		err = r.AdjustProductStockTx(ctx, tx, p.ID, p.Stock, models.MovementReasonRestock, nil, "initial stock")
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to record initial stock", "product_id", p.ID, "stock", p.Stock, "error", err)
+			return err
+		}
+	}
+	for _, v := range p.Variants {
+		v.ProductID = p.ID
+		if err := r.insertVariant(ctx, tx, v); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-// GetProducts returns all products
-func (r *Repo) GetProducts(ctx context.Context) ([]*models.Product, error) {
-	rows, err := r.db.QueryContext(
-		ctx,
-		`SELECT id, name, price, stock FROM products`,
+// UpdateProduct stores p's SKU, name, description, category, attributes
+// and base price. A non-zero version must match the product's.
+func (r *Repo) UpdateProduct(ctx context.Context, p *models.Product, version int64) error {
+	attrs, err := attributesJSON(p.Attributes)
+	if err != nil {
+		return err
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := checkCategory(ctx, tx, p.CategoryID); err != nil {
+		return err
+	}
+	if err := checkSKU(ctx, tx, "product_variants", p.SKU); err != nil {
+		return err
+	}
+	res, err := tx.ExecContext(ctx,
+		`UPDATE products
+		 SET sku = ?, name = ?, description = ?, category_id = ?, attributes = ?, price = ?,
+		     version = version + 1
+		 WHERE id = ? AND deleted_at IS NULL AND `+versionMatch,
+		p.SKU, p.Name, p.Description, p.CategoryID, attrs, p.Price,
+		p.ID, version, version,
 	)
+	if IsUniqueConstraintError(err) {
+		return fmt.Errorf("%w: %s", domain.ErrDuplicateSKU, p.SKU)
+	}
+	if err := expectRow(res, err, domain.ErrProductNotFound); err != domain.ErrProductNotFound {
+		if err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+	return checkVersion(ctx, tx, "products", p.ID, version, domain.ErrProductNotFound)
+}
+
+const productColumns = `id, sku, name, description, category_id, attributes, price, stock, version, deleted_at`
+
+// ProductFilter narrows GetProducts.
+type ProductFilter struct {
+	IncludeDeleted bool
+
+	// Category is a category id or slug. Products in it or any of its
+	// descendants match.
+	Category string
+
+	// Attributes must all match, each on the product itself or on one of
+	// its live variants. Keys are restricted to letters, digits, '_' and
+	// '-' by the caller.
+	Attributes map[string]string
+}
+
+// GetProducts returns the products matching filter, with their prices and
+// live variants.
+func (r *Repo) GetProducts(ctx context.Context, filter ProductFilter) ([]*models.Product, error) {
+	query := `SELECT ` + productColumns + ` FROM products p`
+	var conditions []string
+	var args []any
+
+	if !filter.IncludeDeleted {
+		conditions = append(conditions, "p.deleted_at IS NULL")
+	}
+	if filter.Category != "" {
+		conditions = append(conditions, `p.category_id IN (
+			WITH RECURSIVE tree(id) AS (
+				SELECT id FROM categories WHERE slug = ? OR CAST(id AS TEXT) = ?
+				UNION
+				SELECT c.id FROM categories c JOIN tree t ON c.parent_id = t.id
+			)
+			SELECT id FROM tree)`)
+		args = append(args, filter.Category, filter.Category)
+	}
+	for _, key := range slices.Sorted(maps.Keys(filter.Attributes)) {
+		path := `$."` + key + `"`
+		conditions = append(conditions, `(json_extract(p.attributes, ?) = ? OR EXISTS (
+			SELECT 1 FROM product_variants v
+			WHERE v.product_id = p.id AND v.deleted_at IS NULL AND json_extract(v.attributes, ?) = ?))`)
+		args = append(args, path, filter.Attributes[key], path, filter.Attributes[key])
+	}
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY p.id"
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		log.Printf("failed to get products: %v", err)
+		slog.ErrorContext(ctx, "failed to get products", "error", err)
 		return nil, err
 	}
 	defer rows.Close()
 
 	var products []*models.Product
 	for rows.Next() {
-		var p models.Product
-		if err := rows.Scan(&p.ID, &p.Name, &p.Price, &p.Stock); err != nil {
-			log.Printf("error scanning product: %v", err)
+		p, err := scanProduct(rows)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to scan product", "error", err)
 			return nil, err
 		}
-		products = append(products, &p)
+		products = append(products, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
-	log.Printf("retrieved %d products", len(products))
+
+	prices, err := r.getProductPrices(ctx, r.db, 0)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to get product prices", "error", err)
+		return nil, err
+	}
+	variants, err := getProductVariants(ctx, r.db, 0)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to get product variants", "error", err)
+		return nil, err
+	}
+	for _, p := range products {
+		p.Prices = prices[p.ID]
+		p.Variants = variants[p.ID]
+	}
+	slog.DebugContext(ctx, "retrieved products", "count", len(products))
 	return products, nil
 }
 
-// GetProductByID returns a product by id
+// GetProductByID returns a live (not soft-deleted) product by id
 func (r *Repo) GetProductByID(
 	ctx context.Context,
 	id int64,
 ) (*models.Product, error) {
-	row := r.db.QueryRowContext(
-		ctx,
-		`SELECT id, name, price, stock FROM products WHERE id = ?`,
-		id,
-	)
+	return r.getProduct(ctx, `SELECT `+productColumns+` FROM products WHERE id = ? AND deleted_at IS NULL`, id)
+}
 
-	var p models.Product
-	if err := row.Scan(&p.ID, &p.Name, &p.Price, &p.Stock); err != nil {
+// GetProductByIDUnscoped returns a product regardless of soft-delete state
+func (r *Repo) GetProductByIDUnscoped(
+	ctx context.Context,
+	id int64,
+) (*models.Product, error) {
+	return r.getProduct(ctx, `SELECT `+productColumns+` FROM products WHERE id = ?`, id)
+}
+
+func (r *Repo) getProduct(ctx context.Context, query string, id int64) (*models.Product, error) {
+	return r.getProductFrom(ctx, r.db, query, id)
+}
+
+func (r *Repo) getProductFrom(ctx context.Context, q querier, query string, id int64) (*models.Product, error) {
+	p, err := scanProduct(q.QueryRowContext(ctx, query, id))
+	if err != nil {
 		if err == sql.ErrNoRows {
-			log.Printf("product not found with id: %d", id)
-			return nil, nil
+			slog.DebugContext(ctx, "product not found", "product_id", id)
+			return nil, domain.ErrProductNotFound
 		}
-		log.Printf("failed to get product by id=%d: %v", id, err)
+		slog.ErrorContext(ctx, "failed to get product", "product_id", id, "error", err)
+		return nil, err
+	}
+	prices, err := r.getProductPrices(ctx, q, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to get product prices", "product_id", id, "error", err)
+		return nil, err
+	}
+	p.Prices = prices[id]
+	variants, err := getProductVariants(ctx, q, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to get product variants", "product_id", id, "error", err)
 		return nil, err
 	}
-	log.Printf("retrieved product: id=%d, name=%s", p.ID, p.Name)
+	p.Variants = variants[id]
+	slog.DebugContext(ctx, "retrieved product", "product_id", p.ID)
+	return p, nil
+}
+
+// DeleteProduct soft-deletes a product by id
+func (r *Repo) DeleteProduct(ctx context.Context, id, version int64) error {
+	if err := r.softDelete(ctx, "products", id, version, domain.ErrProductNotFound); err != nil {
+		slog.WarnContext(ctx, "failed to delete product", "product_id", id, "error", err)
+		return err
+	}
+	slog.InfoContext(ctx, "product soft-deleted", "product_id", id)
+	return nil
+}
+
+// RestoreProduct clears the soft-delete marker on a product
+func (r *Repo) RestoreProduct(ctx context.Context, id, version int64) error {
+	return r.restore(ctx, "products", id, version, domain.ErrProductNotFound)
+}
+
+func scanProduct(s scanner) (*models.Product, error) {
+	var p models.Product
+	var categoryID sql.NullInt64
+	var attrs string
+	var deletedAt sql.NullTime
+	if err := s.Scan(
+		&p.ID, &p.SKU, &p.Name, &p.Description, &categoryID, &attrs,
+		&p.Price, &p.Stock, &p.Version, &deletedAt,
+	); err != nil {
+		return nil, err
+	}
+	if categoryID.Valid {
+		p.CategoryID = &categoryID.Int64
+	}
+	if err := json.Unmarshal([]byte(attrs), &p.Attributes); err != nil {
+		return nil, err
+	}
+	if deletedAt.Valid {
+		p.DeletedAt = &deletedAt.Time
+	}
 	return &p, nil
 }
 
-// Delete deletes a product by id
-func (r *Repo) Delete(ctx context.Context, id int64) error {
+// attributesJSON encodes product or variant attributes for storage.
+func attributesJSON(attrs map[string]string) (string, error) {
+	if len(attrs) == 0 {
+		return "{}", nil
+	}
+	b, err := json.Marshal(attrs)
+	return string(b), err
+}
 
-	res, err := r.db.ExecContext(
-		ctx,
-		`DELETE FROM products WHERE id = ?`,
-		id,
-	)
+// checkCategory reports ErrCategoryNotFound unless id is nil or names a
+// category.
+func checkCategory(ctx context.Context, q queryRower, id *int64) error {
+	if id == nil {
+		return nil
+	}
+	var exists int
+	err := q.QueryRowContext(ctx, `SELECT 1 FROM categories WHERE id = ?`, *id).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%w: %d", domain.ErrCategoryNotFound, *id)
+	}
+	return err
+}
+
+// checkSKU reports ErrDuplicateSKU when sku is taken in table, which is
+// products or product_variants. SKUs are unique across both; each table's
+// own unique index covers the rest.
+func checkSKU(ctx context.Context, q queryRower, table, sku string) error {
+	if sku == "" {
+		return nil
+	}
+	var exists int
+	err := q.QueryRowContext(ctx, `SELECT 1 FROM `+table+` WHERE sku = ?`, sku).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return nil
+	}
 	if err != nil {
-		log.Printf("failed to delete product id=%d: %v", id, err)
 		return err
 	}
-	if rows, err := res.RowsAffected(); err == nil {
-		log.Printf("successfully deleted product id=%d, rows=%d", id, rows)
-	} else {
-		log.Printf("deleted product id=%d but failed to get rows affected: %v", id, err)
+	return fmt.Errorf("%w: %s", domain.ErrDuplicateSKU, sku)
+}
+
+// ProductAttributeNames returns the attribute names used by live products
+// and their live variants, sorted.
+func (r *Repo) ProductAttributeNames(ctx context.Context) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT a.key FROM products p, json_each(p.attributes) a WHERE p.deleted_at IS NULL
+		 UNION
+		 SELECT a.key FROM product_variants v
+		 JOIN products p ON p.id = v.product_id, json_each(v.attributes) a
+		 WHERE v.deleted_at IS NULL AND p.deleted_at IS NULL
+		 ORDER BY 1`)
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// ExportProducts calls fn with every live product, each followed by its
+// live variants, as rows are read from the database. It stops at the first
+// error from fn.
+func (r *Repo) ExportProducts(ctx context.Context, fn func(*models.ProductRow) error) error {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT p.id, 0, p.sku, '', p.name, p.description, COALESCE(c.slug, ''), p.attributes, p.price, p.stock
+		 FROM products p LEFT JOIN categories c ON c.id = p.category_id
+		 WHERE p.deleted_at IS NULL
+		 UNION ALL
+		 SELECT v.product_id, v.id, v.sku, p.sku, NULL, NULL, NULL, v.attributes, v.price, v.stock
+		 FROM product_variants v JOIN products p ON p.id = v.product_id
+		 WHERE v.deleted_at IS NULL AND p.deleted_at IS NULL
+		 ORDER BY 1, 2`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var productID, variantID, price, stock int64
+		var row models.ProductRow
+		var name, description, category sql.NullString
+		var attrs string
+		if err := rows.Scan(
+			&productID, &variantID, &row.SKU, &row.ParentSKU, &name, &description, &category, &attrs, &price, &stock,
+		); err != nil {
+			return err
+		}
+		if err := json.Unmarshal([]byte(attrs), &row.Attributes); err != nil {
+			return err
+		}
+		if variantID == 0 {
+			row.Name, row.Description, row.Category = &name.String, &description.String, &category.String
+		}
+		row.Price, row.Stock = &price, &stock
+		if err := fn(&row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
 }
@@ -0,0 +1,100 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/hitanshu0729/order_go/internal/domain"
+	"github.com/hitanshu0729/order_go/internal/models"
+	"github.com/hitanshu0729/order_go/internal/pricing"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// newTestRepo returns a Repo over a fresh SQLite file with every migration
+// applied, opened the same way the server opens its database.
+func newTestRepo(t *testing.T) *Repo {
+	t.Helper()
+	return newSeededTestRepo(t, nil)
+}
+
+// newSeededTestRepo is newTestRepo, calling seed, when not nil, with the
+// database and the file name of each migration before it is applied, so
+// that a test can load the rows a migration converts.
+func newSeededTestRepo(t *testing.T, seed func(db *sql.DB, migration string)) *Repo {
+	t.Helper()
+
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_txlock=immediate&_busy_timeout=5000&_foreign_keys=on"
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	files, err := filepath.Glob("../../../migrations/*.up.sql")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no migrations found: %v", err)
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		if seed != nil {
+			seed(db, filepath.Base(f))
+		}
+		b, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(string(b)); err != nil {
+			t.Fatalf("%s: %v", filepath.Base(f), err)
+		}
+	}
+
+	cfg, err := pricing.LoadConfig("../../../pricing.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewRepo(db, pricing.NewEngine(cfg, "INR"))
+}
+
+// createTestProduct inserts a product with the given base price and stock.
+func createTestProduct(t *testing.T, r *Repo, price, stock int64) *models.Product {
+	t.Helper()
+	p := &models.Product{Name: "product", Price: price, Stock: stock}
+	if err := r.CreateProduct(context.Background(), p); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// createTestOrder inserts a pending INR order for the test's user, creating
+// the user on first use.
+func createTestOrder(t *testing.T, r *Repo) *models.Order {
+	t.Helper()
+	ctx := context.Background()
+	email := t.Name() + "@example.com"
+	u, err := r.GetUserByEmail(ctx, email)
+	if errors.Is(err, domain.ErrUserNotFound) {
+		if err := r.CreateUser(ctx, "user", email, ""); err != nil {
+			t.Fatal(err)
+		}
+		u, err = r.GetUserByEmail(ctx, email)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := &models.Order{
+		UserID:          int64(u.ID),
+		Status:          "pending",
+		Currency:        "INR",
+		ExchangeRate:    "1",
+		TaxJurisdiction: "IN-KA",
+	}
+	if err := r.CreateOrder(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
@@ -0,0 +1,358 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/hitanshu0729/order_go/internal/domain"
+	"github.com/hitanshu0729/order_go/internal/models"
+)
+
+const returnColumns = `id, order_id, user_id, status, currency, refund_amount, note,
+	created_at, updated_at, decided_at, received_at`
+
+const returnItemColumns = `id, return_id, order_item_id, product_id, variant_id, quantity, reason, note, refund_amount`
+
+// CreateReturn requests a return of ret.Items from the shipped order
+// ret.OrderID and queues a return.requested event. Each item names an order
+// line, which may appear once, and at most the shipped units of it not
+// already in a return that was not rejected. Refunds are priced from the order's
+// breakdown. ret is reloaded with its id, items and amounts.
+func (r *Repo) CreateReturn(ctx context.Context, ret *models.Return) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	order, err := liveOrderTx(ctx, tx, ret.OrderID)
+	if err != nil {
+		return err
+	}
+	switch order.Status {
+	case "partially_shipped", "shipped", "delivered":
+	default:
+		return fmt.Errorf("%w: only shipped orders can be returned, order is %s", domain.ErrInvalidOrderStatus, order.Status)
+	}
+	if order.Pricing == nil {
+		return fmt.Errorf("%w: order has no pricing breakdown to refund from", domain.ErrInvalidReturn)
+	}
+
+	lines, err := getOrderItems(ctx, tx, order.ID)
+	if err != nil {
+		return err
+	}
+	byID := make(map[int64]*models.OrderItem, len(lines))
+	for _, line := range lines {
+		byID[line.ID] = line
+	}
+
+	var total int64
+	seen := make(map[int64]bool, len(ret.Items))
+	for _, item := range ret.Items {
+		line, ok := byID[item.OrderItemID]
+		if !ok {
+			return fmt.Errorf("%w: item %d is not part of order %d", domain.ErrInvalidReturn, item.OrderItemID, order.ID)
+		}
+		if seen[line.ID] {
+			return fmt.Errorf("%w: item %d is listed more than once", domain.ErrInvalidReturn, line.ID)
+		}
+		seen[line.ID] = true
+
+		shipped, err := shippedQuantity(ctx, tx, line.ID)
+		if err != nil {
+			return err
+		}
+		returned, err := returnedQuantity(ctx, tx, line.ID)
+		if err != nil {
+			return err
+		}
+		if left := shipped - returned; item.Quantity > left {
+			return fmt.Errorf("%w: item %d has %d of %d shipped units left to return", domain.ErrInvalidReturn, line.ID, left, shipped)
+		}
+		refund, err := order.Pricing.Refund(line.ID, item.Quantity)
+		if err != nil {
+			return err
+		}
+		item.ProductID, item.VariantID, item.RefundAmount = line.ProductID, line.VariantID, refund
+		total += refund
+	}
+
+	var id int64
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO returns (order_id, user_id, currency, refund_amount) VALUES (?, ?, ?, ?) RETURNING id`,
+		order.ID, order.UserID, order.Currency, total,
+	).Scan(&id)
+	if err != nil {
+		return err
+	}
+	events := make([]map[string]any, 0, len(ret.Items))
+	for _, item := range ret.Items {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO return_items (return_id, order_item_id, product_id, variant_id, quantity, reason, note, refund_amount)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			id, item.OrderItemID, item.ProductID, item.VariantID, item.Quantity, item.Reason, item.Note, item.RefundAmount,
+		)
+		if err != nil {
+			return err
+		}
+		events = append(events, map[string]any{
+			"order_item_id": item.OrderItemID,
+			"product_id":    item.ProductID,
+			"variant_id":    item.VariantID,
+			"quantity":      item.Quantity,
+			"reason":        item.Reason,
+			"refund_amount": item.RefundAmount,
+		})
+	}
+	err = r.enqueueEventTx(ctx, tx, "return.requested", map[string]any{
+		"return_id":     id,
+		"order_id":      order.ID,
+		"user_id":       order.UserID,
+		"currency":      order.Currency,
+		"refund_amount": total,
+		"items":         events,
+	})
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	created, err := r.GetReturn(ctx, id)
+	if err != nil {
+		return err
+	}
+	*ret = *created
+	return nil
+}
+
+// returnedQuantity returns how many units of an order line are in returns
+// that were not rejected.
+func returnedQuantity(ctx context.Context, q queryRower, orderItemID int64) (int64, error) {
+	var n int64
+	err := q.QueryRowContext(ctx,
+		`SELECT COALESCE(SUM(ri.quantity), 0)
+		 FROM return_items ri JOIN returns rt ON rt.id = ri.return_id
+		 WHERE ri.order_item_id = ? AND rt.status != 'rejected'`,
+		orderItemID,
+	).Scan(&n)
+	return n, err
+}
+
+// GetReturn returns a return with its items.
+func (r *Repo) GetReturn(ctx context.Context, id int64) (*models.Return, error) {
+	ret, err := scanReturn(r.db.QueryRowContext(ctx, `SELECT `+returnColumns+` FROM returns WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrReturnNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if ret.Items, err = getReturnItems(ctx, r.db, id); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// ListOrderReturns returns an order's returns with their items, oldest
+// first.
+func (r *Repo) ListOrderReturns(ctx context.Context, orderID int64) ([]*models.Return, error) {
+	return r.queryReturns(ctx, `SELECT `+returnColumns+` FROM returns WHERE order_id = ? ORDER BY id`, orderID)
+}
+
+// ListReturns returns returns with their items, newest first, optionally
+// only those with the given status.
+func (r *Repo) ListReturns(ctx context.Context, status string) ([]*models.Return, error) {
+	return r.queryReturns(ctx,
+		`SELECT `+returnColumns+` FROM returns WHERE (? = '' OR status = ?) ORDER BY id DESC`, status, status)
+}
+
+func (r *Repo) queryReturns(ctx context.Context, query string, args ...any) ([]*models.Return, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	returns := []*models.Return{}
+	for rows.Next() {
+		ret, err := scanReturn(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		returns = append(returns, ret)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, ret := range returns {
+		if ret.Items, err = getReturnItems(ctx, r.db, ret.ID); err != nil {
+			return nil, err
+		}
+	}
+	return returns, nil
+}
+
+// ApproveReturn approves a requested return and queues a return.approved
+// event.
+func (r *Repo) ApproveReturn(ctx context.Context, id int64, note string) (*models.Return, error) {
+	return r.decideReturn(ctx, id, models.ReturnStatusApproved, note)
+}
+
+// RejectReturn rejects a requested return and queues a return.rejected
+// event. Its units can be returned again.
+func (r *Repo) RejectReturn(ctx context.Context, id int64, note string) (*models.Return, error) {
+	return r.decideReturn(ctx, id, models.ReturnStatusRejected, note)
+}
+
+func (r *Repo) decideReturn(ctx context.Context, id int64, status, note string) (*models.Return, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	ret, err := scanReturn(tx.QueryRowContext(ctx,
+		`UPDATE returns
+		 SET status = ?, note = ?, decided_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = ? AND status = 'requested'
+		 RETURNING `+returnColumns,
+		status, note, id,
+	))
+	if err == sql.ErrNoRows {
+		return nil, returnStatusError(ctx, tx, id, "only requested returns can be "+status)
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = r.enqueueEventTx(ctx, tx, "return."+status, map[string]any{
+		"return_id": ret.ID,
+		"order_id":  ret.OrderID,
+		"user_id":   ret.UserID,
+		"note":      note,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.GetReturn(ctx, id)
+}
+
+// ReceiveReturn records the goods of an approved return as received,
+// restocks its units with 'return' entries in the inventory ledger that
+// reference the order, and queues a return.received event carrying the
+// refund owed.
+func (r *Repo) ReceiveReturn(ctx context.Context, id int64) (*models.Return, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	ret, err := scanReturn(tx.QueryRowContext(ctx,
+		`UPDATE returns
+		 SET status = 'received', received_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = ? AND status = 'approved'
+		 RETURNING `+returnColumns,
+		id,
+	))
+	if err == sql.ErrNoRows {
+		return nil, returnStatusError(ctx, tx, id, "only approved returns can be received")
+	}
+	if err != nil {
+		return nil, err
+	}
+	items, err := getReturnItems(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	note := fmt.Sprintf("return %d", id)
+	for _, item := range items {
+		if item.VariantID != nil {
+			err = r.AdjustVariantStockTx(ctx, tx, *item.VariantID, item.Quantity, models.MovementReasonReturn, &ret.OrderID, note)
+		} else {
+			err = r.AdjustProductStockTx(ctx, tx, item.ProductID, item.Quantity, models.MovementReasonReturn, &ret.OrderID, note)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = r.enqueueEventTx(ctx, tx, "return.received", map[string]any{
+		"return_id":     ret.ID,
+		"order_id":      ret.OrderID,
+		"user_id":       ret.UserID,
+		"currency":      ret.Currency,
+		"refund_amount": ret.RefundAmount,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.GetReturn(ctx, id)
+}
+
+// returnStatusError explains a status change guarded on the return's
+// current status that touched no row.
+func returnStatusError(ctx context.Context, q queryRower, id int64, msg string) error {
+	var status string
+	err := q.QueryRowContext(ctx, `SELECT status FROM returns WHERE id = ?`, id).Scan(&status)
+	if err == sql.ErrNoRows {
+		return domain.ErrReturnNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: %s, return is %s", domain.ErrInvalidReturnStatus, msg, status)
+}
+
+func getReturnItems(ctx context.Context, q querier, returnID int64) ([]*models.ReturnItem, error) {
+	rows, err := q.QueryContext(ctx,
+		`SELECT `+returnItemColumns+` FROM return_items WHERE return_id = ? ORDER BY id`, returnID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []*models.ReturnItem{}
+	for rows.Next() {
+		var item models.ReturnItem
+		var variantID sql.NullInt64
+		if err := rows.Scan(
+			&item.ID, &item.ReturnID, &item.OrderItemID, &item.ProductID, &variantID,
+			&item.Quantity, &item.Reason, &item.Note, &item.RefundAmount,
+		); err != nil {
+			return nil, err
+		}
+		if variantID.Valid {
+			item.VariantID = &variantID.Int64
+		}
+		items = append(items, &item)
+	}
+	return items, rows.Err()
+}
+
+func scanReturn(s scanner) (*models.Return, error) {
+	var ret models.Return
+	var decidedAt, receivedAt sql.NullTime
+	if err := s.Scan(
+		&ret.ID, &ret.OrderID, &ret.UserID, &ret.Status, &ret.Currency, &ret.RefundAmount, &ret.Note,
+		&ret.CreatedAt, &ret.UpdatedAt, &decidedAt, &receivedAt,
+	); err != nil {
+		return nil, err
+	}
+	if decidedAt.Valid {
+		ret.DecidedAt = &decidedAt.Time
+	}
+	if receivedAt.Valid {
+		ret.ReceivedAt = &receivedAt.Time
+	}
+	return &ret, nil
+}
@@ -0,0 +1,137 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hitanshu0729/order_go/internal/domain"
+	"github.com/hitanshu0729/order_go/internal/models"
+)
+
+// newPaidOrder returns an order for quantity units of a product with 10 in
+// stock, paid through a captured payment.
+func newPaidOrder(t *testing.T, r *Repo, quantity int64) (*models.Order, *models.Product) {
+	t.Helper()
+	ctx := context.Background()
+	p := createTestProduct(t, r, 100, 10)
+	o := createTestOrder(t, r)
+	item := &models.OrderItem{OrderID: o.ID, ProductID: p.ID, Quantity: quantity, Price: p.Price}
+	if err := r.AddOrderItem(ctx, item, 0); err != nil {
+		t.Fatal(err)
+	}
+	payment := &models.Payment{OrderID: o.ID, Provider: "fake"}
+	if err := r.CreatePayment(ctx, payment, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.CapturePayment(ctx, payment.ID, models.PaymentStatusPending); err != nil {
+		t.Fatal(err)
+	}
+	return getTestOrder(t, r, o.ID), p
+}
+
+// newShippedOrder returns a paid order for quantity units, shipped in full,
+// and its only line.
+func newShippedOrder(t *testing.T, r *Repo, quantity int64) (*models.Order, *models.OrderItem) {
+	t.Helper()
+	ctx := context.Background()
+	o, _ := newPaidOrder(t, r, quantity)
+	if _, err := r.ShipOrder(ctx, o.ID, 0, "ups", "1Z"); err != nil {
+		t.Fatal(err)
+	}
+	items, err := r.GetOrderItems(ctx, o.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return getTestOrder(t, r, o.ID), items[0]
+}
+
+func TestReturnStateMachine(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+	o, line := newShippedOrder(t, r, 2)
+	stock := productStock(t, r, line.ProductID)
+
+	ret := &models.Return{OrderID: o.ID, Items: []*models.ReturnItem{
+		{OrderItemID: line.ID, Quantity: 1, Reason: models.ReturnReasonDamaged},
+	}}
+	if err := r.CreateReturn(ctx, ret); err != nil {
+		t.Fatal(err)
+	}
+	if ret.Status != models.ReturnStatusRequested || ret.RefundAmount <= 0 || len(ret.Items) != 1 {
+		t.Fatalf("return = %+v, want a requested return of one line with a refund", ret)
+	}
+
+	if _, err := r.ReceiveReturn(ctx, ret.ID); !errors.Is(err, domain.ErrInvalidReturnStatus) {
+		t.Errorf("receive requested return: err = %v, want %v", err, domain.ErrInvalidReturnStatus)
+	}
+	approved, err := r.ApproveReturn(ctx, ret.ID, "ok")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if approved.Status != models.ReturnStatusApproved || approved.DecidedAt == nil {
+		t.Errorf("approved return = %+v, want approved with a decision time", approved)
+	}
+	if _, err := r.RejectReturn(ctx, ret.ID, ""); !errors.Is(err, domain.ErrInvalidReturnStatus) {
+		t.Errorf("reject approved return: err = %v, want %v", err, domain.ErrInvalidReturnStatus)
+	}
+
+	received, err := r.ReceiveReturn(ctx, ret.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if received.Status != models.ReturnStatusReceived || received.ReceivedAt == nil {
+		t.Errorf("received return = %+v, want received with a receipt time", received)
+	}
+	if got := productStock(t, r, line.ProductID); got != stock+1 || ledgerSum(t, r, line.ProductID) != got {
+		t.Errorf("stock = %d, ledger = %d, want both %d", got, ledgerSum(t, r, line.ProductID), stock+1)
+	}
+	if _, err := r.ReceiveReturn(ctx, ret.ID); !errors.Is(err, domain.ErrInvalidReturnStatus) {
+		t.Errorf("receive twice: err = %v, want %v", err, domain.ErrInvalidReturnStatus)
+	}
+	if _, err := r.ApproveReturn(ctx, ret.ID+1, ""); !errors.Is(err, domain.ErrReturnNotFound) {
+		t.Errorf("approve missing return: err = %v, want %v", err, domain.ErrReturnNotFound)
+	}
+}
+
+func TestRejectedReturnFreesUnits(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+	o, line := newShippedOrder(t, r, 2)
+	newReturn := func(quantity int64) *models.Return {
+		return &models.Return{OrderID: o.ID, Items: []*models.ReturnItem{
+			{OrderItemID: line.ID, Quantity: quantity, Reason: models.ReturnReasonChangedMind},
+		}}
+	}
+
+	first := newReturn(2)
+	if err := r.CreateReturn(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.CreateReturn(ctx, newReturn(1)); !errors.Is(err, domain.ErrInvalidReturn) {
+		t.Fatalf("return more than shipped: err = %v, want %v", err, domain.ErrInvalidReturn)
+	}
+	if _, err := r.RejectReturn(ctx, first.ID, "worn"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.CreateReturn(ctx, newReturn(2)); err != nil {
+		t.Errorf("return after rejection: %v", err)
+	}
+}
+
+func TestCreateReturnRequiresShippedOrder(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+	o, _ := newPaidOrder(t, r, 1)
+	items, err := r.GetOrderItems(ctx, o.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ret := &models.Return{OrderID: o.ID, Items: []*models.ReturnItem{
+		{OrderItemID: items[0].ID, Quantity: 1, Reason: models.ReturnReasonOther},
+	}}
+	if err := r.CreateReturn(ctx, ret); !errors.Is(err, domain.ErrInvalidOrderStatus) {
+		t.Errorf("return of unshipped order: err = %v, want %v", err, domain.ErrInvalidOrderStatus)
+	}
+}
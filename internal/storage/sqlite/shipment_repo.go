@@ -0,0 +1,455 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/hitanshu0729/order_go/internal/domain"
+	"github.com/hitanshu0729/order_go/internal/models"
+)
+
+const shipmentColumns = `id, order_id, status, carrier, tracking_number,
+	created_at, updated_at, shipped_at, delivered_at`
+
+// CreateShipment packs s.Items of the paid order s.OrderID into a new
+// shipment and queues a shipment.packed event. Each item names an order
+// line, which may appear once, and at most the units of it not already in
+// a shipment; without items, every unit left is packed. s is reloaded with
+// its id and items.
+func (r *Repo) CreateShipment(ctx context.Context, s *models.Shipment) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	order, err := liveOrderTx(ctx, tx, s.OrderID)
+	if err != nil {
+		return err
+	}
+	id, err := r.createShipmentTx(ctx, tx, order, s)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	created, err := r.GetShipment(ctx, id)
+	if err != nil {
+		return err
+	}
+	*s = *created
+	return nil
+}
+
+// ShipOrder packs every unit of the order not yet in a shipment into one
+// shipment and ships it at once with carrier and trackingNumber. A non-zero
+// version must match the order's.
+func (r *Repo) ShipOrder(ctx context.Context, orderID, version int64, carrier, trackingNumber string) (*models.Shipment, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	order, err := liveOrderTx(ctx, tx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if err := versionError(version, order.Version); err != nil {
+		return nil, err
+	}
+	id, err := r.createShipmentTx(ctx, tx, order, &models.Shipment{Carrier: carrier, TrackingNumber: trackingNumber})
+	if err != nil {
+		return nil, err
+	}
+	if err := r.shipShipmentTx(ctx, tx, id, "", ""); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.GetShipment(ctx, id)
+}
+
+// createShipmentTx inserts s, with its items checked against order, read
+// inside tx, and queues a shipment.packed event.
+func (r *Repo) createShipmentTx(ctx context.Context, tx *sql.Tx, order *models.Order, s *models.Shipment) (int64, error) {
+	if order.Status != "paid" && order.Status != "partially_shipped" {
+		return 0, fmt.Errorf("%w: only paid orders can be shipped, order is %s", domain.ErrInvalidOrderStatus, order.Status)
+	}
+	lines, err := getOrderItems(ctx, tx, order.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	left := make(map[int64]int64, len(lines))
+	for _, line := range lines {
+		packed, err := packedQuantity(ctx, tx, line.ID)
+		if err != nil {
+			return 0, err
+		}
+		left[line.ID] = line.Quantity - packed
+	}
+
+	items := s.Items
+	if len(items) == 0 {
+		for _, line := range lines {
+			if left[line.ID] > 0 {
+				items = append(items, &models.ShipmentItem{OrderItemID: line.ID, Quantity: left[line.ID]})
+			}
+		}
+		if len(items) == 0 {
+			return 0, fmt.Errorf("%w: every unit of order %d is already in a shipment", domain.ErrInvalidShipment, order.ID)
+		}
+	}
+	seen := make(map[int64]bool, len(items))
+	for _, item := range items {
+		n, ok := left[item.OrderItemID]
+		if !ok {
+			return 0, fmt.Errorf("%w: item %d is not part of order %d", domain.ErrInvalidShipment, item.OrderItemID, order.ID)
+		}
+		if seen[item.OrderItemID] {
+			return 0, fmt.Errorf("%w: item %d is listed more than once", domain.ErrInvalidShipment, item.OrderItemID)
+		}
+		seen[item.OrderItemID] = true
+		if item.Quantity > n {
+			return 0, fmt.Errorf("%w: item %d has %d units left to ship", domain.ErrInvalidShipment, item.OrderItemID, n)
+		}
+	}
+
+	var id int64
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO shipments (order_id, carrier, tracking_number) VALUES (?, ?, ?) RETURNING id`,
+		order.ID, s.Carrier, s.TrackingNumber,
+	).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	events := make([]map[string]any, 0, len(items))
+	for _, item := range items {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO shipment_items (shipment_id, order_item_id, quantity) VALUES (?, ?, ?)`,
+			id, item.OrderItemID, item.Quantity,
+		)
+		if err != nil {
+			return 0, err
+		}
+		events = append(events, map[string]any{
+			"order_item_id": item.OrderItemID,
+			"quantity":      item.Quantity,
+		})
+	}
+	err = r.enqueueEventTx(ctx, tx, "shipment.packed", map[string]any{
+		"shipment_id": id,
+		"order_id":    order.ID,
+		"user_id":     order.UserID,
+		"items":       events,
+	})
+	return id, err
+}
+
+// packedQuantity returns how many units of an order line are in shipments,
+// whatever their status.
+func packedQuantity(ctx context.Context, q queryRower, orderItemID int64) (int64, error) {
+	var n int64
+	err := q.QueryRowContext(ctx,
+		`SELECT COALESCE(SUM(quantity), 0) FROM shipment_items WHERE order_item_id = ?`,
+		orderItemID,
+	).Scan(&n)
+	return n, err
+}
+
+// shippedQuantity returns how many units of an order line are in shipments
+// that have been shipped.
+func shippedQuantity(ctx context.Context, q queryRower, orderItemID int64) (int64, error) {
+	var n int64
+	err := q.QueryRowContext(ctx,
+		`SELECT COALESCE(SUM(si.quantity), 0)
+		 FROM shipment_items si JOIN shipments s ON s.id = si.shipment_id
+		 WHERE si.order_item_id = ? AND s.status IN ('shipped', 'delivered')`,
+		orderItemID,
+	).Scan(&n)
+	return n, err
+}
+
+// GetShipment returns a shipment with its items.
+func (r *Repo) GetShipment(ctx context.Context, id int64) (*models.Shipment, error) {
+	s, err := scanShipment(r.db.QueryRowContext(ctx, `SELECT `+shipmentColumns+` FROM shipments WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrShipmentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if s.Items, err = getShipmentItems(ctx, r.db, id); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ListOrderShipments returns an order's shipments with their items, oldest
+// first.
+func (r *Repo) ListOrderShipments(ctx context.Context, orderID int64) ([]*models.Shipment, error) {
+	return r.queryShipments(ctx, `SELECT `+shipmentColumns+` FROM shipments WHERE order_id = ? ORDER BY id`, orderID)
+}
+
+// ListShipments returns shipments with their items, newest first,
+// optionally only those with the given status.
+func (r *Repo) ListShipments(ctx context.Context, status string) ([]*models.Shipment, error) {
+	return r.queryShipments(ctx,
+		`SELECT `+shipmentColumns+` FROM shipments WHERE (? = '' OR status = ?) ORDER BY id DESC`, status, status)
+}
+
+func (r *Repo) queryShipments(ctx context.Context, query string, args ...any) ([]*models.Shipment, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	shipments := []*models.Shipment{}
+	for rows.Next() {
+		s, err := scanShipment(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		shipments = append(shipments, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, s := range shipments {
+		if s.Items, err = getShipmentItems(ctx, r.db, s.ID); err != nil {
+			return nil, err
+		}
+	}
+	return shipments, nil
+}
+
+// ShipShipment hands a packed shipment to its carrier, setting carrier and
+// trackingNumber when given, updates its order's status and queues a
+// shipment.shipped event.
+func (r *Repo) ShipShipment(ctx context.Context, id int64, carrier, trackingNumber string) (*models.Shipment, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := r.shipShipmentTx(ctx, tx, id, carrier, trackingNumber); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.GetShipment(ctx, id)
+}
+
+func (r *Repo) shipShipmentTx(ctx context.Context, tx *sql.Tx, id int64, carrier, trackingNumber string) error {
+	s, err := scanShipment(tx.QueryRowContext(ctx,
+		`UPDATE shipments
+		 SET status = 'shipped',
+		     carrier = CASE WHEN ? = '' THEN carrier ELSE ? END,
+		     tracking_number = CASE WHEN ? = '' THEN tracking_number ELSE ? END,
+		     shipped_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = ? AND status = 'packed'
+		 RETURNING `+shipmentColumns,
+		carrier, carrier, trackingNumber, trackingNumber, id,
+	))
+	if err == sql.ErrNoRows {
+		return shipmentStatusError(ctx, tx, id, "only packed shipments can be shipped")
+	}
+	if err != nil {
+		return err
+	}
+	return r.shipmentChangedTx(ctx, tx, "shipment.shipped", s)
+}
+
+// DeliverShipment records a shipped shipment as delivered, updates its
+// order's status and queues a shipment.delivered event.
+func (r *Repo) DeliverShipment(ctx context.Context, id int64) (*models.Shipment, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	s, err := scanShipment(tx.QueryRowContext(ctx,
+		`UPDATE shipments
+		 SET status = 'delivered', delivered_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = ? AND status = 'shipped'
+		 RETURNING `+shipmentColumns,
+		id,
+	))
+	if err == sql.ErrNoRows {
+		return nil, shipmentStatusError(ctx, tx, id, "only shipped shipments can be delivered")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := r.shipmentChangedTx(ctx, tx, "shipment.delivered", s); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.GetShipment(ctx, id)
+}
+
+// DeleteShipment deletes a packed shipment, so its units can be shipped
+// again, and queues a shipment.deleted event.
+func (r *Repo) DeleteShipment(ctx context.Context, id int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	s, err := scanShipment(tx.QueryRowContext(ctx,
+		`DELETE FROM shipments WHERE id = ? AND status = 'packed' RETURNING `+shipmentColumns, id))
+	if err == sql.ErrNoRows {
+		return shipmentStatusError(ctx, tx, id, "only packed shipments can be deleted")
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM shipment_items WHERE shipment_id = ?`, id); err != nil {
+		return err
+	}
+	var userID int64
+	if err := tx.QueryRowContext(ctx, `SELECT user_id FROM orders WHERE id = ?`, s.OrderID).Scan(&userID); err != nil {
+		return err
+	}
+	err = r.enqueueEventTx(ctx, tx, "shipment.deleted", map[string]any{
+		"shipment_id": s.ID,
+		"order_id":    s.OrderID,
+		"user_id":     userID,
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// shipmentChangedTx updates the status of the order of s, which was just
+// shipped or delivered inside tx, and queues the event.
+func (r *Repo) shipmentChangedTx(ctx context.Context, tx *sql.Tx, event string, s *models.Shipment) error {
+	userID, status, err := syncOrderShipmentStatusTx(ctx, tx, s.OrderID)
+	if err != nil {
+		return err
+	}
+	return r.enqueueEventTx(ctx, tx, event, map[string]any{
+		"shipment_id":     s.ID,
+		"order_id":        s.OrderID,
+		"user_id":         userID,
+		"carrier":         s.Carrier,
+		"tracking_number": s.TrackingNumber,
+		"order_status":    status,
+	})
+}
+
+// syncOrderShipmentStatusTx derives an order's status from its shipments
+// and stores it: delivered once every unit was delivered, shipped once
+// every unit was shipped, partially_shipped once any was, and paid
+// otherwise. An order without units, such as one shipped before shipments
+// existed that had no items, keeps its status. It returns the order's user
+// and status.
+func syncOrderShipmentStatusTx(ctx context.Context, tx *sql.Tx, orderID int64) (int64, string, error) {
+	var userID int64
+	var status string
+	err := tx.QueryRowContext(ctx, `SELECT user_id, status FROM orders WHERE id = ?`, orderID).Scan(&userID, &status)
+	if err != nil {
+		return 0, "", err
+	}
+	var units, shipped, delivered int64
+	err = tx.QueryRowContext(ctx,
+		`SELECT COALESCE(SUM(quantity), 0) FROM order_items WHERE order_id = ?`, orderID,
+	).Scan(&units)
+	if err != nil {
+		return 0, "", err
+	}
+	if units == 0 {
+		return userID, status, nil
+	}
+	err = tx.QueryRowContext(ctx,
+		`SELECT COALESCE(SUM(CASE WHEN s.status IN ('shipped', 'delivered') THEN si.quantity END), 0),
+		        COALESCE(SUM(CASE WHEN s.status = 'delivered' THEN si.quantity END), 0)
+		 FROM shipment_items si JOIN shipments s ON s.id = si.shipment_id
+		 WHERE s.order_id = ?`,
+		orderID,
+	).Scan(&shipped, &delivered)
+	if err != nil {
+		return 0, "", err
+	}
+
+	switch {
+	case delivered == units:
+		status = "delivered"
+	case shipped == units:
+		status = "shipped"
+	case shipped > 0:
+		status = "partially_shipped"
+	default:
+		status = "paid"
+	}
+	_, err = tx.ExecContext(ctx,
+		`UPDATE orders SET status = ?, version = version + 1 WHERE id = ? AND status != ?`,
+		status, orderID, status,
+	)
+	return userID, status, err
+}
+
+// shipmentStatusError explains a status change guarded on the shipment's
+// current status that touched no row.
+func shipmentStatusError(ctx context.Context, q queryRower, id int64, msg string) error {
+	var status string
+	err := q.QueryRowContext(ctx, `SELECT status FROM shipments WHERE id = ?`, id).Scan(&status)
+	if err == sql.ErrNoRows {
+		return domain.ErrShipmentNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: %s, shipment is %s", domain.ErrInvalidShipmentStatus, msg, status)
+}
+
+func getShipmentItems(ctx context.Context, q querier, shipmentID int64) ([]*models.ShipmentItem, error) {
+	rows, err := q.QueryContext(ctx,
+		`SELECT id, shipment_id, order_item_id, quantity FROM shipment_items WHERE shipment_id = ? ORDER BY id`, shipmentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []*models.ShipmentItem{}
+	for rows.Next() {
+		var item models.ShipmentItem
+		if err := rows.Scan(&item.ID, &item.ShipmentID, &item.OrderItemID, &item.Quantity); err != nil {
+			return nil, err
+		}
+		items = append(items, &item)
+	}
+	return items, rows.Err()
+}
+
+func scanShipment(s scanner) (*models.Shipment, error) {
+	var sh models.Shipment
+	var shippedAt, deliveredAt sql.NullTime
+	if err := s.Scan(
+		&sh.ID, &sh.OrderID, &sh.Status, &sh.Carrier, &sh.TrackingNumber,
+		&sh.CreatedAt, &sh.UpdatedAt, &shippedAt, &deliveredAt,
+	); err != nil {
+		return nil, err
+	}
+	if shippedAt.Valid {
+		sh.ShippedAt = &shippedAt.Time
+	}
+	if deliveredAt.Valid {
+		sh.DeliveredAt = &deliveredAt.Time
+	}
+	return &sh, nil
+}
@@ -0,0 +1,200 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hitanshu0729/order_go/internal/domain"
+	"github.com/hitanshu0729/order_go/internal/models"
+)
+
+// orderLine returns the only line of an order.
+func orderLine(t *testing.T, r *Repo, orderID int64) *models.OrderItem {
+	t.Helper()
+	items, err := r.GetOrderItems(context.Background(), orderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 {
+		t.Fatalf("order %d has %d lines, want 1", orderID, len(items))
+	}
+	return items[0]
+}
+
+func TestShipmentLifecycle(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+	o, _ := newPaidOrder(t, r, 3)
+	line := orderLine(t, r, o.ID)
+
+	first := &models.Shipment{OrderID: o.ID, Items: []*models.ShipmentItem{{OrderItemID: line.ID, Quantity: 2}}}
+	if err := r.CreateShipment(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	if first.Status != models.ShipmentStatusPacked || len(first.Items) != 1 {
+		t.Fatalf("shipment = %+v, want one packed line", first)
+	}
+	if status := getTestOrder(t, r, o.ID).Status; status != "paid" {
+		t.Fatalf("order with a packed shipment is %q, want paid", status)
+	}
+	if _, err := r.DeliverShipment(ctx, first.ID); !errors.Is(err, domain.ErrInvalidShipmentStatus) {
+		t.Errorf("deliver packed shipment: err = %v, want %v", err, domain.ErrInvalidShipmentStatus)
+	}
+
+	steps := []struct {
+		name string
+		do   func() error
+		want string
+	}{
+		{"ship part", func() error {
+			_, err := r.ShipShipment(ctx, first.ID, "ups", "1Z1")
+			return err
+		}, "partially_shipped"},
+		{"ship the rest", func() error {
+			_, err := r.ShipOrder(ctx, o.ID, 0, "ups", "1Z2")
+			return err
+		}, "shipped"},
+		{"deliver part", func() error {
+			_, err := r.DeliverShipment(ctx, first.ID)
+			return err
+		}, "shipped"},
+		{"deliver the rest", func() error {
+			shipments, err := r.ListOrderShipments(ctx, o.ID)
+			if err != nil {
+				return err
+			}
+			_, err = r.DeliverShipment(ctx, shipments[1].ID)
+			return err
+		}, "delivered"},
+	}
+	for _, step := range steps {
+		if err := step.do(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if status := getTestOrder(t, r, o.ID).Status; status != step.want {
+			t.Fatalf("%s: order is %q, want %q", step.name, status, step.want)
+		}
+	}
+	if n := countEvents(t, r, "shipment.delivered"); n != 2 {
+		t.Errorf("%d shipment.delivered events, want 2", n)
+	}
+}
+
+func TestCreateShipmentRejectsOverShipping(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+	o, _ := newPaidOrder(t, r, 2)
+	line := orderLine(t, r, o.ID)
+	other, _ := newPaidOrder(t, r, 1)
+	otherLine := orderLine(t, r, other.ID)
+	ship := func(items ...*models.ShipmentItem) error {
+		return r.CreateShipment(ctx, &models.Shipment{OrderID: o.ID, Items: items})
+	}
+
+	if err := ship(&models.ShipmentItem{OrderItemID: line.ID, Quantity: 3}); !errors.Is(err, domain.ErrInvalidShipment) {
+		t.Errorf("ship 3 of 2 units: err = %v, want %v", err, domain.ErrInvalidShipment)
+	}
+	if err := ship(&models.ShipmentItem{OrderItemID: otherLine.ID, Quantity: 1}); !errors.Is(err, domain.ErrInvalidShipment) {
+		t.Errorf("ship another order's line: err = %v, want %v", err, domain.ErrInvalidShipment)
+	}
+	if err := ship(&models.ShipmentItem{OrderItemID: line.ID, Quantity: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ship(&models.ShipmentItem{OrderItemID: line.ID, Quantity: 1}); !errors.Is(err, domain.ErrInvalidShipment) {
+		t.Errorf("ship a packed unit again: err = %v, want %v", err, domain.ErrInvalidShipment)
+	}
+	if err := ship(); !errors.Is(err, domain.ErrInvalidShipment) {
+		t.Errorf("ship the rest of a packed order: err = %v, want %v", err, domain.ErrInvalidShipment)
+	}
+
+	pending := createTestOrder(t, r)
+	err := r.CreateShipment(ctx, &models.Shipment{OrderID: pending.ID})
+	if !errors.Is(err, domain.ErrInvalidOrderStatus) {
+		t.Errorf("ship pending order: err = %v, want %v", err, domain.ErrInvalidOrderStatus)
+	}
+}
+
+func TestDeletePackedShipment(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+	o, _ := newPaidOrder(t, r, 2)
+
+	packed := &models.Shipment{OrderID: o.ID}
+	if err := r.CreateShipment(ctx, packed); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.CancelOrder(ctx, o.ID, 0, ""); !errors.Is(err, domain.ErrInvalidOrderStatus) {
+		t.Errorf("cancel with a packed shipment: err = %v, want %v", err, domain.ErrInvalidOrderStatus)
+	}
+	if err := r.DeleteShipment(ctx, packed.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.GetShipment(ctx, packed.ID); !errors.Is(err, domain.ErrShipmentNotFound) {
+		t.Errorf("get deleted shipment: err = %v, want %v", err, domain.ErrShipmentNotFound)
+	}
+
+	shipped, err := r.ShipOrder(ctx, o.ID, 0, "ups", "1Z")
+	if err != nil {
+		t.Fatalf("ship the units of the deleted shipment: %v", err)
+	}
+	if err := r.DeleteShipment(ctx, shipped.ID); !errors.Is(err, domain.ErrInvalidShipmentStatus) {
+		t.Errorf("delete shipped shipment: err = %v, want %v", err, domain.ErrInvalidShipmentStatus)
+	}
+	if status := getTestOrder(t, r, o.ID).Status; status != "shipped" {
+		t.Errorf("order is %q, want shipped", status)
+	}
+}
+
+func TestShipmentsBackfillCompletedOrders(t *testing.T) {
+	var withItems, withoutItems int64
+	r := newSeededTestRepo(t, func(db *sql.DB, migration string) {
+		if !strings.HasPrefix(migration, "0023_") {
+			return
+		}
+		// Orders completed before shipments existed, one of them empty.
+		exec := func(query string, args ...any) int64 {
+			t.Helper()
+			res, err := db.Exec(query, args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			id, err := res.LastInsertId()
+			if err != nil {
+				t.Fatal(err)
+			}
+			return id
+		}
+		user := exec(`INSERT INTO users (name, email) VALUES ('user', 'backfill@example.com')`)
+		product := exec(`INSERT INTO products (sku, name, price, stock) VALUES ('P-1', 'product', 100, 10)`)
+		withItems = exec(`INSERT INTO orders (user_id, status, total_amount) VALUES (?, 'completed', 200)`, user)
+		exec(`INSERT INTO order_items (order_id, product_id, quantity, price) VALUES (?, ?, 2, 100)`, withItems, product)
+		withoutItems = exec(`INSERT INTO orders (user_id, status, total_amount) VALUES (?, 'completed', 0)`, user)
+	})
+	ctx := context.Background()
+
+	for _, id := range []int64{withItems, withoutItems} {
+		if status := getTestOrder(t, r, id).Status; status != "shipped" {
+			t.Fatalf("order %d is %q, want shipped", id, status)
+		}
+		shipments, err := r.ListOrderShipments(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(shipments) != 1 || shipments[0].Status != models.ShipmentStatusShipped {
+			t.Fatalf("order %d shipments = %+v, want one shipped", id, shipments)
+		}
+		if _, err := r.DeliverShipment(ctx, shipments[0].ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if status := getTestOrder(t, r, withItems).Status; status != "delivered" {
+		t.Errorf("order with items is %q, want delivered", status)
+	}
+	// Without units there is nothing to derive a status from.
+	if status := getTestOrder(t, r, withoutItems).Status; status != "shipped" {
+		t.Errorf("order without items is %q, want shipped", status)
+	}
+}
@@ -0,0 +1,115 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+)
+
+// scanner is satisfied by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// softDelete marks a live row as deleted, returning notFound when there is
+// no live row with that id. A non-zero version must match the row's. table
+// is always a package constant, never user input.
+func (r *Repo) softDelete(ctx context.Context, table string, id, version int64, notFound error) error {
+	res, err := r.db.ExecContext(
+		ctx,
+		`UPDATE `+table+` SET deleted_at = CURRENT_TIMESTAMP, version = version + 1
+		 WHERE id = ? AND deleted_at IS NULL AND `+versionMatch,
+		id, version, version,
+	)
+	if err := expectRow(res, err, notFound); err != notFound {
+		return err
+	}
+	return checkVersion(ctx, r.db, table, id, version, notFound)
+}
+
+// restore clears the deleted marker on a soft-deleted row, returning
+// notFound when there is no deleted row with that id. A non-zero version
+// must match the row's.
+func (r *Repo) restore(ctx context.Context, table string, id, version int64, notFound error) error {
+	res, err := r.db.ExecContext(
+		ctx,
+		`UPDATE `+table+` SET deleted_at = NULL, version = version + 1
+		 WHERE id = ? AND deleted_at IS NOT NULL AND `+versionMatch,
+		id, version, version,
+	)
+	if err := expectRow(res, err, notFound); err != notFound {
+		return err
+	}
+	return checkVersion(ctx, r.db, table, id, version, notFound)
+}
+
+// PurgeResult counts rows hard-deleted by PurgeDeleted.
+type PurgeResult struct {
+	Orders   int64 `json:"orders"`
+	Products int64 `json:"products"`
+	Users    int64 `json:"users"`
+}
+
+// PurgeDeleted permanently removes rows soft-deleted before cutoff.
+//
+// Orders go first (their items, returns, payments and shipments cascade),
+// except those with a captured or refunded payment, which are kept as the
+// record of the money taken and given back. Products are only purged once no order line or coupon references them,
+// together with their ledger entries; a coupon keeps its product so it never
+// widens to the whole order. Users are only purged once they have no orders
+// or product imports left, so purging a user can never cascade into order
+// history; jobs they queued are kept with no creator.
+func (r *Repo) PurgeDeleted(ctx context.Context, cutoff time.Time) (PurgeResult, error) {
+	var result PurgeResult
+	before := cutoff.UTC().Format("2006-01-02 15:04:05")
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return result, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx,
+		`DELETE FROM orders WHERE deleted_at IS NOT NULL AND deleted_at < ?
+		 AND NOT EXISTS (SELECT 1 FROM payments p
+			WHERE p.order_id = orders.id AND p.status IN ('captured', 'refunded'))`,
+		before,
+	)
+	if err != nil {
+		return result, err
+	}
+	result.Orders, _ = res.RowsAffected()
+
+	purgeableProducts := `SELECT id FROM products
+		WHERE deleted_at IS NOT NULL AND deleted_at < ?
+		AND NOT EXISTS (SELECT 1 FROM order_items oi WHERE oi.product_id = products.id)
+		AND NOT EXISTS (SELECT 1 FROM coupons c WHERE c.product_id = products.id)`
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM inventory_movements WHERE product_id IN (`+purgeableProducts+`)`,
+		before,
+	); err != nil {
+		return result, err
+	}
+	res, err = tx.ExecContext(ctx, `DELETE FROM products WHERE id IN (`+purgeableProducts+`)`, before)
+	if err != nil {
+		return result, err
+	}
+	result.Products, _ = res.RowsAffected()
+
+	purgeableUsers := `SELECT id FROM users
+		WHERE deleted_at IS NOT NULL AND deleted_at < ?
+		AND NOT EXISTS (SELECT 1 FROM orders o WHERE o.user_id = users.id)
+		AND NOT EXISTS (SELECT 1 FROM product_imports pi WHERE pi.created_by = users.id)`
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE jobs SET created_by = NULL WHERE created_by IN (`+purgeableUsers+`)`,
+		before,
+	); err != nil {
+		return result, err
+	}
+	res, err = tx.ExecContext(ctx, `DELETE FROM users WHERE id IN (`+purgeableUsers+`)`, before)
+	if err != nil {
+		return result, err
+	}
+	result.Users, _ = res.RowsAffected()
+
+	return result, tx.Commit()
+}
@@ -0,0 +1,138 @@
+package sqlite
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hitanshu0729/order_go/internal/models"
+)
+
+func TestPurgeDeletedKeepsReferencedRows(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+	exec := func(query string, args ...any) {
+		t.Helper()
+		if _, err := r.db.Exec(query, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A product scoped by a coupon and one that is not referenced at all.
+	scoped := createTestProduct(t, r, 100, 1)
+	free := createTestProduct(t, r, 100, 1)
+	coupon := &models.Coupon{Code: "SCOPED", Kind: models.CouponKindPercentage, PercentOff: 10, ProductID: &scoped.ID, Active: true}
+	if err := r.CreateCoupon(ctx, coupon); err != nil {
+		t.Fatal(err)
+	}
+
+	// A user who ran a product import and one who only queued a job.
+	for _, email := range []string{"importer@example.com", "queuer@example.com"} {
+		if err := r.CreateUser(ctx, "user", email, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	importer, err := r.GetUserByEmail(ctx, "importer@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	queuer, err := r.GetUserByEmail(ctx, "queuer@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exec(`INSERT INTO product_imports (format, created_by) VALUES ('csv', ?)`, importer.ID)
+	exec(`INSERT INTO jobs (type, created_by) VALUES ('noop', ?)`, queuer.ID)
+
+	for _, id := range []int64{scoped.ID, free.ID} {
+		if err := r.DeleteProduct(ctx, id, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, id := range []uint{importer.ID, queuer.ID} {
+		if err := r.DeleteUser(ctx, int64(id), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := r.PurgeDeleted(ctx, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Products != 1 || result.Users != 1 {
+		t.Errorf("purged %+v, want 1 product and 1 user", result)
+	}
+
+	if _, err := r.GetProductByIDUnscoped(ctx, scoped.ID); err != nil {
+		t.Errorf("product referenced by a coupon was purged: %v", err)
+	}
+	if _, err := r.GetProductByIDUnscoped(ctx, free.ID); err == nil {
+		t.Error("unreferenced product was not purged")
+	}
+	if _, err := r.GetUserByIDUnscoped(ctx, int64(importer.ID)); err != nil {
+		t.Errorf("user with a product import was purged: %v", err)
+	}
+	if _, err := r.GetUserByIDUnscoped(ctx, int64(queuer.ID)); err == nil {
+		t.Error("user with only a job was not purged")
+	}
+
+	var createdBy *int64
+	if err := r.db.QueryRow(`SELECT created_by FROM jobs`).Scan(&createdBy); err != nil {
+		t.Fatal(err)
+	}
+	if createdBy != nil {
+		t.Errorf("job created_by = %d, want NULL", *createdBy)
+	}
+}
+
+func TestPurgeDeletedKeepsPaidOrders(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+	p := createTestProduct(t, r, 100, 10)
+
+	// An order paid and refunded, and one whose only payment failed.
+	paid := createTestOrder(t, r)
+	failed := createTestOrder(t, r)
+	for _, o := range []*models.Order{paid, failed} {
+		if err := addTestItem(ctx, r, o.ID, p.ID, 0); err != nil {
+			t.Fatal(err)
+		}
+		payment := &models.Payment{OrderID: o.ID, Provider: "fake"}
+		if err := r.CreatePayment(ctx, payment, 0); err != nil {
+			t.Fatal(err)
+		}
+		if o == failed {
+			if _, err := r.UpdatePayment(ctx, payment.ID, models.PaymentStatusPending, models.PaymentStatusFailed, "", "declined"); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if _, err := r.CapturePayment(ctx, payment.ID, models.PaymentStatusPending); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := r.RefundPayment(ctx, payment.ID, payment.Amount, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, o := range []*models.Order{paid, failed} {
+		if err := r.DeleteOrder(ctx, o.ID, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := r.PurgeDeleted(ctx, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Orders != 1 {
+		t.Errorf("purged %d orders, want 1", result.Orders)
+	}
+	if _, err := r.GetOrderByIDUnscoped(ctx, paid.ID); err != nil {
+		t.Errorf("order with a refunded payment was purged: %v", err)
+	}
+	if payments, err := r.ListOrderPayments(ctx, paid.ID); err != nil || len(payments) != 1 {
+		t.Errorf("payments of the kept order = %v, %v; want 1", payments, err)
+	}
+	if _, err := r.GetOrderByIDUnscoped(ctx, failed.ID); err == nil {
+		t.Error("order with only a failed payment was not purged")
+	}
+}
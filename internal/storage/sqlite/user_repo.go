@@ -3,31 +3,48 @@ package sqlite
 import (
 	"context"
 	"database/sql"
-	"log"
+	"log/slog"
 
+	"github.com/hitanshu0729/order_go/internal/domain"
 	"github.com/hitanshu0729/order_go/internal/models"
+	"github.com/hitanshu0729/order_go/internal/pricing"
 )
 
 type Repo struct {
-	db *sql.DB
+	db      *sql.DB
+	pricing *pricing.Engine
 }
 
-func NewRepo(db *sql.DB) *Repo {
-	return &Repo{db: db}
+func NewRepo(db *sql.DB, pricing *pricing.Engine) *Repo {
+	return &Repo{db: db, pricing: pricing}
 }
 
-func (r *Repo) CreateUser(ctx context.Context, name, email string) error {
+const userColumns = `id, name, email, role, version, deleted_at, password_hash, credentials_changed_at`
+
+// CreateUser inserts a user. An empty passwordHash means the user cannot
+// log in with a password.
+func (r *Repo) CreateUser(ctx context.Context, name, email, passwordHash string) error {
 	_, err := r.db.ExecContext(
 		ctx,
-		`INSERT INTO users (name, email) VALUES (?, ?)`,
+		`INSERT INTO users (name, email, password_hash) VALUES (?, ?, ?)`,
 		name,
 		email,
+		sql.NullString{String: passwordHash, Valid: passwordHash != ""},
 	)
+	if IsUniqueConstraintError(err) {
+		return domain.ErrDuplicateEmail
+	}
 	return err
 }
 
-func (r *Repo) GetUsers(ctx context.Context) ([]*models.User, error) {
-	rows, err := r.db.QueryContext(ctx, `SELECT id, name, email FROM users`)
+// GetUsers returns all users. Soft-deleted users are only included when
+// includeDeleted is set.
+func (r *Repo) GetUsers(ctx context.Context, includeDeleted bool) ([]*models.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users`
+	if !includeDeleted {
+		query += ` WHERE deleted_at IS NULL`
+	}
+	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -35,49 +52,149 @@ func (r *Repo) GetUsers(ctx context.Context) ([]*models.User, error) {
 
 	var users []*models.User
 	for rows.Next() {
-		var user models.User
-		if err := rows.Scan(&user.ID, &user.Name, &user.Email); err != nil {
-			log.Println("Error scanning user:", err)
+		user, err := scanUser(rows)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to scan user", "error", err)
 			return nil, err
 		}
-		users = append(users, &user)
+		users = append(users, user)
 	}
 	return users, nil
 }
 
+// GetUserByID returns a live (not soft-deleted) user.
 func (r *Repo) GetUserByID(ctx context.Context, id int64) (*models.User, error) {
-	row := r.db.QueryRowContext(ctx, `SELECT id, name, email FROM users WHERE id = ?`, id)
-	var user models.User
-	if err := row.Scan(&user.ID, &user.Name, &user.Email); err != nil {
+	return r.getUser(ctx, `SELECT `+userColumns+` FROM users WHERE id = ? AND deleted_at IS NULL`, id)
+}
+
+// GetUserByEmail returns a live user by email, including its password hash.
+func (r *Repo) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	user, err := scanUser(r.db.QueryRowContext(
+		ctx,
+		`SELECT `+userColumns+` FROM users WHERE email = ? AND deleted_at IS NULL`,
+		email,
+	))
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrUserNotFound
+	}
+	return user, err
+}
+
+// SetUserRole changes a live user's role. A non-zero version must match
+// the user's.
+func (r *Repo) SetUserRole(ctx context.Context, id int64, role string, version int64) error {
+	res, err := r.db.ExecContext(
+		ctx,
+		`UPDATE users SET role = ?, version = version + 1
+		 WHERE id = ? AND deleted_at IS NULL AND `+versionMatch,
+		role,
+		id,
+		version, version,
+	)
+	if err := expectRow(res, err, domain.ErrUserNotFound); err != domain.ErrUserNotFound {
+		return err
+	}
+	return checkVersion(ctx, r.db, "users", id, version, domain.ErrUserNotFound)
+}
+
+// GetUserByIDUnscoped returns a user regardless of soft-delete state.
+func (r *Repo) GetUserByIDUnscoped(ctx context.Context, id int64) (*models.User, error) {
+	return r.getUser(ctx, `SELECT `+userColumns+` FROM users WHERE id = ?`, id)
+}
+
+func (r *Repo) getUser(ctx context.Context, query string, id int64) (*models.User, error) {
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
 		if err == sql.ErrNoRows {
-			log.Println("User not found with ID:", id)
-			return nil, nil // User not found
+			slog.DebugContext(ctx, "user not found", "user_id", id)
+			return nil, domain.ErrUserNotFound
 		}
 		return nil, err
 	}
-	return &user, nil
+	return user, nil
 }
 
-func (r *Repo) DeleteUser(ctx context.Context, id int64) error {
-	_, err := r.db.ExecContext(ctx, `DELETE FROM users WHERE id = ?`, id)
-	return err
+// DeleteUser soft-deletes a user. Their orders are left untouched so that
+// financial history is preserved.
+func (r *Repo) DeleteUser(ctx context.Context, id, version int64) error {
+	return r.softDelete(ctx, "users", id, version, domain.ErrUserNotFound)
 }
 
-func (r *Repo) UpdateUser(ctx context.Context, id int64, name, email string) error {
-	user, err := r.db.ExecContext(
+// RestoreUser clears the soft-delete marker on a user.
+func (r *Repo) RestoreUser(ctx context.Context, id, version int64) error {
+	return r.restore(ctx, "users", id, version, domain.ErrUserNotFound)
+}
+
+// UpdateUser changes a live user's name and email and, when passwordHash
+// is not empty, their password. A non-zero version must match the user's.
+// Changing the password revokes the user's API keys and invalidates tokens
+// issued before it, in the same transaction.
+func (r *Repo) UpdateUser(ctx context.Context, id int64, name, email, passwordHash string, version int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	user, err := tx.ExecContext(
 		ctx,
-		`UPDATE users SET name = ?, email = ? WHERE id = ?`,
+		`UPDATE users SET name = ?, email = ?, version = version + 1
+		 WHERE id = ? AND deleted_at IS NULL AND `+versionMatch,
 		name,
 		email,
 		id,
+		version, version,
 	)
 	if err != nil {
+		if IsUniqueConstraintError(err) {
+			return domain.ErrDuplicateEmail
+		}
 		return err
 	}
 	rowsAffected, err := user.RowsAffected()
 	if err != nil {
 		return err
 	}
-	log.Printf("Succesfully Update user with id : %d , num rows affected: %d", id, rowsAffected)
+	if rowsAffected == 0 {
+		return checkVersion(ctx, tx, "users", id, version, domain.ErrUserNotFound)
+	}
+
+	if passwordHash != "" {
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE users SET password_hash = ?, credentials_changed_at = CURRENT_TIMESTAMP WHERE id = ?`,
+			passwordHash, id,
+		); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = ? AND revoked_at IS NULL`, id,
+		); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "user updated", "user_id", id, "password_changed", passwordHash != "")
 	return nil
 }
+
+func scanUser(s scanner) (*models.User, error) {
+	var user models.User
+	var deletedAt sql.NullTime
+	var passwordHash sql.NullString
+	var credentialsChangedAt sql.NullTime
+	if err := s.Scan(
+		&user.ID, &user.Name, &user.Email, &user.Role, &user.Version, &deletedAt, &passwordHash, &credentialsChangedAt,
+	); err != nil {
+		return nil, err
+	}
+	user.PasswordHash = passwordHash.String
+	if deletedAt.Valid {
+		user.DeletedAt = &deletedAt.Time
+	}
+	if credentialsChangedAt.Valid {
+		user.CredentialsChangedAt = &credentialsChangedAt.Time
+	}
+	return &user, nil
+}
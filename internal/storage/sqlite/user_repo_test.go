@@ -0,0 +1,53 @@
+package sqlite
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hitanshu0729/order_go/internal/models"
+)
+
+func TestUpdateUserPasswordRevokesCredentials(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+	if err := r.CreateUser(ctx, "user", "user@example.com", "old-hash"); err != nil {
+		t.Fatal(err)
+	}
+	u, err := r.GetUserByEmail(ctx, "user@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := &models.APIKey{UserID: int64(u.ID), Name: "ci", Prefix: "ok_test", KeyHash: "hash"}
+	if err := r.CreateAPIKey(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+
+	// A profile change leaves the password and credentials alone.
+	if err := r.UpdateUser(ctx, int64(u.ID), "renamed", u.Email, "", u.Version); err != nil {
+		t.Fatal(err)
+	}
+	u, err = r.GetUserByEmail(ctx, u.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.PasswordHash != "old-hash" || u.CredentialsChangedAt != nil {
+		t.Fatalf("profile change touched credentials: hash %q changed at %v", u.PasswordHash, u.CredentialsChangedAt)
+	}
+	if k, err := r.GetAPIKeyByHash(ctx, "hash"); err != nil || k.RevokedAt != nil {
+		t.Fatalf("api key after profile change = %+v, %v; want live", k, err)
+	}
+
+	if err := r.UpdateUser(ctx, int64(u.ID), u.Name, u.Email, "new-hash", u.Version); err != nil {
+		t.Fatal(err)
+	}
+	u, err = r.GetUserByEmail(ctx, u.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.PasswordHash != "new-hash" || u.CredentialsChangedAt == nil {
+		t.Errorf("after password change: hash %q changed at %v", u.PasswordHash, u.CredentialsChangedAt)
+	}
+	if k, err := r.GetAPIKeyByHash(ctx, "hash"); err != nil || k.RevokedAt == nil {
+		t.Errorf("api key after password change = %+v, %v; want revoked", k, err)
+	}
+}
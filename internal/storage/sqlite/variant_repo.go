@@ -0,0 +1,174 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hitanshu0729/order_go/internal/domain"
+	"github.com/hitanshu0729/order_go/internal/models"
+)
+
+const variantColumns = `id, product_id, sku, attributes, price, stock, deleted_at`
+
+// CreateProductVariant adds a variant to a live product and records its
+// initial stock in the ledger. v gets its id.
+func (r *Repo) CreateProductVariant(ctx context.Context, v *models.ProductVariant) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx,
+		`UPDATE products SET version = version + 1 WHERE id = ? AND deleted_at IS NULL`, v.ProductID)
+	if err := expectRow(res, err, domain.ErrProductNotFound); err != nil {
+		return err
+	}
+	if err := r.insertVariant(ctx, tx, v); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *Repo) insertVariant(ctx context.Context, tx *sql.Tx, v *models.ProductVariant) error {
+	attrs, err := attributesJSON(v.Attributes)
+	if err != nil {
+		return err
+	}
+	if err := checkSKU(ctx, tx, "products", v.SKU); err != nil {
+		return err
+	}
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO product_variants (product_id, sku, attributes, price, stock) VALUES (?, ?, ?, ?, 0)`,
+		v.ProductID, v.SKU, attrs, v.Price,
+	)
+	if IsUniqueConstraintError(err) {
+		return fmt.Errorf("%w: %s", domain.ErrDuplicateSKU, v.SKU)
+	}
+	if err != nil {
+		return err
+	}
+	if v.ID, err = res.LastInsertId(); err != nil {
+		return err
+	}
+	if v.Stock > 0 {
+		return r.AdjustVariantStockTx(ctx, tx, v.ID, v.Stock, models.MovementReasonRestock, nil, "initial stock")
+	}
+	return nil
+}
+
+// GetProductVariant returns a live variant of a product.
+func (r *Repo) GetProductVariant(ctx context.Context, productID, variantID int64) (*models.ProductVariant, error) {
+	return getProductVariant(ctx, r.db, productID, variantID)
+}
+
+func getProductVariant(ctx context.Context, q queryRower, productID, variantID int64) (*models.ProductVariant, error) {
+	v, err := scanVariant(q.QueryRowContext(ctx,
+		`SELECT `+variantColumns+` FROM product_variants
+		 WHERE id = ? AND product_id = ? AND deleted_at IS NULL`,
+		variantID, productID,
+	))
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrVariantNotFound
+	}
+	return v, err
+}
+
+// GetLineProduct returns a live product as sold on an order line, with the
+// line's variant label. A product with variants must be ordered as one of
+// them by variantID, and the line is labelled with the variant's SKU; a
+// free-form label is only accepted for products without variants.
+func (r *Repo) GetLineProduct(ctx context.Context, productID int64, variantID *int64, label string) (*models.Product, string, error) {
+	return r.lineProduct(ctx, r.db, productID, variantID, label)
+}
+
+func (r *Repo) lineProduct(ctx context.Context, q querier, productID int64, variantID *int64, label string) (*models.Product, string, error) {
+	product, err := r.getProductFrom(ctx, q,
+		`SELECT `+productColumns+` FROM products WHERE id = ? AND deleted_at IS NULL`, productID)
+	if err != nil {
+		return nil, "", err
+	}
+	if variantID == nil {
+		if len(product.Variants) > 0 {
+			return nil, "", fmt.Errorf("%w: product %d has variants, choose one by variant_id", domain.ErrVariantRequired, productID)
+		}
+		return product, label, nil
+	}
+	v, err := getProductVariant(ctx, q, productID, *variantID)
+	if err != nil {
+		return nil, "", err
+	}
+	if label != "" && label != v.SKU {
+		return nil, "", fmt.Errorf("%w: variant %q does not match variant %d (%s)", domain.ErrVariantRequired, label, v.ID, v.SKU)
+	}
+	return product.WithVariant(v), v.SKU, nil
+}
+
+// DeleteProductVariant soft-deletes a variant. Order lines keep referring
+// to it, but it can no longer be ordered.
+func (r *Repo) DeleteProductVariant(ctx context.Context, productID, variantID int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx,
+		`UPDATE product_variants SET deleted_at = CURRENT_TIMESTAMP
+		 WHERE id = ? AND product_id = ? AND deleted_at IS NULL`,
+		variantID, productID,
+	)
+	if err := expectRow(res, err, domain.ErrVariantNotFound); err != nil {
+		return err
+	}
+	if err := bumpProductVersion(ctx, tx, productID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// getProductVariants returns live variants keyed by product id. When
+// productID is non-zero only that product's are loaded.
+func getProductVariants(ctx context.Context, q querier, productID int64) (map[int64][]*models.ProductVariant, error) {
+	query := `SELECT ` + variantColumns + ` FROM product_variants WHERE deleted_at IS NULL`
+	var args []any
+	if productID != 0 {
+		query += ` AND product_id = ?`
+		args = append(args, productID)
+	}
+	query += ` ORDER BY product_id, id`
+
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	variants := map[int64][]*models.ProductVariant{}
+	for rows.Next() {
+		v, err := scanVariant(rows)
+		if err != nil {
+			return nil, err
+		}
+		variants[v.ProductID] = append(variants[v.ProductID], v)
+	}
+	return variants, rows.Err()
+}
+
+func scanVariant(s scanner) (*models.ProductVariant, error) {
+	var v models.ProductVariant
+	var attrs string
+	var deletedAt sql.NullTime
+	if err := s.Scan(&v.ID, &v.ProductID, &v.SKU, &attrs, &v.Price, &v.Stock, &deletedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(attrs), &v.Attributes); err != nil {
+		return nil, err
+	}
+	if deletedAt.Valid {
+		v.DeletedAt = &deletedAt.Time
+	}
+	return &v, nil
+}
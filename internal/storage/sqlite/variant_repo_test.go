@@ -0,0 +1,82 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hitanshu0729/order_go/internal/domain"
+	"github.com/hitanshu0729/order_go/internal/models"
+)
+
+func TestGetLineProduct(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+	plain := createTestProduct(t, r, 100, 10)
+	sized := &models.Product{Name: "sized", Price: 100, Variants: []*models.ProductVariant{
+		{SKU: "SIZED-S", Price: 110, Stock: 5},
+	}}
+	if err := r.CreateProduct(ctx, sized); err != nil {
+		t.Fatal(err)
+	}
+	variantID := sized.Variants[0].ID
+	other := int64(-1)
+
+	tests := []struct {
+		name      string
+		productID int64
+		variantID *int64
+		label     string
+		wantPrice int64
+		wantLabel string
+		wantErr   error
+	}{
+		{"plain product", plain.ID, nil, "", 100, "", nil},
+		{"plain product with label", plain.ID, nil, "blue", 100, "blue", nil},
+		{"plain product with variant", plain.ID, &variantID, "", 0, "", domain.ErrVariantNotFound},
+		{"variant", sized.ID, &variantID, "", 110, "SIZED-S", nil},
+		{"variant with its SKU", sized.ID, &variantID, "SIZED-S", 110, "SIZED-S", nil},
+		{"variant with another label", sized.ID, &variantID, "large", 0, "", domain.ErrVariantRequired},
+		{"unknown variant", sized.ID, &other, "", 0, "", domain.ErrVariantNotFound},
+		{"no variant", sized.ID, nil, "", 0, "", domain.ErrVariantRequired},
+		{"SKU as a label", sized.ID, nil, "SIZED-S", 0, "", domain.ErrVariantRequired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			product, label, err := r.GetLineProduct(ctx, tt.productID, tt.variantID, tt.label)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if product.Price != tt.wantPrice || label != tt.wantLabel {
+				t.Errorf("got price %d label %q, want %d %q", product.Price, label, tt.wantPrice, tt.wantLabel)
+			}
+		})
+	}
+}
+
+func TestOrderLinesKeyedOnVariantID(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+	p := createTestProduct(t, r, 100, 10)
+	o := createTestOrder(t, r)
+
+	for _, label := range []string{"red", "", "blue"} {
+		item := &models.OrderItem{OrderID: o.ID, ProductID: p.ID, Quantity: 1, Price: 100, Variant: label}
+		if err := r.AddOrderItem(ctx, item, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	items, err := r.GetOrderItems(ctx, o.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Quantity != 3 || items[0].Variant != "blue" {
+		t.Fatalf("items = %+v, want one line of 3 labelled blue", items)
+	}
+}
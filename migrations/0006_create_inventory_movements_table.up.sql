@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS inventory_movements (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+
+    product_id INTEGER NOT NULL,
+
+    -- signed delta applied to products.stock
+    quantity INTEGER NOT NULL
+        CHECK (quantity != 0),
+
+    reason TEXT NOT NULL
+        CHECK (reason IN ('order', 'restock', 'adjustment', 'return')),
+
+    -- order id for 'order' and 'return' movements
+    reference_id INTEGER,
+
+    note TEXT NOT NULL DEFAULT '',
+
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (product_id) REFERENCES products(id)
+);
+CREATE INDEX idx_inventory_movements_product_id ON inventory_movements(product_id);
+
+-- opening balance so the ledger sum matches existing stock
+INSERT INTO inventory_movements (product_id, quantity, reason, note)
+SELECT id, stock, 'adjustment', 'opening balance' FROM products WHERE stock > 0;
@@ -0,0 +1,7 @@
+ALTER TABLE users ADD COLUMN deleted_at DATETIME;
+ALTER TABLE products ADD COLUMN deleted_at DATETIME;
+ALTER TABLE orders ADD COLUMN deleted_at DATETIME;
+
+CREATE INDEX idx_users_deleted_at ON users(deleted_at);
+CREATE INDEX idx_products_deleted_at ON products(deleted_at);
+CREATE INDEX idx_orders_deleted_at ON orders(deleted_at);
@@ -0,0 +1,4 @@
+ALTER TABLE orders DROP COLUMN exchange_rate;
+ALTER TABLE orders DROP COLUMN currency;
+
+DROP TABLE IF EXISTS product_prices;
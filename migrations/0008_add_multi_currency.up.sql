@@ -0,0 +1,14 @@
+-- explicit per-currency prices; products.price stays in the base currency
+CREATE TABLE IF NOT EXISTS product_prices (
+    product_id INTEGER NOT NULL,
+    currency TEXT NOT NULL,
+    amount INTEGER NOT NULL
+        CHECK (amount > 0),   -- smallest unit of currency
+    PRIMARY KEY (product_id, currency),
+    FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+);
+
+-- existing orders predate multi-currency and are in the base currency
+ALTER TABLE orders ADD COLUMN currency TEXT NOT NULL DEFAULT 'INR';
+-- base → order currency rate at creation time, as a decimal string
+ALTER TABLE orders ADD COLUMN exchange_rate TEXT NOT NULL DEFAULT '1';
@@ -0,0 +1,6 @@
+ALTER TABLE orders DROP COLUMN pricing;
+ALTER TABLE orders DROP COLUMN tax_jurisdiction;
+ALTER TABLE orders DROP COLUMN shipping_amount;
+ALTER TABLE orders DROP COLUMN tax_amount;
+ALTER TABLE orders DROP COLUMN discount_amount;
+ALTER TABLE orders DROP COLUMN subtotal_amount;
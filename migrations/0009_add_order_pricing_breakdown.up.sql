@@ -0,0 +1,11 @@
+-- all amounts in the order currency's smallest unit; total_amount is the grand total
+ALTER TABLE orders ADD COLUMN subtotal_amount INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN discount_amount INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN tax_amount INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN shipping_amount INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN tax_jurisdiction TEXT NOT NULL DEFAULT '';
+-- full pricing breakdown as JSON
+ALTER TABLE orders ADD COLUMN pricing TEXT;
+
+-- existing totals were plain item sums
+UPDATE orders SET subtotal_amount = total_amount;
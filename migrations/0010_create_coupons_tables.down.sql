@@ -0,0 +1,4 @@
+ALTER TABLE orders DROP COLUMN coupon_id;
+
+DROP TABLE IF EXISTS coupon_redemptions;
+DROP TABLE IF EXISTS coupons;
@@ -0,0 +1,47 @@
+CREATE TABLE IF NOT EXISTS coupons (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+
+    code TEXT NOT NULL UNIQUE,   -- stored upper-case
+    description TEXT NOT NULL DEFAULT '',
+
+    kind TEXT NOT NULL
+        CHECK (kind IN ('percentage', 'fixed', 'buy_x_get_y')),
+
+    percent_off INTEGER NOT NULL DEFAULT 0
+        CHECK (percent_off BETWEEN 0 AND 100),
+    amount_off INTEGER NOT NULL DEFAULT 0
+        CHECK (amount_off >= 0),   -- base currency, smallest unit
+
+    -- restricts percentage/fixed coupons to one product; required for buy_x_get_y
+    product_id INTEGER,
+    buy_quantity INTEGER NOT NULL DEFAULT 0,
+    get_quantity INTEGER NOT NULL DEFAULT 0,
+
+    min_order_amount INTEGER NOT NULL DEFAULT 0,   -- base currency, smallest unit
+
+    -- 0 means unlimited
+    max_uses INTEGER NOT NULL DEFAULT 0,
+    max_uses_per_user INTEGER NOT NULL DEFAULT 0,
+    times_used INTEGER NOT NULL DEFAULT 0,
+
+    starts_at DATETIME,
+    ends_at DATETIME,
+    active INTEGER NOT NULL DEFAULT 1,
+
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (product_id) REFERENCES products(id)
+);
+
+CREATE TABLE IF NOT EXISTS coupon_redemptions (
+    coupon_id INTEGER NOT NULL,
+    order_id INTEGER NOT NULL UNIQUE,
+    user_id INTEGER NOT NULL,
+    redeemed_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (coupon_id, order_id),
+    FOREIGN KEY (coupon_id) REFERENCES coupons(id),
+    FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE
+);
+CREATE INDEX idx_coupon_redemptions_user ON coupon_redemptions(coupon_id, user_id);
+
+ALTER TABLE orders ADD COLUMN coupon_id INTEGER REFERENCES coupons(id);
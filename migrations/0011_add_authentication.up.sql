@@ -0,0 +1,22 @@
+-- bcrypt hash; NULL for users that cannot log in with a password
+ALTER TABLE users ADD COLUMN password_hash TEXT;
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+
+    user_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+
+    -- first characters of the key, shown to users to tell keys apart
+    prefix TEXT NOT NULL,
+    -- SHA-256 of the full key; the key itself is never stored
+    key_hash TEXT NOT NULL UNIQUE,
+
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_used_at DATETIME,
+    expires_at DATETIME,
+    revoked_at DATETIME,
+
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE INDEX idx_api_keys_user_id ON api_keys(user_id);
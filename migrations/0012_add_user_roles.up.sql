@@ -0,0 +1,4 @@
+-- customer: own orders only; staff: every order, shipping and status
+-- changes; admin: additionally manages users, products and coupons
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'customer'
+    CHECK (role IN ('customer', 'staff', 'admin'));
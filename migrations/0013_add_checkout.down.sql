@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS outbox;
+
+ALTER TABLE orders DROP COLUMN shipping_address;
@@ -0,0 +1,20 @@
+-- shipping address captured at checkout, as JSON; NULL for orders built item by item
+ALTER TABLE orders ADD COLUMN shipping_address TEXT;
+
+-- transactional outbox: events are written in the same transaction as the
+-- change they describe and published to Kafka by the outbox relay
+CREATE TABLE IF NOT EXISTS outbox (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+
+    event_type TEXT NOT NULL,
+    payload TEXT NOT NULL,   -- JSON
+    -- request id and trace context of the request that wrote the event
+    request_id TEXT NOT NULL DEFAULT '',
+    trace_context TEXT NOT NULL DEFAULT '{}',
+
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    published_at DATETIME,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX idx_outbox_unpublished ON outbox(id) WHERE published_at IS NULL;
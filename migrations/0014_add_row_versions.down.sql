@@ -0,0 +1,3 @@
+ALTER TABLE users DROP COLUMN version;
+ALTER TABLE products DROP COLUMN version;
+ALTER TABLE orders DROP COLUMN version;
@@ -0,0 +1,5 @@
+-- optimistic concurrency: every update increments version, and conditional
+-- updates only apply to the version the client last read (its ETag)
+ALTER TABLE orders ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE products ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE users ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_order_items_line;
+
+ALTER TABLE order_items DROP COLUMN variant;
+ALTER TABLE order_items DROP COLUMN note;
@@ -0,0 +1,18 @@
+-- per-line note and variant label, both optional
+ALTER TABLE order_items ADD COLUMN note TEXT NOT NULL DEFAULT '';
+ALTER TABLE order_items ADD COLUMN variant TEXT NOT NULL DEFAULT '';
+
+-- a product appears on an order once per variant: fold existing duplicate
+-- lines into the earliest one, which keeps its price, before enforcing it
+UPDATE order_items SET quantity = (
+    SELECT SUM(d.quantity) FROM order_items d
+    WHERE d.order_id = order_items.order_id AND d.product_id = order_items.product_id
+)
+WHERE id IN (
+    SELECT MIN(id) FROM order_items GROUP BY order_id, product_id HAVING COUNT(*) > 1
+);
+DELETE FROM order_items WHERE id NOT IN (
+    SELECT MIN(id) FROM order_items GROUP BY order_id, product_id
+);
+
+CREATE UNIQUE INDEX idx_order_items_line ON order_items(order_id, product_id, variant);
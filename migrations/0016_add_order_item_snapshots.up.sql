@@ -0,0 +1,8 @@
+-- what each line recorded about its product when it was added, as JSON, so
+-- orders stay readable after the product is renamed or purged
+ALTER TABLE order_items ADD COLUMN product_snapshot TEXT NOT NULL DEFAULT '{}';
+
+UPDATE order_items SET product_snapshot = (
+    SELECT json_object('name', p.name) FROM products p WHERE p.id = order_items.product_id
+)
+WHERE EXISTS (SELECT 1 FROM products p WHERE p.id = order_items.product_id);
@@ -0,0 +1,13 @@
+ALTER TABLE order_items DROP COLUMN variant_id;
+ALTER TABLE inventory_movements DROP COLUMN variant_id;
+
+DROP TABLE IF EXISTS product_variants;
+
+DROP INDEX IF EXISTS idx_products_category_id;
+ALTER TABLE products DROP COLUMN attributes;
+ALTER TABLE products DROP COLUMN category_id;
+ALTER TABLE products DROP COLUMN description;
+DROP INDEX IF EXISTS idx_products_sku;
+ALTER TABLE products DROP COLUMN sku;
+
+DROP TABLE IF EXISTS categories;
@@ -0,0 +1,43 @@
+-- category tree; parent_id is NULL for top-level categories
+CREATE TABLE IF NOT EXISTS categories (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    slug TEXT NOT NULL UNIQUE,
+    parent_id INTEGER,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (parent_id) REFERENCES categories(id)
+);
+CREATE INDEX idx_categories_parent_id ON categories(parent_id);
+
+-- existing products get a generated SKU so every product has a unique one
+ALTER TABLE products ADD COLUMN sku TEXT NOT NULL DEFAULT '';
+UPDATE products SET sku = 'P-' || id;
+CREATE UNIQUE INDEX idx_products_sku ON products(sku);
+
+ALTER TABLE products ADD COLUMN description TEXT NOT NULL DEFAULT '';
+ALTER TABLE products ADD COLUMN category_id INTEGER REFERENCES categories(id);
+-- free-form string attributes as a JSON object, e.g. {"brand": "Acme"}
+ALTER TABLE products ADD COLUMN attributes TEXT NOT NULL DEFAULT '{}';
+CREATE INDEX idx_products_category_id ON products(category_id);
+
+-- sellable variants of a product (size, colour, ...), each with its own
+-- SKU, base-currency price and stock
+CREATE TABLE IF NOT EXISTS product_variants (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    product_id INTEGER NOT NULL,
+    sku TEXT NOT NULL UNIQUE,
+    attributes TEXT NOT NULL DEFAULT '{}',   -- JSON, e.g. {"size": "M", "color": "red"}
+    price INTEGER NOT NULL
+        CHECK (price > 0),
+    stock INTEGER NOT NULL DEFAULT 0
+        CHECK (stock >= 0),
+    deleted_at DATETIME,
+    FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+);
+CREATE INDEX idx_product_variants_product_id ON product_variants(product_id);
+
+-- stock of a variant is kept on the variant and ledgered against it
+ALTER TABLE inventory_movements ADD COLUMN variant_id INTEGER REFERENCES product_variants(id);
+
+-- order lines for a variant; their variant label is the variant's SKU
+ALTER TABLE order_items ADD COLUMN variant_id INTEGER REFERENCES product_variants(id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_product_imports_status;
+DROP TABLE IF EXISTS product_imports;
@@ -0,0 +1,21 @@
+-- bulk product imports; the uploaded file is kept until the import has run
+-- so large files can be processed in the background
+CREATE TABLE IF NOT EXISTS product_imports (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'completed', 'failed')),
+    format TEXT NOT NULL CHECK (format IN ('csv', 'ndjson')),
+    dry_run BOOLEAN NOT NULL DEFAULT 0,
+    payload BLOB,
+    total_rows INTEGER NOT NULL DEFAULT 0,
+    created_rows INTEGER NOT NULL DEFAULT 0,
+    updated_rows INTEGER NOT NULL DEFAULT 0,
+    failed_rows INTEGER NOT NULL DEFAULT 0,
+    errors TEXT NOT NULL DEFAULT '[]',       -- JSON array of per-row errors
+    error TEXT NOT NULL DEFAULT '',          -- why the whole import failed, if it did
+    created_by INTEGER NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    started_at DATETIME,
+    finished_at DATETIME,
+    FOREIGN KEY (created_by) REFERENCES users(id)
+);
+CREATE INDEX idx_product_imports_status ON product_imports(status);
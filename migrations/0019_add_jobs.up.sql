@@ -0,0 +1,27 @@
+-- background job queue. A worker claims a job by taking a lease, renews it
+-- while the job runs, and the job is claimed again if the lease expires.
+-- Times compared by the queue are written by SQLite, as CURRENT_TIMESTAMP
+-- or datetime('now', ...), so they compare as text.
+CREATE TABLE IF NOT EXISTS jobs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    type TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'queued' CHECK (status IN ('queued', 'running', 'succeeded', 'failed', 'cancelled')),
+    payload TEXT NOT NULL DEFAULT '{}',      -- JSON
+    result TEXT,                             -- JSON, once succeeded
+    error TEXT NOT NULL DEFAULT '',          -- last failure
+    progress INTEGER NOT NULL DEFAULT 0 CHECK (progress BETWEEN 0 AND 100),
+    progress_message TEXT NOT NULL DEFAULT '',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 3 CHECK (max_attempts > 0),
+    cancel_requested BOOLEAN NOT NULL DEFAULT 0,
+    run_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    lease_owner TEXT,
+    lease_expires_at DATETIME,
+    created_by INTEGER,                      -- NULL for jobs queued by the service itself
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    started_at DATETIME,
+    finished_at DATETIME,
+    FOREIGN KEY (created_by) REFERENCES users(id)
+);
+CREATE INDEX idx_jobs_status_run_at ON jobs(status, run_at);
@@ -0,0 +1,3 @@
+-- why an order was cancelled, given by whoever cancelled it or 'expired'
+-- for unpaid orders cancelled by the expiry job
+ALTER TABLE orders ADD COLUMN cancellation_reason TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_return_items_order_item_id;
+DROP INDEX IF EXISTS idx_return_items_return_id;
+DROP TABLE IF EXISTS return_items;
+DROP INDEX IF EXISTS idx_returns_status;
+DROP INDEX IF EXISTS idx_returns_order_id;
+DROP TABLE IF EXISTS returns;
@@ -0,0 +1,37 @@
+-- returns of completed orders. A return is requested for some units of some
+-- lines, approved or rejected by staff, and restocked once the goods are
+-- received. Refund amounts are what the customer paid for those units, in
+-- the order's currency, computed when the return is requested.
+CREATE TABLE IF NOT EXISTS returns (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    order_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    status TEXT NOT NULL DEFAULT 'requested' CHECK (status IN ('requested', 'approved', 'rejected', 'received')),
+    currency TEXT NOT NULL,
+    refund_amount INTEGER NOT NULL DEFAULT 0,
+    note TEXT NOT NULL DEFAULT '',           -- why it was approved or rejected
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    decided_at DATETIME,
+    received_at DATETIME,
+    FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+CREATE INDEX idx_returns_order_id ON returns(order_id);
+CREATE INDEX idx_returns_status ON returns(status);
+
+CREATE TABLE IF NOT EXISTS return_items (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    return_id INTEGER NOT NULL,
+    order_item_id INTEGER NOT NULL,
+    product_id INTEGER NOT NULL,
+    variant_id INTEGER,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    reason TEXT NOT NULL CHECK (reason IN ('damaged', 'defective', 'wrong_item', 'not_as_described', 'changed_mind', 'other')),
+    note TEXT NOT NULL DEFAULT '',
+    refund_amount INTEGER NOT NULL DEFAULT 0,
+    FOREIGN KEY (return_id) REFERENCES returns(id) ON DELETE CASCADE,
+    FOREIGN KEY (order_item_id) REFERENCES order_items(id) ON DELETE CASCADE
+);
+CREATE INDEX idx_return_items_return_id ON return_items(return_id);
+CREATE INDEX idx_return_items_order_item_id ON return_items(order_item_id);
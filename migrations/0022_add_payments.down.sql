@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_payments_provider_reference;
+DROP INDEX IF EXISTS idx_payments_order_id;
+DROP TABLE IF EXISTS payments;
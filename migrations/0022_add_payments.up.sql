@@ -0,0 +1,20 @@
+-- payments of orders through a payment provider. reference is the
+-- provider's id for the payment, set once it has been authorized or
+-- accepted, and is how provider webhooks name it.
+CREATE TABLE IF NOT EXISTS payments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    order_id INTEGER NOT NULL,
+    provider TEXT NOT NULL,
+    reference TEXT,
+    method TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'authorized', 'captured', 'failed', 'voided', 'refunded')),
+    amount INTEGER NOT NULL CHECK (amount > 0),
+    currency TEXT NOT NULL,
+    refunded_amount INTEGER NOT NULL DEFAULT 0 CHECK (refunded_amount BETWEEN 0 AND amount),
+    error TEXT NOT NULL DEFAULT '',          -- why it failed, was voided or was refunded automatically
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE
+);
+CREATE INDEX idx_payments_order_id ON payments(order_id);
+CREATE UNIQUE INDEX idx_payments_provider_reference ON payments(provider, reference);
@@ -0,0 +1,50 @@
+PRAGMA foreign_keys = OFF;
+
+CREATE TABLE orders_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    status TEXT NOT NULL
+        CHECK (status IN ('pending', 'paid', 'cancelled', 'completed')),
+    total_amount INTEGER NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    deleted_at DATETIME,
+    currency TEXT NOT NULL DEFAULT 'INR',
+    exchange_rate TEXT NOT NULL DEFAULT '1',
+    subtotal_amount INTEGER NOT NULL DEFAULT 0,
+    discount_amount INTEGER NOT NULL DEFAULT 0,
+    tax_amount INTEGER NOT NULL DEFAULT 0,
+    shipping_amount INTEGER NOT NULL DEFAULT 0,
+    tax_jurisdiction TEXT NOT NULL DEFAULT '',
+    pricing TEXT,
+    coupon_id INTEGER REFERENCES coupons(id),
+    shipping_address TEXT,
+    version INTEGER NOT NULL DEFAULT 1,
+    cancellation_reason TEXT NOT NULL DEFAULT '',
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+-- partially shipped orders go back to paid, as they were before.
+INSERT INTO orders_old (
+    id, user_id, status, total_amount, created_at, deleted_at, currency, exchange_rate,
+    subtotal_amount, discount_amount, tax_amount, shipping_amount, tax_jurisdiction,
+    pricing, coupon_id, shipping_address, version, cancellation_reason
+)
+SELECT
+    id, user_id,
+    CASE status WHEN 'partially_shipped' THEN 'paid' WHEN 'shipped' THEN 'completed' WHEN 'delivered' THEN 'completed' ELSE status END,
+    total_amount, created_at, deleted_at, currency, exchange_rate,
+    subtotal_amount, discount_amount, tax_amount, shipping_amount, tax_jurisdiction,
+    pricing, coupon_id, shipping_address, version, cancellation_reason
+FROM orders;
+DROP TABLE orders;
+ALTER TABLE orders_old RENAME TO orders;
+CREATE INDEX idx_orders_user_id ON orders(user_id);
+CREATE INDEX idx_orders_deleted_at ON orders(deleted_at);
+
+PRAGMA foreign_keys = ON;
+
+DROP INDEX IF EXISTS idx_shipment_items_order_item_id;
+DROP INDEX IF EXISTS idx_shipment_items_shipment_id;
+DROP TABLE IF EXISTS shipment_items;
+DROP INDEX IF EXISTS idx_shipments_status;
+DROP INDEX IF EXISTS idx_shipments_order_id;
+DROP TABLE IF EXISTS shipments;
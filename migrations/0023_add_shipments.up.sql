@@ -0,0 +1,81 @@
+-- shipments of paid orders. Each ships some units of some lines with a
+-- carrier and tracking number; it is packed, then shipped, then delivered.
+CREATE TABLE IF NOT EXISTS shipments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    order_id INTEGER NOT NULL,
+    status TEXT NOT NULL DEFAULT 'packed' CHECK (status IN ('packed', 'shipped', 'delivered')),
+    carrier TEXT NOT NULL DEFAULT '',
+    tracking_number TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    shipped_at DATETIME,
+    delivered_at DATETIME,
+    FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE
+);
+CREATE INDEX idx_shipments_order_id ON shipments(order_id);
+CREATE INDEX idx_shipments_status ON shipments(status);
+
+CREATE TABLE IF NOT EXISTS shipment_items (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    shipment_id INTEGER NOT NULL,
+    order_item_id INTEGER NOT NULL,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    FOREIGN KEY (shipment_id) REFERENCES shipments(id) ON DELETE CASCADE,
+    FOREIGN KEY (order_item_id) REFERENCES order_items(id) ON DELETE CASCADE
+);
+CREATE INDEX idx_shipment_items_shipment_id ON shipment_items(shipment_id);
+CREATE INDEX idx_shipment_items_order_item_id ON shipment_items(order_item_id);
+
+-- an order's status now follows its shipments: partially_shipped, shipped
+-- and delivered replace completed. SQLite cannot alter a CHECK constraint,
+-- so the table is rebuilt. Foreign keys must be off while it is, or
+-- dropping the old table would cascade to the rows referencing it; this
+-- only works outside a transaction.
+PRAGMA foreign_keys = OFF;
+
+CREATE TABLE orders_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    status TEXT NOT NULL
+        CHECK (status IN ('pending', 'paid', 'partially_shipped', 'shipped', 'delivered', 'cancelled')),
+    total_amount INTEGER NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    deleted_at DATETIME,
+    currency TEXT NOT NULL DEFAULT 'INR',
+    exchange_rate TEXT NOT NULL DEFAULT '1',
+    subtotal_amount INTEGER NOT NULL DEFAULT 0,
+    discount_amount INTEGER NOT NULL DEFAULT 0,
+    tax_amount INTEGER NOT NULL DEFAULT 0,
+    shipping_amount INTEGER NOT NULL DEFAULT 0,
+    tax_jurisdiction TEXT NOT NULL DEFAULT '',
+    pricing TEXT,
+    coupon_id INTEGER REFERENCES coupons(id),
+    shipping_address TEXT,
+    version INTEGER NOT NULL DEFAULT 1,
+    cancellation_reason TEXT NOT NULL DEFAULT '',
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+INSERT INTO orders_new (
+    id, user_id, status, total_amount, created_at, deleted_at, currency, exchange_rate,
+    subtotal_amount, discount_amount, tax_amount, shipping_amount, tax_jurisdiction,
+    pricing, coupon_id, shipping_address, version, cancellation_reason
+)
+SELECT
+    id, user_id, CASE status WHEN 'completed' THEN 'shipped' ELSE status END, total_amount, created_at, deleted_at, currency, exchange_rate,
+    subtotal_amount, discount_amount, tax_amount, shipping_amount, tax_jurisdiction,
+    pricing, coupon_id, shipping_address, version, cancellation_reason
+FROM orders;
+DROP TABLE orders;
+ALTER TABLE orders_new RENAME TO orders;
+CREATE INDEX idx_orders_user_id ON orders(user_id);
+CREATE INDEX idx_orders_deleted_at ON orders(deleted_at);
+
+PRAGMA foreign_keys = ON;
+
+-- orders completed before shipments existed were shipped whole: record
+-- that as one shipment each, so their status follows from their shipments
+-- like every other order's.
+INSERT INTO shipments (order_id, status, created_at, updated_at, shipped_at)
+SELECT id, 'shipped', created_at, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP FROM orders WHERE status = 'shipped';
+INSERT INTO shipment_items (shipment_id, order_item_id, quantity)
+SELECT s.id, oi.id, oi.quantity FROM shipments s JOIN order_items oi ON oi.order_id = s.order_id;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_order_items_line;
+CREATE UNIQUE INDEX idx_order_items_line ON order_items(order_id, product_id, variant);
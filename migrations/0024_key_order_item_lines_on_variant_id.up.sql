@@ -0,0 +1,32 @@
+-- order lines are keyed on the catalog variant rather than the free-form
+-- variant label, so a label can never collide with a variant's SKU. Fold
+-- lines that now share a key into the earliest one, which keeps its price,
+-- moving their shipped and returned units onto it, before enforcing it.
+CREATE TEMP TABLE order_item_merges AS
+SELECT oi.id AS id, (
+    SELECT MIN(k.id) FROM order_items k
+    WHERE k.order_id = oi.order_id AND k.product_id = oi.product_id
+      AND COALESCE(k.variant_id, 0) = COALESCE(oi.variant_id, 0)
+) AS kept_id
+FROM order_items oi;
+DELETE FROM order_item_merges WHERE id = kept_id;
+
+UPDATE order_items SET quantity = quantity + (
+    SELECT SUM(d.quantity) FROM order_items d
+    JOIN order_item_merges m ON m.id = d.id
+    WHERE m.kept_id = order_items.id
+)
+WHERE id IN (SELECT kept_id FROM order_item_merges);
+UPDATE shipment_items SET order_item_id = (
+    SELECT kept_id FROM order_item_merges WHERE id = shipment_items.order_item_id
+)
+WHERE order_item_id IN (SELECT id FROM order_item_merges);
+UPDATE return_items SET order_item_id = (
+    SELECT kept_id FROM order_item_merges WHERE id = return_items.order_item_id
+)
+WHERE order_item_id IN (SELECT id FROM order_item_merges);
+DELETE FROM order_items WHERE id IN (SELECT id FROM order_item_merges);
+DROP TABLE order_item_merges;
+
+DROP INDEX IF EXISTS idx_order_items_line;
+CREATE UNIQUE INDEX idx_order_items_line ON order_items(order_id, product_id, COALESCE(variant_id, 0));
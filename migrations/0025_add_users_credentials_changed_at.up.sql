@@ -0,0 +1,2 @@
+-- tokens issued before a password change are no longer accepted
+ALTER TABLE users ADD COLUMN credentials_changed_at DATETIME;